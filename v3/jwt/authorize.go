@@ -0,0 +1,248 @@
+package jwtware
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Match selects how the Values of a Requirement are matched.
+type Match int
+
+const (
+	// MatchAny requires at least one of the values.
+	MatchAny Match = iota
+	// MatchAll requires every value.
+	MatchAll
+)
+
+// Requirement is a condition on a single claim, checked by RequireClaims.
+type Requirement struct {
+	// Claim is the name of the claim. Nested claims are addressed by a
+	// dotted path such as "realm_access.roles"; a claim whose name
+	// contains dots, such as "https://example.com/roles", is matched as a
+	// whole first.
+	// Required.
+	Claim string
+
+	// Values are compared with the claim, or with its elements if it is
+	// an array. Numbers and booleans are compared in their JSON form.
+	// Without Values the claim only has to be present.
+	// Optional. Default: nil
+	Values []string
+
+	// Match selects whether any or all of Values are required.
+	// Optional. Default: MatchAny
+	Match Match
+
+	// Split splits string claims at whitespace, as in the OAuth "scope"
+	// claim.
+	// Optional. Default: false
+	Split bool
+}
+
+// RequireClaims returns a middleware that requires the token stored by New
+// to satisfy every requirement. It answers 401 without a token and 403
+// with an RFC 6750 "insufficient_scope" error if a requirement fails. The
+// challenge uses the scheme of the request's Authorization header, DPoP or
+// Bearer.
+//
+//	app.Get("/admin", jwtware.RequireClaims(jwtware.Requirement{
+//		Claim:  "realm_access.roles",
+//		Values: []string{"admin"},
+//	}), handler)
+func RequireClaims(requirements ...Requirement) fiber.Handler {
+	for _, r := range requirements {
+		if r.Claim == "" {
+			panic("Fiber: JWT claims requirement: Claim is required")
+		}
+	}
+	return func(c fiber.Ctx) error {
+		token := FromContext(c)
+		if token == nil {
+			return authError(c, fiber.StatusUnauthorized, "", "")
+		}
+		claims, err := claimsMap(token.Claims)
+		if err != nil {
+			return authError(c, fiber.StatusForbidden, "insufficient_scope", "")
+		}
+		for _, r := range requirements {
+			if !r.satisfiedBy(claims) {
+				return authError(c, fiber.StatusForbidden, "insufficient_scope", "")
+			}
+		}
+		return c.Next()
+	}
+}
+
+// RequireScopes returns a middleware that requires the token stored by New
+// to grant all scopes, read from the space-separated "scope" claim or, if
+// absent, from the "scp" claim. It answers 403 with an RFC 6750
+// "insufficient_scope" error listing the scopes otherwise. Use
+// RequireClaims with MatchAny to require any of several scopes. It panics
+// without scopes, which would let every token pass.
+func RequireScopes(scopes ...string) fiber.Handler {
+	if len(scopes) == 0 {
+		panic("Fiber: JWT scopes requirement: At least one scope is required")
+	}
+	scope := strings.Join(scopes, " ")
+	return func(c fiber.Ctx) error {
+		token := FromContext(c)
+		if token == nil {
+			return authError(c, fiber.StatusUnauthorized, "", "")
+		}
+		claims, err := claimsMap(token.Claims)
+		if err != nil {
+			return authError(c, fiber.StatusForbidden, "insufficient_scope", scope)
+		}
+		r := Requirement{Claim: "scope", Values: scopes, Match: MatchAll, Split: true}
+		if _, ok := lookupClaim(claims, "scope"); !ok {
+			r.Claim = "scp"
+		}
+		if !r.satisfiedBy(claims) {
+			return authError(c, fiber.StatusForbidden, "insufficient_scope", scope)
+		}
+		return c.Next()
+	}
+}
+
+// RequireAudience returns a middleware that requires the "aud" claim of the
+// token stored by New to contain any of audiences. A token for another
+// audience is answered with 401 and an RFC 6750 "invalid_token" error. It
+// panics without audiences, which would let every token pass.
+func RequireAudience(audiences ...string) fiber.Handler {
+	if len(audiences) == 0 {
+		panic("Fiber: JWT audience requirement: At least one audience is required")
+	}
+	r := Requirement{Claim: "aud", Values: audiences}
+	return func(c fiber.Ctx) error {
+		token := FromContext(c)
+		if token == nil {
+			return authError(c, fiber.StatusUnauthorized, "", "")
+		}
+		claims, err := claimsMap(token.Claims)
+		if err != nil || !r.satisfiedBy(claims) {
+			return authError(c, fiber.StatusUnauthorized, "invalid_token", "")
+		}
+		return c.Next()
+	}
+}
+
+func (r Requirement) satisfiedBy(claims map[string]any) bool {
+	claim, ok := lookupClaim(claims, r.Claim)
+	if !ok {
+		return false
+	}
+	if len(r.Values) == 0 {
+		return true
+	}
+	have := claimValues(claim, r.Split)
+	if r.Match == MatchAll {
+		for _, v := range r.Values {
+			if !slices.Contains(have, v) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range r.Values {
+		if slices.Contains(have, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// claimsMap returns claims as a map. Typed claims are converted through
+// their JSON form, so paths use the JSON names of their fields.
+func claimsMap(claims jwt.Claims) (map[string]any, error) {
+	if mc, ok := claims.(jwt.MapClaims); ok {
+		return mc, nil
+	}
+	raw, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// lookupClaim resolves the dotted path in claims.
+func lookupClaim(claims map[string]any, path string) (any, bool) {
+	if v, ok := claims[path]; ok {
+		return v, v != nil
+	}
+	name, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		return nil, false
+	}
+	switch child := claims[name].(type) {
+	case map[string]any:
+		return lookupClaim(child, rest)
+	case jwt.MapClaims:
+		return lookupClaim(child, rest)
+	}
+	return nil, false
+}
+
+// claimValues flattens a claim into the strings compared with
+// Requirement.Values.
+func claimValues(claim any, split bool) []string {
+	if list, ok := claim.([]any); ok {
+		values := make([]string, 0, len(list))
+		for _, v := range list {
+			values = append(values, claimValues(v, split)...)
+		}
+		return values
+	}
+	switch v := claim.(type) {
+	case string:
+		if split {
+			return strings.Fields(v)
+		}
+		return []string{v}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case json.Number:
+		return []string{v.String()}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case []string:
+		return v
+	}
+	return nil
+}
+
+// authError answers with status and an RFC 6750 WWW-Authenticate
+// challenge, in the DPoP scheme of RFC 9449 when the request used it.
+// Without code the challenge carries no error, as for a request without a
+// token.
+func authError(c fiber.Ctx, status int, code, scope string) error {
+	challenge := "Bearer"
+	scheme, _, _ := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if strings.EqualFold(scheme, "DPoP") {
+		challenge = "DPoP"
+	}
+	if code != "" {
+		challenge += ` error="` + code + `"`
+	}
+	if scope != "" {
+		challenge += `, scope="` + scope + `"`
+	}
+	c.Set(fiber.HeaderWWWAuthenticate, challenge)
+	switch code {
+	case "":
+		return c.Status(status).SendString(ErrMissingToken.Error())
+	case "insufficient_scope":
+		return c.Status(status).SendString("Insufficient scope")
+	default:
+		return c.Status(status).SendString("Invalid or expired JWT")
+	}
+}
@@ -0,0 +1,140 @@
+package jwtware_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	jwtware "github.com/gofiber/contrib/v3/jwt"
+)
+
+func TestRequireClaims(t *testing.T) {
+	key := jwtware.SigningKey{JWTAlg: jwtware.HS256, Key: []byte(defaultSigningKey)}
+	issuer := jwtware.NewIssuer(jwtware.IssuerConfig{SigningKey: key, Audience: []string{"api"}})
+
+	app := fiber.New()
+	app.Get("/anonymous", jwtware.RequireScopes("read"), func(c fiber.Ctx) error { return c.SendString("ok") })
+	app.Use(jwtware.New(jwtware.Config{SigningKey: key}))
+	ok := func(c fiber.Ctx) error { return c.SendString("ok") }
+	app.Get("/read", jwtware.RequireScopes("read"), ok)
+	app.Get("/write", jwtware.RequireScopes("read", "write"), ok)
+	app.Get("/api", jwtware.RequireAudience("other", "api"), ok)
+	app.Get("/billing", jwtware.RequireAudience("billing"), ok)
+	app.Get("/admin", jwtware.RequireClaims(
+		jwtware.Requirement{Claim: "realm_access.roles", Values: []string{"admin", "owner"}},
+		jwtware.Requirement{Claim: "https://example.com/tenant"},
+	), ok)
+	app.Get("/staff", jwtware.RequireClaims(
+		jwtware.Requirement{Claim: "realm_access.roles", Values: []string{"admin", "staff"}, Match: jwtware.MatchAll},
+	), ok)
+
+	token, err := issuer.AccessToken("alice", jwt.MapClaims{
+		"scope":                      "read profile",
+		"realm_access":               map[string]any{"roles": []string{"admin"}},
+		"https://example.com/tenant": "acme",
+	})
+	require.NoError(t, err)
+
+	call := func(path string) (int, string) {
+		req := httptest.NewRequest(fiber.MethodGet, path, nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode, resp.Header.Get(fiber.HeaderWWWAuthenticate)
+	}
+
+	status, challenge := call("/anonymous")
+	assert.Equal(t, fiber.StatusUnauthorized, status)
+	assert.Equal(t, "Bearer", challenge)
+
+	status, _ = call("/read")
+	assert.Equal(t, fiber.StatusOK, status)
+	status, challenge = call("/write")
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Equal(t, `Bearer error="insufficient_scope", scope="read write"`, challenge)
+
+	status, _ = call("/api")
+	assert.Equal(t, fiber.StatusOK, status)
+	status, challenge = call("/billing")
+	assert.Equal(t, fiber.StatusUnauthorized, status)
+	assert.Equal(t, `Bearer error="invalid_token"`, challenge)
+
+	status, _ = call("/admin")
+	assert.Equal(t, fiber.StatusOK, status)
+	status, challenge = call("/staff")
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Equal(t, `Bearer error="insufficient_scope"`, challenge)
+
+	assert.Panics(t, func() { jwtware.RequireScopes() })
+	assert.Panics(t, func() { jwtware.RequireAudience() })
+}
+
+func TestRequireScopesWithDPoP(t *testing.T) {
+	app := fiber.New()
+	app.Use(jwtware.New(jwtware.Config{
+		SigningKey: jwtware.SigningKey{Key: []byte(defaultSigningKey)},
+		DPoP:       jwtware.NewDPoP(jwtware.DPoPConfig{}),
+	}))
+	app.Get("/write", jwtware.RequireScopes("write"), func(c fiber.Ctx) error { return c.SendString("ok") })
+
+	key := newECDPoPKey(t)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"scope": "read",
+		"cnf":   map[string]any{"jkt": key.jkt},
+	}).SignedString([]byte(defaultSigningKey))
+	require.NoError(t, err)
+
+	// The challenge uses the scheme the client authenticated with.
+	req := httptest.NewRequest(fiber.MethodGet, "http://example.com/write", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "DPoP "+token)
+	req.Header.Set("DPoP", key.proof(t, fiber.MethodGet, "http://example.com/write", token, time.Now()))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, `DPoP error="insufficient_scope", scope="write"`, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+}
+
+type roleClaims struct {
+	Roles []string `json:"roles"`
+	Scp   []string `json:"scp"`
+	jwt.RegisteredClaims
+}
+
+func TestRequireClaimsWithTypedClaims(t *testing.T) {
+	app := fiber.New()
+	app.Use(jwtware.New(jwtware.Config{
+		SigningKey: jwtware.SigningKey{Key: []byte(defaultSigningKey)},
+		Claims:     &roleClaims{},
+	}))
+	ok := func(c fiber.Ctx) error { return c.SendString("ok") }
+	app.Get("/scp", jwtware.RequireScopes("read"), ok)
+	app.Get("/aud", jwtware.RequireAudience("api"), ok)
+	app.Get("/roles", jwtware.RequireClaims(jwtware.Requirement{Claim: "roles", Values: []string{"editor"}}), ok)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, roleClaims{
+		Roles:            []string{"viewer"},
+		Scp:              []string{"read"},
+		RegisteredClaims: jwt.RegisteredClaims{Audience: jwt.ClaimStrings{"api"}},
+	})
+	signed, err := token.SignedString([]byte(defaultSigningKey))
+	require.NoError(t, err)
+
+	for path, want := range map[string]int{
+		"/scp":   fiber.StatusOK,
+		"/aud":   fiber.StatusOK,
+		"/roles": fiber.StatusForbidden,
+	} {
+		req := httptest.NewRequest(fiber.MethodGet, path, nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+signed)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, want, resp.StatusCode, path)
+	}
+
+	assert.Panics(t, func() { jwtware.RequireClaims(jwtware.Requirement{}) })
+}
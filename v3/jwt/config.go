@@ -34,16 +34,16 @@ type Config struct {
 
 	// ErrorHandler is executed when token validation fails.
 	// It allows customization of JWT error responses.
-	// Optional. Default: 401 Invalid or expired JWT
+	// Optional. Default: 401 Invalid or expired JWT, 503 when token introspection fails
 	ErrorHandler fiber.ErrorHandler
 
 	// SigningKey is the primary key used to validate tokens.
 	// Used as a fallback if SigningKeys is empty.
-	// At least one of the following is required: KeyFunc, JWKSetURLs, SigningKeys, or SigningKey.
+	// At least one of the following is required: KeyFunc, JWKSetURLs, SigningKeys, SigningKey, or Introspection.
 	SigningKey SigningKey
 
 	// SigningKeys is a map of keys used to validate tokens with the "kid" field.
-	// At least one of the following is required: KeyFunc, JWKSetURLs, SigningKeys, or SigningKey.
+	// At least one of the following is required: KeyFunc, JWKSetURLs, SigningKeys, SigningKey, or Introspection.
 	SigningKeys map[string]SigningKey
 
 	// Claims are extendable claims data defining token content.
@@ -51,7 +51,7 @@ type Config struct {
 	Claims jwt.Claims
 
 	// Extractor defines a function to extract the token from the request.
-	// Optional. Default: FromAuthHeader("Bearer").
+	// Optional. Default: FromAuthHeader("Bearer"), or FromAuthHeader("DPoP") with DPoP.
 	Extractor extractors.Extractor
 
 	// TokenProcessorFunc processes the token extracted using the Extractor.
@@ -61,7 +61,7 @@ type Config struct {
 	// KeyFunc provides the public key for JWT verification.
 	// It handles algorithm verification and key selection.
 	// By default, the github.com/MicahParks/keyfunc/v2 package is used.
-	// At least one of the following is required: KeyFunc, JWKSetURLs, SigningKeys, or SigningKey.
+	// At least one of the following is required: KeyFunc, JWKSetURLs, SigningKeys, SigningKey, or Introspection.
 	KeyFunc jwt.Keyfunc
 
 	// JWKSetURLs is a list of URLs containing JSON Web Key Sets (JWKS) for signature verification.
@@ -71,12 +71,29 @@ type Config struct {
 	// - Auto-refresh on new "kid" in JWT.
 	// - Rate limit refreshes to once every 5 minutes.
 	// - Timeout refreshes after 10 seconds.
-	// At least one of the following is required: KeyFunc, JWKSetURLs, SigningKeys, or SigningKey.
+	// At least one of the following is required: KeyFunc, JWKSetURLs, SigningKeys, SigningKey, or Introspection.
 	JWKSetURLs []string
 
 	// ParserOptions provides additional options for JWT parsing.
 	// Optional. Default: nil
 	ParserOptions []jwt.ParserOption
+
+	// Introspection validates opaque tokens at an OAuth 2.0 introspection
+	// endpoint, see NewIntrospector. Tokens that are not JWTs, or all
+	// tokens if no key is configured, are introspected; ParserOptions
+	// still validate the returned claims.
+	// At least one of the following is required: KeyFunc, JWKSetURLs, SigningKeys, SigningKey, or Introspection.
+	Introspection *Introspector
+
+	// DPoP requires every token to be bound to a key with a DPoP proof
+	// (RFC 9449), see NewDPoP. Invalid proofs fail with ErrInvalidDPoPProof.
+	// Optional. Default: nil
+	DPoP *DPoP
+
+	// Revocation rejects revoked tokens after they were parsed, see
+	// NewRevocation. Storage errors reject the token as well.
+	// Optional. Default: nil
+	Revocation *Revocation
 }
 
 // SigningKey holds information about the recognized cryptographic keys used to sign JWTs by this program.
@@ -110,11 +127,19 @@ func makeCfg(config []Config) (cfg Config) {
 			if e, ok := err.(*fiber.Error); ok {
 				return c.Status(e.Code).SendString(e.Message)
 			}
+			if errors.Is(err, ErrInvalidDPoPProof) {
+				c.Set(fiber.HeaderWWWAuthenticate, `DPoP error="invalid_dpop_proof"`)
+				return c.Status(fiber.StatusUnauthorized).SendString(ErrInvalidDPoPProof.Error())
+			}
+			if errors.Is(err, ErrIntrospectionFailed) {
+				// The token may be fine; the endpoint could not tell.
+				return c.Status(fiber.StatusServiceUnavailable).SendString(ErrIntrospectionFailed.Error())
+			}
 			return c.Status(fiber.StatusUnauthorized).SendString("Invalid or expired JWT")
 		}
 	}
-	if cfg.SigningKey.Key == nil && len(cfg.SigningKeys) == 0 && len(cfg.JWKSetURLs) == 0 && cfg.KeyFunc == nil {
-		panic("Fiber: JWT middleware configuration: At least one of the following is required: KeyFunc, JWKSetURLs, SigningKeys, or SigningKey.")
+	if cfg.SigningKey.Key == nil && len(cfg.SigningKeys) == 0 && len(cfg.JWKSetURLs) == 0 && cfg.KeyFunc == nil && cfg.Introspection == nil {
+		panic("Fiber: JWT middleware configuration: At least one of the following is required: KeyFunc, JWKSetURLs, SigningKeys, SigningKey, or Introspection.")
 	}
 	if len(cfg.SigningKeys) > 0 {
 		for _, key := range cfg.SigningKeys {
@@ -138,7 +163,11 @@ func makeCfg(config []Config) (cfg Config) {
 		cfg.Claims = jwt.MapClaims{}
 	}
 	if cfg.Extractor.Extract == nil {
-		cfg.Extractor = extractors.FromAuthHeader("Bearer")
+		if cfg.DPoP != nil {
+			cfg.Extractor = extractors.FromAuthHeader("DPoP")
+		} else {
+			cfg.Extractor = extractors.FromAuthHeader("Bearer")
+		}
 	}
 
 	if cfg.KeyFunc == nil {
@@ -161,7 +190,7 @@ func makeCfg(config []Config) (cfg Config) {
 			} else {
 				cfg.KeyFunc = keyfunc.NewGiven(givenKeys).Keyfunc
 			}
-		} else {
+		} else if cfg.SigningKey.Key != nil {
 			cfg.KeyFunc = signingKeyFunc(cfg.SigningKey)
 		}
 	}
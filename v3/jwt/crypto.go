@@ -45,4 +45,7 @@ const (
 
 	// PS512 represents a public cryptography key generated by a 512 bit RSA algorithm.
 	PS512 = "PS512"
+
+	// EdDSA represents a public cryptography key generated by an Ed25519 algorithm.
+	EdDSA = "EdDSA"
 )
@@ -0,0 +1,301 @@
+package jwtware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidDPoPProof is passed to the ErrorHandler when the DPoP proof of
+// a request is missing, malformed, replayed or not bound to its access
+// token. The wrapping error tells which check failed.
+var ErrInvalidDPoPProof = errors.New("invalid DPoP proof")
+
+// DPoPConfig defines the config for a DPoP verifier.
+type DPoPConfig struct {
+	// Algorithms are the accepted signature algorithms of proofs.
+	// Optional. Default: ES256, ES384, ES512, PS256, PS384, PS512, RS256, RS384, RS512, EdDSA
+	Algorithms []string
+
+	// MaxAge is how far the "iat" of a proof may be from now, in either
+	// direction. Proofs are remembered for this long to detect replays.
+	// Optional. Default: 1 * time.Minute
+	MaxAge time.Duration
+
+	// Storage remembers the "jti" of accepted proofs. Use a shared Storage
+	// when several instances serve the same clients.
+	//
+	// fiber.Storage has no atomic set-if-absent, so a jti is checked with
+	// a Get followed by a Set. Checks are serialized within one DPoP, but
+	// a proof replayed to two instances at the same moment can pass on
+	// both if both Gets run before either Set.
+	// Optional. Default: in-memory
+	Storage fiber.Storage
+
+	// KeyPrefix is prepended to every Storage key.
+	// Optional. Default: "dpop_jti:"
+	KeyPrefix string
+
+	// URL returns the URL the proof's "htu" must match, for example the
+	// public URL behind a proxy. Query and fragment are ignored.
+	// Optional. Default: c.BaseURL() + c.Path()
+	URL func(c fiber.Ctx) string
+}
+
+// DPoP verifies DPoP proofs (RFC 9449) of sender-constrained access
+// tokens, used by New when set as Config.DPoP.
+type DPoP struct {
+	cfg DPoPConfig
+	// mu serializes replay checks, against seen or against Storage.
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewDPoP creates a DPoP verifier.
+func NewDPoP(config DPoPConfig) *DPoP {
+	cfg := config
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = []string{ES256, ES384, ES512, PS256, PS384, PS512, RS256, RS384, RS512, EdDSA}
+	}
+	for _, alg := range cfg.Algorithms {
+		if alg == "none" || strings.HasPrefix(alg, "HS") {
+			panic("Fiber: JWT DPoP configuration: Unsupported algorithm (must be asymmetric): " + alg)
+		}
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = time.Minute
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "dpop_jti:"
+	}
+	if cfg.URL == nil {
+		cfg.URL = func(c fiber.Ctx) string {
+			return c.BaseURL() + c.Path()
+		}
+	}
+	return &DPoP{cfg: cfg, seen: make(map[string]time.Time)}
+}
+
+// Verify checks the DPoP header of c: the proof must be signed by the key
+// in its "jwk" header, match the method and URL of c, be recent and unused,
+// carry the hash of accessToken in "ath", and the key's thumbprint must
+// equal the "cnf.jkt" claim of token.
+func (d *DPoP) Verify(c fiber.Ctx, accessToken string, token *jwt.Token) error {
+	proofs := c.Request().Header.PeekAll("DPoP")
+	if len(proofs) != 1 {
+		return fmt.Errorf("%w: expected one DPoP header, got %d", ErrInvalidDPoPProof, len(proofs))
+	}
+
+	var thumbprint string
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(string(proofs[0]), claims, func(proof *jwt.Token) (interface{}, error) {
+		if typ, _ := proof.Header["typ"].(string); typ != "dpop+jwt" {
+			return nil, errors.New(`typ must be "dpop+jwt"`)
+		}
+		jwk, ok := proof.Header["jwk"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("missing jwk header")
+		}
+		key, jkt, err := parsePublicJWK(jwk)
+		if err != nil {
+			return nil, err
+		}
+		thumbprint = jkt
+		return key, nil
+	}, jwt.WithValidMethods(d.cfg.Algorithms))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidDPoPProof, err)
+	}
+
+	if htm, _ := claims["htm"].(string); htm != c.Method() {
+		return fmt.Errorf("%w: htm does not match the request method", ErrInvalidDPoPProof)
+	}
+	if htu, _ := claims["htu"].(string); !sameURL(htu, d.cfg.URL(c)) {
+		return fmt.Errorf("%w: htu does not match the request URL", ErrInvalidDPoPProof)
+	}
+	iat, err := claims.GetIssuedAt()
+	if err != nil || iat == nil {
+		return fmt.Errorf("%w: missing iat", ErrInvalidDPoPProof)
+	}
+	if age := time.Since(iat.Time); age > d.cfg.MaxAge || age < -d.cfg.MaxAge {
+		return fmt.Errorf("%w: iat is outside the accepted window", ErrInvalidDPoPProof)
+	}
+	hash := sha256.Sum256([]byte(accessToken))
+	ath, _ := claims["ath"].(string)
+	if subtle.ConstantTimeCompare([]byte(ath), []byte(base64.RawURLEncoding.EncodeToString(hash[:]))) != 1 {
+		return fmt.Errorf("%w: ath does not match the access token", ErrInvalidDPoPProof)
+	}
+	tokenClaims, err := claimsMap(token.Claims)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidDPoPProof, err)
+	}
+	jkt, _ := lookupClaim(tokenClaims, "cnf.jkt")
+	if jkt, _ := jkt.(string); subtle.ConstantTimeCompare([]byte(jkt), []byte(thumbprint)) != 1 {
+		return fmt.Errorf("%w: key does not match the cnf.jkt of the access token", ErrInvalidDPoPProof)
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return fmt.Errorf("%w: missing jti", ErrInvalidDPoPProof)
+	}
+	// The proof is rejected by its iat once the entry expires.
+	return d.remember(c, thumbprint+":"+jti, iat.Add(d.cfg.MaxAge))
+}
+
+// remember records the proof key until expires and fails if it is known.
+func (d *DPoP) remember(ctx context.Context, key string, expires time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cfg.Storage != nil {
+		key = d.cfg.KeyPrefix + key
+		val, err := d.cfg.Storage.GetWithContext(ctx, key)
+		if err != nil {
+			return err
+		}
+		if val != nil {
+			return fmt.Errorf("%w: jti was already used", ErrInvalidDPoPProof)
+		}
+		return d.cfg.Storage.SetWithContext(ctx, key, []byte("1"), time.Until(expires))
+	}
+
+	now := time.Now()
+	if exp, ok := d.seen[key]; ok && now.Before(exp) {
+		return fmt.Errorf("%w: jti was already used", ErrInvalidDPoPProof)
+	}
+	// Drop expired entries now and then so the map stays bounded by the
+	// proofs of the last MaxAge.
+	if len(d.seen) >= 1024 && len(d.seen)&(len(d.seen)-1) == 0 {
+		for k, exp := range d.seen {
+			if !now.Before(exp) {
+				delete(d.seen, k)
+			}
+		}
+	}
+	d.seen[key] = expires
+	return nil
+}
+
+// sameURL compares two URLs without query and fragment, ignoring the case
+// of scheme and host and default ports.
+func sameURL(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil || a == "" {
+		return false
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) &&
+		canonicalHost(ua) == canonicalHost(ub) &&
+		ua.EscapedPath() == ub.EscapedPath()
+}
+
+func canonicalHost(u *url.URL) string {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	scheme := strings.ToLower(u.Scheme)
+	if (scheme == "https" && port == "443") || (scheme == "http" && port == "80") {
+		port = ""
+	}
+	if port == "" {
+		return host
+	}
+	return host + ":" + port
+}
+
+// parsePublicJWK returns the public key of jwk and its RFC 7638 thumbprint.
+func parsePublicJWK(jwk map[string]interface{}) (interface{}, string, error) {
+	member := func(name string) string {
+		v, _ := jwk[name].(string)
+		return v
+	}
+	decode := func(name string) ([]byte, error) {
+		b, err := base64.RawURLEncoding.DecodeString(member(name))
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("invalid jwk member %q", name)
+		}
+		return b, nil
+	}
+	if _, ok := jwk["d"]; ok {
+		return nil, "", errors.New("jwk must not contain a private key")
+	}
+
+	var key interface{}
+	var thumbprintInput string
+	switch member("kty") {
+	case "EC":
+		var curve elliptic.Curve
+		switch member("crv") {
+		case P256:
+			curve = elliptic.P256()
+		case P384:
+			curve = elliptic.P384()
+		case P521:
+			curve = elliptic.P521()
+		default:
+			return nil, "", fmt.Errorf("unsupported jwk curve %q", member("crv"))
+		}
+		x, err := decode("x")
+		if err != nil {
+			return nil, "", err
+		}
+		y, err := decode("y")
+		if err != nil {
+			return nil, "", err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, "", errors.New("invalid jwk coordinates")
+		}
+		pub, err := ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, "", err
+		}
+		key = pub
+		thumbprintInput = `{"crv":"` + member("crv") + `","kty":"EC","x":"` + member("x") + `","y":"` + member("y") + `"}`
+	case "RSA":
+		n, err := decode("n")
+		if err != nil {
+			return nil, "", err
+		}
+		e, err := decode("e")
+		if err != nil {
+			return nil, "", err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 || exponent.Int64() < 3 {
+			return nil, "", errors.New("invalid jwk exponent")
+		}
+		key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+		thumbprintInput = `{"e":"` + member("e") + `","kty":"RSA","n":"` + member("n") + `"}`
+	case "OKP":
+		if member("crv") != "Ed25519" {
+			return nil, "", fmt.Errorf("unsupported jwk curve %q", member("crv"))
+		}
+		x, err := decode("x")
+		if err != nil {
+			return nil, "", err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, "", errors.New("invalid jwk key size")
+		}
+		key = ed25519.PublicKey(x)
+		thumbprintInput = `{"crv":"Ed25519","kty":"OKP","x":"` + member("x") + `"}`
+	default:
+		return nil, "", fmt.Errorf("unsupported jwk key type %q", member("kty"))
+	}
+	sum := sha256.Sum256([]byte(thumbprintInput))
+	return key, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,186 @@
+package jwtware_test
+
+import (
+	"crypto"
+	cryptoecdsa "crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	jwtware "github.com/gofiber/contrib/v3/jwt"
+)
+
+// dpopKey is a client key with its public JWK and RFC 7638 thumbprint.
+type dpopKey struct {
+	method jwt.SigningMethod
+	key    crypto.Signer
+	jwk    map[string]any
+	jkt    string
+}
+
+func newECDPoPKey(t *testing.T) dpopKey {
+	t.Helper()
+	key, err := cryptoecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pub, err := key.PublicKey.Bytes()
+	require.NoError(t, err)
+	x := base64.RawURLEncoding.EncodeToString(pub[1:33])
+	y := base64.RawURLEncoding.EncodeToString(pub[33:])
+	sum := sha256.Sum256([]byte(`{"crv":"P-256","kty":"EC","x":"` + x + `","y":"` + y + `"}`))
+	return dpopKey{
+		method: jwt.SigningMethodES256,
+		key:    key,
+		jwk:    map[string]any{"kty": "EC", "crv": "P-256", "x": x, "y": y},
+		jkt:    base64.RawURLEncoding.EncodeToString(sum[:]),
+	}
+}
+
+func newEdDPoPKey(t *testing.T) dpopKey {
+	t.Helper()
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	x := base64.RawURLEncoding.EncodeToString(pub)
+	sum := sha256.Sum256([]byte(`{"crv":"Ed25519","kty":"OKP","x":"` + x + `"}`))
+	return dpopKey{
+		method: jwt.SigningMethodEdDSA,
+		key:    key,
+		jwk:    map[string]any{"kty": "OKP", "crv": "Ed25519", "x": x},
+		jkt:    base64.RawURLEncoding.EncodeToString(sum[:]),
+	}
+}
+
+func (k dpopKey) proof(t *testing.T, method, htu, accessToken string, iat time.Time) string {
+	t.Helper()
+	hash := sha256.Sum256([]byte(accessToken))
+	proof := jwt.NewWithClaims(k.method, jwt.MapClaims{
+		"htm": method,
+		"htu": htu,
+		"iat": iat.Unix(),
+		"jti": rand.Text(),
+		"ath": base64.RawURLEncoding.EncodeToString(hash[:]),
+	})
+	proof.Header["typ"] = "dpop+jwt"
+	proof.Header["jwk"] = k.jwk
+	signed, err := proof.SignedString(k.key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestDPoP(t *testing.T) {
+	app := fiber.New()
+	app.Use(jwtware.New(jwtware.Config{
+		SigningKey: jwtware.SigningKey{Key: []byte(defaultSigningKey)},
+		DPoP:       jwtware.NewDPoP(jwtware.DPoPConfig{}),
+	}))
+	app.Get("/resource", func(c fiber.Ctx) error { return c.SendString("ok") })
+
+	accessToken := func(jkt string) string {
+		claims := jwt.MapClaims{"sub": "alice"}
+		if jkt != "" {
+			claims["cnf"] = map[string]any{"jkt": jkt}
+		}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(defaultSigningKey))
+		require.NoError(t, err)
+		return signed
+	}
+	call := func(token string, proofs ...string) (int, string) {
+		req := httptest.NewRequest(fiber.MethodGet, "http://example.com/resource?page=2", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "DPoP "+token)
+		for _, proof := range proofs {
+			req.Header.Add("DPoP", proof)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode, resp.Header.Get(fiber.HeaderWWWAuthenticate)
+	}
+	const htu = "http://example.com/resource"
+
+	ec := newECDPoPKey(t)
+	token := accessToken(ec.jkt)
+	proof := ec.proof(t, fiber.MethodGet, htu, token, time.Now())
+	status, _ := call(token, proof)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// A proof is accepted only once.
+	status, challenge := call(token, proof)
+	assert.Equal(t, fiber.StatusUnauthorized, status)
+	assert.Equal(t, `DPoP error="invalid_dpop_proof"`, challenge)
+
+	// htu is compared without query, case of host and default port.
+	status, _ = call(token, ec.proof(t, fiber.MethodGet, "http://EXAMPLE.com:80/resource", token, time.Now()))
+	assert.Equal(t, fiber.StatusOK, status)
+
+	ed := newEdDPoPKey(t)
+	edToken := accessToken(ed.jkt)
+	status, _ = call(edToken, ed.proof(t, fiber.MethodGet, htu, edToken, time.Now()))
+	assert.Equal(t, fiber.StatusOK, status)
+
+	for name, proofs := range map[string][]string{
+		"missing":      nil,
+		"two proofs":   {ec.proof(t, fiber.MethodGet, htu, token, time.Now()), ec.proof(t, fiber.MethodGet, htu, token, time.Now())},
+		"wrong method": {ec.proof(t, fiber.MethodPost, htu, token, time.Now())},
+		"wrong url":    {ec.proof(t, fiber.MethodGet, "https://example.com/resource", token, time.Now())},
+		"too old":      {ec.proof(t, fiber.MethodGet, htu, token, time.Now().Add(-time.Hour))},
+		"other token":  {ec.proof(t, fiber.MethodGet, htu, edToken, time.Now())},
+		"other key":    {ed.proof(t, fiber.MethodGet, htu, token, time.Now())},
+	} {
+		status, _ := call(token, proofs...)
+		assert.Equal(t, fiber.StatusUnauthorized, status, name)
+	}
+
+	// Tokens without a bound key cannot be used with any proof.
+	unbound := accessToken("")
+	status, _ = call(unbound, ec.proof(t, fiber.MethodGet, htu, unbound, time.Now()))
+	assert.Equal(t, fiber.StatusUnauthorized, status)
+
+	assert.Panics(t, func() { jwtware.NewDPoP(jwtware.DPoPConfig{Algorithms: []string{jwtware.HS256}}) })
+}
+
+func TestDPoPStorageReplay(t *testing.T) {
+	app := fiber.New()
+	app.Use(jwtware.New(jwtware.Config{
+		SigningKey: jwtware.SigningKey{Key: []byte(defaultSigningKey)},
+		DPoP:       jwtware.NewDPoP(jwtware.DPoPConfig{Storage: newMemoryStorage()}),
+	}))
+	app.Get("/resource", func(c fiber.Ctx) error { return c.SendString("ok") })
+
+	key := newECDPoPKey(t)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "alice",
+		"cnf": map[string]any{"jkt": key.jkt},
+	}).SignedString([]byte(defaultSigningKey))
+	require.NoError(t, err)
+	proof := key.proof(t, fiber.MethodGet, "http://example.com/resource", token, time.Now())
+
+	// Concurrent replays to one instance are serialized, so exactly one
+	// of them is accepted.
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(fiber.MethodGet, "http://example.com/resource", nil)
+			req.Header.Set(fiber.HeaderAuthorization, "DPoP "+token)
+			req.Header.Set("DPoP", proof)
+			resp, err := app.Test(req)
+			if assert.NoError(t, err) && resp.StatusCode == fiber.StatusOK {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), accepted.Load())
+}
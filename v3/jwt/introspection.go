@@ -0,0 +1,291 @@
+package jwtware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrTokenInactive is passed to the ErrorHandler for a token the
+	// introspection endpoint reports as not active.
+	ErrTokenInactive = errors.New("token is not active")
+
+	// ErrIntrospectionFailed wraps errors of the introspection request,
+	// such as an unreachable endpoint or a non-200 response. The default
+	// ErrorHandler answers it with 503 Service Unavailable.
+	ErrIntrospectionFailed = errors.New("token introspection failed")
+)
+
+// IntrospectionConfig defines the config for an Introspector.
+type IntrospectionConfig struct {
+	// URL is the RFC 7662 introspection endpoint. HTTPS is recommended.
+	// Required.
+	URL string
+
+	// ClientID and ClientSecret authenticate the resource server at the
+	// endpoint with HTTP Basic authentication.
+	// Optional. Default: ""
+	ClientID     string
+	ClientSecret string
+
+	// TokenTypeHint is sent as "token_type_hint". An empty hint is not
+	// sent.
+	// Optional. Default: "access_token"
+	TokenTypeHint string
+
+	// Client sends the introspection requests.
+	// Optional. Default: &http.Client{Timeout: 10 * time.Second}
+	Client *http.Client
+
+	// MaxCacheTTL caps how long an active response is cached. Responses
+	// are cached until their "exp" and not at all without one, so a token
+	// revoked at the provider is accepted until then.
+	// Optional. Default: 0 (until "exp")
+	MaxCacheTTL time.Duration
+
+	// InactiveCacheTTL is how long a token reported as not active is
+	// remembered, so that retries with an unknown token do not each reach
+	// the endpoint. A negative value disables it.
+	// Optional. Default: 5 * time.Second
+	InactiveCacheTTL time.Duration
+
+	// DisableCache introspects every request. Concurrent requests with the
+	// same token still share one introspection request.
+	// Optional. Default: false
+	DisableCache bool
+}
+
+// Introspector validates opaque tokens at an OAuth 2.0 token introspection
+// endpoint (RFC 7662), used by New when set as Config.Introspection.
+type Introspector struct {
+	cfg   IntrospectionConfig
+	mu    sync.Mutex
+	cache map[string]introspectionEntry
+	// calls holds the requests in flight, so that concurrent cache misses
+	// for the same token wait for one response.
+	calls map[string]*introspectionCall
+}
+
+// introspectionEntry is a cached response. Its claims are nil for a token
+// that is not active.
+type introspectionEntry struct {
+	claims  jwt.MapClaims
+	expires time.Time
+}
+
+type introspectionCall struct {
+	done   chan struct{}
+	claims jwt.MapClaims
+	err    error
+}
+
+// NewIntrospector creates an Introspector. It panics for an invalid URL,
+// like New does for JWKSetURLs.
+func NewIntrospector(config IntrospectionConfig) *Introspector {
+	cfg := config
+	parsed, err := url.Parse(cfg.URL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		panic("Fiber: JWT introspection configuration: Invalid introspection URL (must be absolute http/https): " + cfg.URL)
+	}
+	if cfg.TokenTypeHint == "" {
+		cfg.TokenTypeHint = "access_token"
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.InactiveCacheTTL == 0 {
+		cfg.InactiveCacheTTL = 5 * time.Second
+	}
+	return &Introspector{
+		cfg:   cfg,
+		cache: make(map[string]introspectionEntry),
+		calls: make(map[string]*introspectionCall),
+	}
+}
+
+// Introspect asks the endpoint about token. For an active token it returns
+// a valid *jwt.Token whose Claims are the jwt.MapClaims of the response,
+// such as "scope", "client_id", "sub" and "exp"; its Header is empty and
+// its Method is nil. Every call returns its own copy of the claims.
+func (i *Introspector) Introspect(ctx context.Context, token string) (*jwt.Token, error) {
+	key := sha256.Sum256([]byte(token))
+	claims, err := i.lookup(ctx, hex.EncodeToString(key[:]), token)
+	if err != nil {
+		return nil, err
+	}
+	// The cached claims are shared by every request with the token, so
+	// each one gets a copy it may modify.
+	return &jwt.Token{
+		Raw:    token,
+		Header: map[string]interface{}{},
+		Claims: cloneClaims(claims),
+		Valid:  true,
+	}, nil
+}
+
+// cloneClaims deep copies claims.
+func cloneClaims(claims jwt.MapClaims) jwt.MapClaims {
+	clone := make(jwt.MapClaims, len(claims))
+	for k, v := range claims {
+		clone[k] = cloneClaim(v)
+	}
+	return clone
+}
+
+// cloneClaim deep copies the objects and arrays of a decoded JSON value.
+func cloneClaim(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = cloneClaim(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = cloneClaim(e)
+		}
+		return l
+	}
+	return v
+}
+
+// lookup returns the cached response for key or joins the request in
+// flight for it, starting one if there is none.
+func (i *Introspector) lookup(ctx context.Context, key, token string) (jwt.MapClaims, error) {
+	i.mu.Lock()
+	if entry, ok := i.cached(key); ok {
+		i.mu.Unlock()
+		if entry.claims == nil {
+			return nil, ErrTokenInactive
+		}
+		return entry.claims, nil
+	}
+	if call, ok := i.calls[key]; ok {
+		i.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.claims, call.err
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrIntrospectionFailed, ctx.Err())
+		}
+	}
+	call := &introspectionCall{done: make(chan struct{})}
+	i.calls[key] = call
+	i.mu.Unlock()
+
+	call.claims, call.err = i.request(ctx, token)
+	i.mu.Lock()
+	// Storing before the call is removed keeps later misses from sending
+	// a second request.
+	i.store(key, call.claims, call.err)
+	delete(i.calls, key)
+	i.mu.Unlock()
+	close(call.done)
+	return call.claims, call.err
+}
+
+func (i *Introspector) request(ctx context.Context, token string) (jwt.MapClaims, error) {
+	form := url.Values{"token": {token}}
+	if i.cfg.TokenTypeHint != "" {
+		form.Set("token_type_hint", i.cfg.TokenTypeHint)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.cfg.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrIntrospectionFailed, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if i.cfg.ClientID != "" {
+		// RFC 6749 section 2.3.1 form-encodes the credentials first.
+		req.SetBasicAuth(url.QueryEscape(i.cfg.ClientID), url.QueryEscape(i.cfg.ClientSecret))
+	}
+
+	resp, err := i.cfg.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrIntrospectionFailed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status %d", ErrIntrospectionFailed, resp.StatusCode)
+	}
+	claims := jwt.MapClaims{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrIntrospectionFailed, err)
+	}
+	if active, _ := claims["active"].(bool); !active {
+		return nil, ErrTokenInactive
+	}
+	return claims, nil
+}
+
+// cached returns the live cache entry for key. i.mu must be held.
+func (i *Introspector) cached(key string) (introspectionEntry, bool) {
+	if i.cfg.DisableCache {
+		return introspectionEntry{}, false
+	}
+	entry, ok := i.cache[key]
+	if !ok {
+		return introspectionEntry{}, false
+	}
+	if !time.Now().Before(entry.expires) {
+		delete(i.cache, key)
+		return introspectionEntry{}, false
+	}
+	return entry, true
+}
+
+// store caches the result of a request for key. i.mu must be held.
+func (i *Introspector) store(key string, claims jwt.MapClaims, err error) {
+	if i.cfg.DisableCache {
+		return
+	}
+	now := time.Now()
+	var expires time.Time
+	switch {
+	case errors.Is(err, ErrTokenInactive):
+		if i.cfg.InactiveCacheTTL < 0 {
+			return
+		}
+		expires = now.Add(i.cfg.InactiveCacheTTL)
+	case err != nil:
+		return
+	default:
+		exp, expErr := claims.GetExpirationTime()
+		if expErr != nil || exp == nil {
+			return
+		}
+		expires = exp.Time
+		if i.cfg.MaxCacheTTL > 0 {
+			if limit := now.Add(i.cfg.MaxCacheTTL); limit.Before(expires) {
+				expires = limit
+			}
+		}
+	}
+	if !now.Before(expires) {
+		return
+	}
+	// Drop expired entries now and then so the cache stays bounded by the
+	// number of live tokens.
+	if len(i.cache) >= 1024 && len(i.cache)&(len(i.cache)-1) == 0 {
+		for k, e := range i.cache {
+			if !now.Before(e.expires) {
+				delete(i.cache, k)
+			}
+		}
+	}
+	i.cache[key] = introspectionEntry{claims: claims, expires: expires}
+}
@@ -0,0 +1,226 @@
+package jwtware_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	jwtware "github.com/gofiber/contrib/v3/jwt"
+)
+
+// newIntrospectionServer answers introspection requests for the "opaque"
+// token and counts them.
+func newIntrospectionServer(t *testing.T, exp time.Time) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		// RFC 6749 form-encodes the client credentials.
+		id, secret, ok := r.BasicAuth()
+		secret, _ = url.QueryUnescape(secret)
+		if !ok || id != "api" || secret != "s3cr:t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.FormValue("token_type_hint") != "access_token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp := map[string]any{"active": false}
+		if r.FormValue("token") == "opaque" {
+			resp = map[string]any{
+				"active":    true,
+				"sub":       "alice",
+				"client_id": "web",
+				"scope":     "read write",
+				"exp":       exp.Unix(),
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestIntrospection(t *testing.T) {
+	srv, calls := newIntrospectionServer(t, time.Now().Add(time.Hour))
+
+	app := fiber.New()
+	app.Use(jwtware.New(jwtware.Config{
+		SigningKey: jwtware.SigningKey{Key: []byte(defaultSigningKey)},
+		Introspection: jwtware.NewIntrospector(jwtware.IntrospectionConfig{
+			URL:          srv.URL,
+			ClientID:     "api",
+			ClientSecret: "s3cr:t",
+		}),
+	}))
+	app.Get("/", jwtware.RequireScopes("write"), func(c fiber.Ctx) error {
+		claims := jwtware.FromContext(c).Claims.(jwt.MapClaims)
+		return c.SendString(claims["sub"].(string) + " " + claims["client_id"].(string))
+	})
+
+	call := func(token string) (int, string) {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := call("opaque")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "alice web", body)
+
+	// Active responses are cached until exp.
+	status, _ = call("opaque")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Inactive responses are cached briefly as well.
+	for range 2 {
+		status, _ = call("unknown")
+		assert.Equal(t, fiber.StatusUnauthorized, status)
+	}
+	assert.Equal(t, int32(2), calls.Load())
+
+	// JWTs are still verified locally when a key is configured.
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":       "bob",
+		"client_id": "cli",
+		"scope":     "write",
+	}).SignedString([]byte(defaultSigningKey))
+	require.NoError(t, err)
+	status, body = call(signed)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "bob cli", body)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestIntrospectionErrors(t *testing.T) {
+	srv, calls := newIntrospectionServer(t, time.Now().Add(-time.Minute))
+
+	introspector := jwtware.NewIntrospector(jwtware.IntrospectionConfig{
+		URL:          srv.URL,
+		ClientID:     "api",
+		ClientSecret: "s3cr:t",
+	})
+	_, err := introspector.Introspect(t.Context(), "unknown")
+	require.ErrorIs(t, err, jwtware.ErrTokenInactive)
+
+	// Expired responses are neither cached nor accepted by New.
+	app := fiber.New()
+	app.Use(jwtware.New(jwtware.Config{Introspection: introspector}))
+	app.Get("/", func(c fiber.Ctx) error { return c.SendString("ok") })
+	for range 2 {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer opaque")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	}
+	assert.Equal(t, int32(3), calls.Load())
+
+	wrongSecret := jwtware.NewIntrospector(jwtware.IntrospectionConfig{URL: srv.URL, ClientID: "api"})
+	_, err = wrongSecret.Introspect(t.Context(), "opaque")
+	require.ErrorIs(t, err, jwtware.ErrIntrospectionFailed)
+
+	// A failing endpoint is not the client's fault.
+	app = fiber.New()
+	app.Use(jwtware.New(jwtware.Config{Introspection: wrongSecret}))
+	app.Get("/", func(c fiber.Ctx) error { return c.SendString("ok") })
+	req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer opaque")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+
+	assert.Panics(t, func() { jwtware.NewIntrospector(jwtware.IntrospectionConfig{}) })
+	assert.Panics(t, func() { jwtware.NewIntrospector(jwtware.IntrospectionConfig{URL: "ftp://example.com"}) })
+}
+
+func TestIntrospectionSharesConcurrentRequests(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"active":false}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	introspector := jwtware.NewIntrospector(jwtware.IntrospectionConfig{URL: srv.URL, DisableCache: true})
+	errs := make(chan error, 8)
+	for range cap(errs) {
+		go func() {
+			_, err := introspector.Introspect(t.Context(), "opaque")
+			errs <- err
+		}()
+	}
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	// Give the other callers time to join the request in flight.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for range cap(errs) {
+		require.ErrorIs(t, <-errs, jwtware.ErrTokenInactive)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Without the cache, the next request reaches the endpoint again.
+	_, err := introspector.Introspect(t.Context(), "opaque")
+	require.ErrorIs(t, err, jwtware.ErrTokenInactive)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+type introspectionClaims struct {
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+func TestIntrospectionClaims(t *testing.T) {
+	srv, _ := newIntrospectionServer(t, time.Now().Add(time.Hour))
+	introspector := jwtware.NewIntrospector(jwtware.IntrospectionConfig{
+		URL:          srv.URL,
+		ClientID:     "api",
+		ClientSecret: "s3cr:t",
+	})
+
+	// Cached claims are copied for every caller.
+	first, err := introspector.Introspect(t.Context(), "opaque")
+	require.NoError(t, err)
+	first.Claims.(jwt.MapClaims)["sub"] = "mallory"
+	second, err := introspector.Introspect(t.Context(), "opaque")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", second.Claims.(jwt.MapClaims)["sub"])
+
+	// The response is decoded into a typed Config.Claims.
+	app := fiber.New()
+	app.Use(jwtware.New(jwtware.Config{
+		Claims:        &introspectionClaims{},
+		Introspection: introspector,
+	}))
+	app.Get("/", jwtware.RequireScopes("read"), func(c fiber.Ctx) error {
+		claims := jwtware.FromContext(c).Claims.(*introspectionClaims)
+		return c.SendString(claims.Subject + " " + claims.ClientID)
+	})
+	req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer opaque")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "alice web", string(body))
+}
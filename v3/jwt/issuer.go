@@ -0,0 +1,390 @@
+package jwtware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrRefreshDisabled is returned by the refresh token methods of an
+	// Issuer without Storage.
+	ErrRefreshDisabled = errors.New("refresh tokens need IssuerConfig.Storage")
+
+	// ErrInvalidRefreshToken is returned for an unknown, expired or revoked
+	// refresh token.
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+	// ErrRefreshTokenReused is returned when a refresh token is presented a
+	// second time. Its whole family is revoked, since either the client or
+	// an attacker holds a stolen copy.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// IssuerConfig defines the config for an Issuer.
+type IssuerConfig struct {
+	// SigningKey is the key used to sign tokens when SigningKeys is empty.
+	// Key must be the private key for asymmetric algorithms, and JWTAlg is
+	// required.
+	SigningKey SigningKey
+
+	// SigningKeys is a map of keys by "kid". Tokens are signed with the key
+	// named by KeyID and carry it in their "kid" header, so verifiers
+	// configured with the matching public keys can select it.
+	SigningKeys map[string]SigningKey
+
+	// KeyID is the "kid" of the key in SigningKeys used to sign new tokens.
+	// With SigningKey it is only set as the "kid" header.
+	// Required when SigningKeys has more than one key.
+	KeyID string
+
+	// Issuer is the "iss" claim of access tokens.
+	// Optional. Default: ""
+	Issuer string
+
+	// Audience is the "aud" claim of access tokens.
+	// Optional. Default: nil
+	Audience []string
+
+	// AccessTokenTTL is the lifetime of access tokens.
+	// Optional. Default: 15 * time.Minute
+	AccessTokenTTL time.Duration
+
+	// RefreshTokenTTL is the lifetime of refresh tokens. Every refresh
+	// issues a new one with the full lifetime.
+	// Optional. Default: 30 * 24 * time.Hour
+	RefreshTokenTTL time.Duration
+
+	// Storage keeps the refresh tokens. Refresh tokens are disabled
+	// without it.
+	//
+	// fiber.Storage has no atomic compare-and-set, so spending a token is
+	// a read followed by a write. Issuers sharing one Storage across
+	// several instances can each accept the same refresh token when two
+	// requests race between that read and write, and reuse detection then
+	// misses the second use. Rotation is only race-free when a single
+	// Issuer on a single instance uses the Storage.
+	// Optional. Default: nil
+	Storage fiber.Storage
+
+	// KeyPrefix is prepended to every Storage key.
+	// Optional. Default: "jwt_refresh:"
+	KeyPrefix string
+}
+
+// TokenPair is the token response of the login and refresh handlers, in
+// the format of RFC 6749 section 5.1.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// Issuer mints access tokens and rotates refresh tokens.
+//
+// Every refresh token can be used once. Refreshing returns a new pair and
+// keeps the used token as spent; presenting a spent token again revokes
+// all tokens descending from the same login, its family.
+//
+// The single-use guarantee holds within one Issuer only: concurrent
+// refreshes are serialized in process, not in Storage. When several
+// instances share a Storage, a refresh token presented to two of them at
+// the same time can be rotated twice without being detected as reused.
+// See IssuerConfig.Storage.
+type Issuer struct {
+	cfg    IssuerConfig
+	kid    string
+	key    SigningKey
+	method jwt.SigningMethod
+	// mu serializes refreshes so a token cannot be rotated twice by
+	// concurrent requests to this process. Other processes sharing the
+	// Storage are not covered.
+	mu sync.Mutex
+}
+
+// refreshRecord is the stored state of a refresh token.
+type refreshRecord struct {
+	Family    string        `json:"family"`
+	Subject   string        `json:"sub"`
+	Claims    jwt.MapClaims `json:"claims,omitempty"`
+	ExpiresAt time.Time     `json:"exp"`
+	Used      bool          `json:"used,omitempty"`
+}
+
+// NewIssuer creates an Issuer. It panics on an invalid config, like New.
+func NewIssuer(config IssuerConfig) *Issuer {
+	cfg := config
+	if cfg.AccessTokenTTL <= 0 {
+		cfg.AccessTokenTTL = 15 * time.Minute
+	}
+	if cfg.RefreshTokenTTL <= 0 {
+		cfg.RefreshTokenTTL = 30 * 24 * time.Hour
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "jwt_refresh:"
+	}
+
+	i := &Issuer{cfg: cfg, kid: cfg.KeyID, key: cfg.SigningKey}
+	switch {
+	case len(cfg.SigningKeys) > 0:
+		if i.kid == "" && len(cfg.SigningKeys) == 1 {
+			for kid := range cfg.SigningKeys {
+				i.kid = kid
+			}
+		}
+		key, ok := cfg.SigningKeys[i.kid]
+		if !ok {
+			panic("Fiber: JWT issuer configuration: KeyID must name one of SigningKeys")
+		}
+		i.key = key
+	case cfg.SigningKey.Key == nil:
+		panic("Fiber: JWT issuer configuration: SigningKey or SigningKeys is required")
+	}
+	if i.key.Key == nil {
+		panic("Fiber: JWT issuer configuration: SigningKey.Key cannot be nil")
+	}
+	i.method = jwt.GetSigningMethod(i.key.JWTAlg)
+	if i.method == nil {
+		panic("Fiber: JWT issuer configuration: unsupported SigningKey.JWTAlg: " + i.key.JWTAlg)
+	}
+	return i
+}
+
+// AccessToken signs an access token for subject. claims are added to the
+// registered "iss", "sub", "aud", "iat", "nbf", "exp" and "jti" claims and
+// may override them.
+func (i *Issuer) AccessToken(subject string, claims jwt.MapClaims) (string, error) {
+	now := time.Now()
+	all := jwt.MapClaims{
+		"sub": subject,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(i.cfg.AccessTokenTTL).Unix(),
+		"jti": randomToken(16),
+	}
+	if i.cfg.Issuer != "" {
+		all["iss"] = i.cfg.Issuer
+	}
+	if len(i.cfg.Audience) > 0 {
+		all["aud"] = i.cfg.Audience
+	}
+	for k, v := range claims {
+		all[k] = v
+	}
+
+	token := jwt.NewWithClaims(i.method, all)
+	if i.kid != "" {
+		token.Header["kid"] = i.kid
+	}
+	return token.SignedString(i.key.Key)
+}
+
+// Issue returns an access token for subject and, when Storage is set, a
+// refresh token that starts a new family.
+func (i *Issuer) Issue(ctx context.Context, subject string, claims jwt.MapClaims) (*TokenPair, error) {
+	pair, err := i.accessPair(subject, claims)
+	if err != nil || i.cfg.Storage == nil {
+		return pair, err
+	}
+	pair.RefreshToken, err = i.storeRefreshToken(ctx, refreshRecord{
+		Family:  randomToken(16),
+		Subject: subject,
+		Claims:  claims,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Refresh spends refreshToken and returns a new access token and refresh
+// token of the same family, with the subject and claims of the login.
+//
+// It returns ErrInvalidRefreshToken for an unknown, expired or revoked
+// token, and ErrRefreshTokenReused, after revoking the family, for a token
+// that was already spent.
+func (i *Issuer) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	if i.cfg.Storage == nil {
+		return nil, ErrRefreshDisabled
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	key := i.tokenKey(refreshToken)
+	rec, err := i.loadRecord(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := i.cfg.Storage.GetWithContext(ctx, i.familyKey(rec.Family))
+	if err != nil {
+		return nil, err
+	}
+	if revoked != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if rec.Used {
+		if err := i.revokeFamily(ctx, rec.Family); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	// Keep the spent token until it expires, to detect its reuse.
+	rec.Used = true
+	if err := i.saveRecord(ctx, key, rec); err != nil {
+		return nil, err
+	}
+
+	pair, err := i.accessPair(rec.Subject, rec.Claims)
+	if err != nil {
+		return nil, err
+	}
+	pair.RefreshToken, err = i.storeRefreshToken(ctx, refreshRecord{
+		Family:  rec.Family,
+		Subject: rec.Subject,
+		Claims:  rec.Claims,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Revoke revokes the family of refreshToken, e.g. on logout. Revoking an
+// unknown token returns ErrInvalidRefreshToken.
+func (i *Issuer) Revoke(ctx context.Context, refreshToken string) error {
+	if i.cfg.Storage == nil {
+		return ErrRefreshDisabled
+	}
+	rec, err := i.loadRecord(ctx, i.tokenKey(refreshToken))
+	if err != nil {
+		return err
+	}
+	return i.revokeFamily(ctx, rec.Family)
+}
+
+// LoginHandler returns a handler that calls authenticate and responds with
+// the TokenPair for the returned subject and claims. An error from
+// authenticate is returned as is when it is a *fiber.Error, and as 401
+// Unauthorized otherwise.
+func (i *Issuer) LoginHandler(authenticate func(c fiber.Ctx) (subject string, claims jwt.MapClaims, err error)) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		subject, claims, err := authenticate(c)
+		if err != nil {
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				return fe
+			}
+			return fiber.ErrUnauthorized
+		}
+		pair, err := i.Issue(c, subject, claims)
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		return c.JSON(pair)
+	}
+}
+
+// RefreshHandler returns a handler that reads the "refresh_token" field of
+// a form or JSON body and responds with the rotated TokenPair. Invalid and
+// reused tokens get 400 Bad Request with {"error":"invalid_grant"}, as in
+// RFC 6749 section 5.2.
+func (i *Issuer) RefreshHandler() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var req struct {
+			RefreshToken string `json:"refresh_token" form:"refresh_token"`
+		}
+		if err := c.Bind().Body(&req); err != nil || req.RefreshToken == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid_request"})
+		}
+		pair, err := i.Refresh(c, req.RefreshToken)
+		if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid_grant"})
+		}
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		return c.JSON(pair)
+	}
+}
+
+func (i *Issuer) accessPair(subject string, claims jwt.MapClaims) (*TokenPair, error) {
+	access, err := i.AccessToken(subject, claims)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken: access,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(i.cfg.AccessTokenTTL / time.Second),
+	}, nil
+}
+
+func (i *Issuer) storeRefreshToken(ctx context.Context, rec refreshRecord) (string, error) {
+	token := randomToken(32)
+	rec.ExpiresAt = time.Now().Add(i.cfg.RefreshTokenTTL)
+	if err := i.saveRecord(ctx, i.tokenKey(token), rec); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (i *Issuer) loadRecord(ctx context.Context, key string) (refreshRecord, error) {
+	var rec refreshRecord
+	raw, err := i.cfg.Storage.GetWithContext(ctx, key)
+	if err != nil {
+		return rec, err
+	}
+	if raw == nil {
+		return rec, ErrInvalidRefreshToken
+	}
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return rec, ErrInvalidRefreshToken
+	}
+	if !time.Now().Before(rec.ExpiresAt) {
+		return rec, ErrInvalidRefreshToken
+	}
+	return rec, nil
+}
+
+func (i *Issuer) saveRecord(ctx context.Context, key string, rec refreshRecord) error {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return i.cfg.Storage.SetWithContext(ctx, key, raw, time.Until(rec.ExpiresAt))
+}
+
+// revokeFamily marks a family as revoked for as long as any of its tokens
+// can still be valid.
+func (i *Issuer) revokeFamily(ctx context.Context, family string) error {
+	return i.cfg.Storage.SetWithContext(ctx, i.familyKey(family), []byte("revoked"), i.cfg.RefreshTokenTTL)
+}
+
+// tokenKey stores refresh tokens by hash, so a leaked storage does not leak
+// usable tokens.
+func (i *Issuer) tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return i.cfg.KeyPrefix + "token:" + base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (i *Issuer) familyKey(family string) string {
+	return i.cfg.KeyPrefix + "family:" + family
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,233 @@
+package jwtware_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	jwtware "github.com/gofiber/contrib/v3/jwt"
+)
+
+// memoryStorage is a minimal fiber.Storage for tests.
+type memoryStorage struct {
+	mu   sync.Mutex
+	data map[string][]byte
+	exp  map[string]time.Time
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{data: map[string][]byte{}, exp: map[string]time.Time{}}
+}
+
+func (s *memoryStorage) GetWithContext(_ context.Context, key string) ([]byte, error) {
+	return s.Get(key)
+}
+
+func (s *memoryStorage) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if exp, ok := s.exp[key]; ok && time.Now().After(exp) {
+		delete(s.data, key)
+		delete(s.exp, key)
+	}
+	return s.data[key], nil
+}
+
+func (s *memoryStorage) SetWithContext(_ context.Context, key string, val []byte, exp time.Duration) error {
+	return s.Set(key, val, exp)
+}
+
+func (s *memoryStorage) Set(key string, val []byte, exp time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = val
+	delete(s.exp, key)
+	if exp > 0 {
+		s.exp[key] = time.Now().Add(exp)
+	}
+	return nil
+}
+
+func (s *memoryStorage) DeleteWithContext(_ context.Context, key string) error {
+	return s.Delete(key)
+}
+
+func (s *memoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	delete(s.exp, key)
+	return nil
+}
+
+func (s *memoryStorage) ResetWithContext(context.Context) error { return s.Reset() }
+
+func (s *memoryStorage) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = map[string][]byte{}
+	s.exp = map[string]time.Time{}
+	return nil
+}
+
+func (s *memoryStorage) Close() error { return nil }
+
+func TestIssuerAccessTokenVerifies(t *testing.T) {
+	keys := map[string]jwtware.SigningKey{
+		"old": {JWTAlg: jwtware.HS256, Key: []byte("old-secret")},
+		"new": {JWTAlg: jwtware.HS256, Key: []byte("new-secret")},
+	}
+	issuer := jwtware.NewIssuer(jwtware.IssuerConfig{
+		SigningKeys: keys,
+		KeyID:       "new",
+		Issuer:      "https://auth.example.com",
+		Audience:    []string{"api"},
+	})
+
+	signed, err := issuer.AccessToken("alice", jwt.MapClaims{"role": "admin"})
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(jwtware.New(jwtware.Config{
+		SigningKeys: keys,
+		ParserOptions: []jwt.ParserOption{
+			jwt.WithIssuer("https://auth.example.com"),
+			jwt.WithAudience("api"),
+		},
+	}))
+	app.Get("/", func(c fiber.Ctx) error {
+		token := jwtware.FromContext(c)
+		claims := token.Claims.(jwt.MapClaims)
+		return c.SendString(token.Header["kid"].(string) + " " + claims["sub"].(string) + " " + claims["role"].(string))
+	})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+signed)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "new alice admin", string(body))
+}
+
+func TestIssuerPanicsOnInvalidConfig(t *testing.T) {
+	assert.Panics(t, func() { jwtware.NewIssuer(jwtware.IssuerConfig{}) })
+	assert.Panics(t, func() {
+		jwtware.NewIssuer(jwtware.IssuerConfig{SigningKey: jwtware.SigningKey{Key: []byte("secret")}})
+	})
+	assert.Panics(t, func() {
+		jwtware.NewIssuer(jwtware.IssuerConfig{
+			SigningKeys: map[string]jwtware.SigningKey{
+				"a": {JWTAlg: jwtware.HS256, Key: []byte("a")},
+				"b": {JWTAlg: jwtware.HS256, Key: []byte("b")},
+			},
+		})
+	})
+}
+
+func TestIssuerRefreshRotation(t *testing.T) {
+	ctx := context.Background()
+	issuer := jwtware.NewIssuer(jwtware.IssuerConfig{
+		SigningKey: jwtware.SigningKey{JWTAlg: jwtware.HS256, Key: []byte(defaultSigningKey)},
+		Storage:    newMemoryStorage(),
+	})
+
+	first, err := issuer.Issue(ctx, "alice", jwt.MapClaims{"role": "admin"})
+	require.NoError(t, err)
+	require.NotEmpty(t, first.RefreshToken)
+	assert.Equal(t, "Bearer", first.TokenType)
+	assert.Equal(t, int64(15*60), first.ExpiresIn)
+
+	second, err := issuer.Refresh(ctx, first.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(second.AccessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(defaultSigningKey), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", claims["sub"])
+	assert.Equal(t, "admin", claims["role"])
+
+	// Reusing a spent token revokes the family, including the token that
+	// replaced it.
+	_, err = issuer.Refresh(ctx, first.RefreshToken)
+	require.ErrorIs(t, err, jwtware.ErrRefreshTokenReused)
+	_, err = issuer.Refresh(ctx, second.RefreshToken)
+	require.ErrorIs(t, err, jwtware.ErrInvalidRefreshToken)
+
+	// Other families are unaffected until revoked.
+	other, err := issuer.Issue(ctx, "bob", nil)
+	require.NoError(t, err)
+	require.NoError(t, issuer.Revoke(ctx, other.RefreshToken))
+	_, err = issuer.Refresh(ctx, other.RefreshToken)
+	require.ErrorIs(t, err, jwtware.ErrInvalidRefreshToken)
+
+	_, err = issuer.Refresh(ctx, "unknown")
+	require.ErrorIs(t, err, jwtware.ErrInvalidRefreshToken)
+
+	noStorage := jwtware.NewIssuer(jwtware.IssuerConfig{
+		SigningKey: jwtware.SigningKey{JWTAlg: jwtware.HS256, Key: []byte(defaultSigningKey)},
+	})
+	pair, err := noStorage.Issue(ctx, "alice", nil)
+	require.NoError(t, err)
+	assert.Empty(t, pair.RefreshToken)
+	_, err = noStorage.Refresh(ctx, "x")
+	require.ErrorIs(t, err, jwtware.ErrRefreshDisabled)
+}
+
+func TestIssuerHandlers(t *testing.T) {
+	issuer := jwtware.NewIssuer(jwtware.IssuerConfig{
+		SigningKey: jwtware.SigningKey{JWTAlg: jwtware.HS256, Key: []byte(defaultSigningKey)},
+		Storage:    newMemoryStorage(),
+	})
+
+	app := fiber.New()
+	app.Post("/login", issuer.LoginHandler(func(c fiber.Ctx) (string, jwt.MapClaims, error) {
+		if c.FormValue("password") != "secret" {
+			return "", nil, jwtware.ErrMissingToken
+		}
+		return c.FormValue("user"), nil, nil
+	}))
+	app.Post("/refresh", issuer.RefreshHandler())
+
+	post := func(path string, form url.Values) (*http.Response, jwtware.TokenPair) {
+		req := httptest.NewRequest(fiber.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var pair jwtware.TokenPair
+		_ = json.NewDecoder(resp.Body).Decode(&pair)
+		return resp, pair
+	}
+
+	resp, _ := post("/login", url.Values{"user": {"alice"}, "password": {"wrong"}})
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+
+	resp, pair := post("/login", url.Values{"user": {"alice"}, "password": {"secret"}})
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "no-store", resp.Header.Get(fiber.HeaderCacheControl))
+	require.NotEmpty(t, pair.AccessToken)
+
+	resp, next := post("/refresh", url.Values{"refresh_token": {pair.RefreshToken}})
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	require.NotEmpty(t, next.RefreshToken)
+
+	resp, _ = post("/refresh", url.Values{"refresh_token": {pair.RefreshToken}})
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = post("/refresh", url.Values{})
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
@@ -6,7 +6,9 @@
 package jwtware
 
 import (
+	"encoding/json"
 	"reflect"
+	"strings"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/golang-jwt/jwt/v5"
@@ -44,24 +46,44 @@ func New(config ...Config) fiber.Handler {
 		}
 
 		var token *jwt.Token
-		if _, ok := cfg.Claims.(jwt.MapClaims); ok {
-			token, err = jwt.Parse(auth, cfg.KeyFunc, cfg.ParserOptions...)
-		} else {
-			claimsType := reflect.TypeOf(cfg.Claims)
-			if claimsType == nil {
-				return cfg.ErrorHandler(c, fiber.NewError(fiber.StatusInternalServerError, "claims type cannot be nil"))
+		if cfg.Introspection != nil && (cfg.KeyFunc == nil || strings.Count(auth, ".") != 2) {
+			token, err = cfg.Introspection.Introspect(c, auth)
+			if err == nil {
+				if _, ok := cfg.Claims.(jwt.MapClaims); !ok {
+					// Decode the response into the configured claims type,
+					// as jwt.ParseWithClaims does for a JWT payload.
+					var claims jwt.Claims
+					if claims, err = newClaims(cfg.Claims); err != nil {
+						return cfg.ErrorHandler(c, err)
+					}
+					var raw []byte
+					if raw, err = json.Marshal(token.Claims); err == nil {
+						err = json.Unmarshal(raw, claims)
+					}
+					token.Claims = claims
+				}
 			}
-
-			if claimsType.Kind() == reflect.Ptr {
-				claimsType = claimsType.Elem()
+			if err == nil {
+				err = jwt.NewValidator(cfg.ParserOptions...).Validate(token.Claims)
 			}
-
-			claims, ok := reflect.New(claimsType).Interface().(jwt.Claims)
-			if !ok {
-				return cfg.ErrorHandler(c, fiber.NewError(fiber.StatusInternalServerError, "claims type does not implement jwt.Claims"))
+		} else if _, ok := cfg.Claims.(jwt.MapClaims); ok {
+			token, err = jwt.Parse(auth, cfg.KeyFunc, cfg.ParserOptions...)
+		} else {
+			claims, claimsErr := newClaims(cfg.Claims)
+			if claimsErr != nil {
+				return cfg.ErrorHandler(c, claimsErr)
 			}
 			token, err = jwt.ParseWithClaims(auth, claims, cfg.KeyFunc, cfg.ParserOptions...)
 		}
+		if err == nil && token.Valid && cfg.DPoP != nil {
+			err = cfg.DPoP.Verify(c, auth, token)
+		}
+		if err == nil && token.Valid && cfg.Revocation != nil {
+			var revoked bool
+			if revoked, err = cfg.Revocation.IsRevoked(c, token); err == nil && revoked {
+				err = ErrTokenRevoked
+			}
+		}
 		if err == nil && token.Valid {
 			// Store user information from token into context.
 			fiber.StoreInContext(c, tokenKey, token)
@@ -71,6 +93,24 @@ func New(config ...Config) fiber.Handler {
 	}
 }
 
+// newClaims returns a new zero value of the type of claims.
+func newClaims(claims jwt.Claims) (jwt.Claims, error) {
+	claimsType := reflect.TypeOf(claims)
+	if claimsType == nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "claims type cannot be nil")
+	}
+
+	if claimsType.Kind() == reflect.Ptr {
+		claimsType = claimsType.Elem()
+	}
+
+	c, ok := reflect.New(claimsType).Interface().(jwt.Claims)
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "claims type does not implement jwt.Claims")
+	}
+	return c, nil
+}
+
 // FromContext returns the token from the context.
 // It accepts fiber.CustomCtx, fiber.Ctx, *fasthttp.RequestCtx, and context.Context.
 // If there is no token, nil is returned.
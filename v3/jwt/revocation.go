@@ -0,0 +1,175 @@
+package jwtware
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrTokenRevoked is passed to the ErrorHandler for a revoked token.
+	ErrTokenRevoked = errors.New("token has been revoked")
+
+	// ErrMissingJTI is returned by Revocation.Revoke for a token without a
+	// "jti" claim.
+	ErrMissingJTI = errors.New("token has no jti claim")
+
+	// ErrMissingSubject is returned by Revocation.RevokeSubject for an
+	// empty subject.
+	ErrMissingSubject = errors.New("token has no sub claim")
+
+	// ErrSubjectRevocationDisabled is returned by RevokeSubject unless
+	// RevocationConfig.CheckSubject is set.
+	ErrSubjectRevocationDisabled = errors.New("subject revocation needs RevocationConfig.CheckSubject")
+)
+
+// RevocationConfig defines the config for a Revocation.
+type RevocationConfig struct {
+	// Storage keeps the revoked token IDs and subjects.
+	// Required.
+	Storage fiber.Storage
+
+	// KeyPrefix is prepended to every Storage key.
+	// Optional. Default: "jwt_revoked:"
+	KeyPrefix string
+
+	// CheckSubject also rejects tokens whose "sub" was revoked with
+	// RevokeSubject and whose "iat" is not after the revocation. It costs a
+	// second Storage lookup per request.
+	// Optional. Default: false
+	CheckSubject bool
+
+	// MaxTokenLifetime is how long entries for tokens without "exp" and
+	// subject revocations are kept. Set it to the longest lifetime of the
+	// accepted tokens; zero keeps them forever.
+	// Optional. Default: 0
+	MaxTokenLifetime time.Duration
+}
+
+// Revocation is a denylist of tokens, checked by New after a token was
+// parsed when set as Config.Revocation.
+type Revocation struct {
+	cfg RevocationConfig
+}
+
+// NewRevocation creates a Revocation. It panics without Storage, like New.
+func NewRevocation(config RevocationConfig) *Revocation {
+	cfg := config
+	if cfg.Storage == nil {
+		panic("Fiber: JWT revocation configuration: Storage is required")
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "jwt_revoked:"
+	}
+	return &Revocation{cfg: cfg}
+}
+
+// Revoke revokes token by its "jti" claim until the token expires, e.g. on
+// logout:
+//
+//	err := revocation.Revoke(c, jwtware.FromContext(c))
+func (r *Revocation) Revoke(ctx context.Context, token *jwt.Token) error {
+	id := tokenID(token.Claims)
+	if id == "" {
+		return ErrMissingJTI
+	}
+	var expiresAt time.Time
+	if exp, err := token.Claims.GetExpirationTime(); err == nil && exp != nil {
+		expiresAt = exp.Time
+	}
+	return r.RevokeID(ctx, id, expiresAt)
+}
+
+// RevokeID revokes the token with the "jti" id. The entry is kept until
+// expiresAt, or for MaxTokenLifetime when expiresAt is zero.
+func (r *Revocation) RevokeID(ctx context.Context, id string, expiresAt time.Time) error {
+	if id == "" {
+		return ErrMissingJTI
+	}
+	ttl := r.cfg.MaxTokenLifetime
+	if !expiresAt.IsZero() {
+		ttl = time.Until(expiresAt)
+		if ttl <= 0 {
+			// Expired tokens are rejected anyway.
+			return nil
+		}
+	}
+	return r.cfg.Storage.SetWithContext(ctx, r.cfg.KeyPrefix+"jti:"+id, []byte("1"), ttl)
+}
+
+// RevokeSubject revokes every token of subject issued before the given
+// time, e.g. after a password change. It needs CheckSubject.
+//
+// "iat" has one-second granularity, so the check fails closed: a token
+// issued in the same second as before is revoked too, even if it was
+// issued a moment later.
+func (r *Revocation) RevokeSubject(ctx context.Context, subject string, before time.Time) error {
+	if !r.cfg.CheckSubject {
+		return ErrSubjectRevocationDisabled
+	}
+	if subject == "" {
+		return ErrMissingSubject
+	}
+	value := []byte(strconv.FormatInt(before.Unix(), 10))
+	return r.cfg.Storage.SetWithContext(ctx, r.cfg.KeyPrefix+"sub:"+subject, value, r.cfg.MaxTokenLifetime)
+}
+
+// IsRevoked reports whether token was revoked by its "jti" or, with
+// CheckSubject, by its "sub". A token of a revoked subject without "iat"
+// counts as revoked.
+func (r *Revocation) IsRevoked(ctx context.Context, token *jwt.Token) (bool, error) {
+	if id := tokenID(token.Claims); id != "" {
+		val, err := r.cfg.Storage.GetWithContext(ctx, r.cfg.KeyPrefix+"jti:"+id)
+		if err != nil || val != nil {
+			return val != nil, err
+		}
+	}
+	if !r.cfg.CheckSubject {
+		return false, nil
+	}
+	// A token without a subject cannot be revoked by it.
+	subject, _ := token.Claims.GetSubject()
+	if subject == "" {
+		return false, nil
+	}
+	val, err := r.cfg.Storage.GetWithContext(ctx, r.cfg.KeyPrefix+"sub:"+subject)
+	if err != nil || val == nil {
+		return false, err
+	}
+	// Fail closed on a corrupt entry and on a token that cannot prove it
+	// is newer.
+	before, parseErr := strconv.ParseInt(string(val), 10, 64)
+	iat, _ := token.Claims.GetIssuedAt()
+	if parseErr != nil || iat == nil {
+		return true, nil
+	}
+	return iat.Unix() <= before, nil
+}
+
+// tokenID returns the "jti" claim of claims, from jwt.MapClaims or from
+// the ID field of a claims struct such as jwt.RegisteredClaims.
+func tokenID(claims jwt.Claims) string {
+	if mc, ok := claims.(jwt.MapClaims); ok {
+		id, _ := mc["jti"].(string)
+		return id
+	}
+	v := reflect.ValueOf(claims)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	if f := v.FieldByName("ID"); f.IsValid() && f.Kind() == reflect.String {
+		return f.String()
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package jwtware_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	jwtware "github.com/gofiber/contrib/v3/jwt"
+)
+
+func TestRevocation(t *testing.T) {
+	key := jwtware.SigningKey{JWTAlg: jwtware.HS256, Key: []byte(defaultSigningKey)}
+	issuer := jwtware.NewIssuer(jwtware.IssuerConfig{SigningKey: key})
+	revocation := jwtware.NewRevocation(jwtware.RevocationConfig{
+		Storage:          newMemoryStorage(),
+		CheckSubject:     true,
+		MaxTokenLifetime: time.Hour,
+	})
+
+	app := fiber.New()
+	app.Use(jwtware.New(jwtware.Config{SigningKey: key, Revocation: revocation}))
+	app.Get("/", func(c fiber.Ctx) error { return c.SendString("ok") })
+	app.Post("/logout", func(c fiber.Ctx) error {
+		return revocation.Revoke(c, jwtware.FromContext(c))
+	})
+
+	call := func(method, token string) int {
+		req := httptest.NewRequest(method, "/", nil)
+		if method == fiber.MethodPost {
+			req = httptest.NewRequest(method, "/logout", nil)
+		}
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	alice, err := issuer.AccessToken("alice", nil)
+	require.NoError(t, err)
+	other, err := issuer.AccessToken("alice", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, fiber.StatusOK, call(fiber.MethodGet, alice))
+	assert.Equal(t, fiber.StatusOK, call(fiber.MethodPost, alice))
+	assert.Equal(t, fiber.StatusUnauthorized, call(fiber.MethodGet, alice))
+	assert.Equal(t, fiber.StatusOK, call(fiber.MethodGet, other))
+
+	// Revoking the subject rejects every token issued before, but not the
+	// ones issued later.
+	require.NoError(t, revocation.RevokeSubject(t.Context(), "alice", time.Now()))
+	assert.Equal(t, fiber.StatusUnauthorized, call(fiber.MethodGet, other))
+	later, err := issuer.AccessToken("alice", jwt.MapClaims{"iat": time.Now().Add(2 * time.Second).Unix()})
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, call(fiber.MethodGet, later))
+}
+
+func TestRevokeSubjectBoundary(t *testing.T) {
+	revocation := jwtware.NewRevocation(jwtware.RevocationConfig{
+		Storage:      newMemoryStorage(),
+		CheckSubject: true,
+	})
+	before := time.Unix(1_700_000_000, 500_000_000)
+	require.NoError(t, revocation.RevokeSubject(t.Context(), "alice", before))
+
+	// Tokens from the same second as before are revoked as well.
+	for iat, revoked := range map[int64]bool{
+		before.Unix() - 1: true,
+		before.Unix():     true,
+		before.Unix() + 1: false,
+	} {
+		token := &jwt.Token{Claims: jwt.MapClaims{"sub": "alice", "iat": float64(iat)}}
+		got, err := revocation.IsRevoked(t.Context(), token)
+		require.NoError(t, err)
+		assert.Equal(t, revoked, got, iat)
+	}
+}
+
+func TestRevocationWithRegisteredClaims(t *testing.T) {
+	revocation := jwtware.NewRevocation(jwtware.RevocationConfig{Storage: newMemoryStorage()})
+	token := &jwt.Token{Claims: &jwt.RegisteredClaims{
+		ID:        "token-1",
+		Subject:   "alice",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}}
+
+	revoked, err := revocation.IsRevoked(t.Context(), token)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, revocation.Revoke(t.Context(), token))
+	revoked, err = revocation.IsRevoked(t.Context(), token)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	require.ErrorIs(t, revocation.Revoke(t.Context(), &jwt.Token{Claims: jwt.MapClaims{}}), jwtware.ErrMissingJTI)
+	require.ErrorIs(t, revocation.RevokeSubject(t.Context(), "alice", time.Now()), jwtware.ErrSubjectRevocationDisabled)
+	assert.Panics(t, func() { jwtware.NewRevocation(jwtware.RevocationConfig{}) })
+}
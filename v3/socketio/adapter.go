@@ -0,0 +1,329 @@
+package socketio
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Adapter fans broadcasts out across server instances and tracks which
+// local connections are in which room.
+//
+// The emit paths (Broadcast, EmitTo, EmitToList, Fire and
+// To(...).Emit) always deliver to matching connections held by this
+// process first, then hand an encoded packet to Publish so every other
+// node sharing the adapter can deliver to its own connections. Each node
+// passes the packets it receives from its peers to the handler
+// registered through Subscribe; that handler resolves rooms against the
+// node's own membership and pool.
+//
+// Room membership is per node: an adapter only records the connections
+// accepted by the process it lives in, exactly like the socket.io Node
+// server's adapters.
+//
+// Implementations must be safe for concurrent use and must not call back
+// into the socketio package (Join, Emit, ...) from any method, since
+// SetUUID calls Rename while holding the connection pool lock.
+type Adapter interface {
+	// AddAll adds the connection uuid to every room in rooms.
+	AddAll(uuid string, rooms ...string)
+	// Del removes the connection uuid from room.
+	Del(uuid, room string)
+	// DelAll removes the connection uuid from every room.
+	DelAll(uuid string)
+	// Rename moves every membership of oldUUID over to newUUID.
+	Rename(oldUUID, newUUID string)
+	// Rooms returns the rooms uuid has joined, sorted by name.
+	Rooms(uuid string) []string
+	// Members returns the UUIDs of the local connections in any of rooms.
+	Members(rooms ...string) []string
+
+	// Publish sends an encoded packet to every other node. The packet
+	// is opaque to the adapter.
+	Publish(packet []byte) error
+	// Subscribe registers the handler invoked with every packet another
+	// node published. SetAdapter calls it exactly once.
+	Subscribe(handler func(packet []byte)) error
+	// Close releases the adapter's resources. The socketio package never
+	// calls it; the owner of the adapter does.
+	Close() error
+}
+
+// MemoryAdapter is the default, single-process Adapter. It keeps room
+// membership in memory and Publish is a no-op, so broadcasts only reach
+// connections held by this process. Cross-node adapters can embed it to
+// reuse its membership bookkeeping.
+//
+// The index is kept in both directions so Join/Leave stay O(1) and the
+// disconnect path (DelAll) does not have to scan every room. Sets are
+// created lazily and dropped as soon as they become empty.
+type MemoryAdapter struct {
+	mu sync.RWMutex
+	// rooms maps a room name to the set of member connection UUIDs.
+	rooms map[string]map[string]struct{}
+	// sids maps a connection UUID to the set of rooms it has joined.
+	sids map[string]map[string]struct{}
+}
+
+// NewMemoryAdapter returns an empty in-memory adapter.
+func NewMemoryAdapter() *MemoryAdapter {
+	return &MemoryAdapter{
+		rooms: make(map[string]map[string]struct{}),
+		sids:  make(map[string]map[string]struct{}),
+	}
+}
+
+// AddAll implements Adapter. Empty room names are ignored.
+func (m *MemoryAdapter) AddAll(uuid string, rooms ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, name := range rooms {
+		if name == "" {
+			continue
+		}
+		members, ok := m.rooms[name]
+		if !ok {
+			members = make(map[string]struct{})
+			m.rooms[name] = members
+		}
+		members[uuid] = struct{}{}
+
+		joined, ok := m.sids[uuid]
+		if !ok {
+			joined = make(map[string]struct{})
+			m.sids[uuid] = joined
+		}
+		joined[name] = struct{}{}
+	}
+}
+
+// Del implements Adapter.
+func (m *MemoryAdapter) Del(uuid, room string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delLocked(uuid, room)
+	if joined, ok := m.sids[uuid]; ok {
+		delete(joined, room)
+		if len(joined) == 0 {
+			delete(m.sids, uuid)
+		}
+	}
+}
+
+// DelAll implements Adapter.
+func (m *MemoryAdapter) DelAll(uuid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name := range m.sids[uuid] {
+		m.delLocked(uuid, name)
+	}
+	delete(m.sids, uuid)
+}
+
+// delLocked drops uuid from the room side of the index only.
+func (m *MemoryAdapter) delLocked(uuid, room string) {
+	if members, ok := m.rooms[room]; ok {
+		delete(members, uuid)
+		if len(members) == 0 {
+			delete(m.rooms, room)
+		}
+	}
+}
+
+// Rename implements Adapter.
+func (m *MemoryAdapter) Rename(oldUUID, newUUID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	joined, ok := m.sids[oldUUID]
+	if !ok {
+		return
+	}
+	delete(m.sids, oldUUID)
+	for name := range joined {
+		members := m.rooms[name]
+		delete(members, oldUUID)
+		members[newUUID] = struct{}{}
+	}
+	m.sids[newUUID] = joined
+}
+
+// Rooms implements Adapter. It returns nil when uuid is in no room.
+func (m *MemoryAdapter) Rooms(uuid string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	joined := m.sids[uuid]
+	if len(joined) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(joined))
+	for name := range joined {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Members implements Adapter. A connection in several of the requested
+// rooms is listed once.
+func (m *MemoryAdapter) Members(rooms ...string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(rooms) == 1 {
+		members := m.rooms[rooms[0]]
+		out := make([]string, 0, len(members))
+		for uuid := range members {
+			out = append(out, uuid)
+		}
+		return out
+	}
+	seen := make(map[string]struct{})
+	var out []string
+	for _, name := range rooms {
+		for uuid := range m.rooms[name] {
+			if _, dup := seen[uuid]; dup {
+				continue
+			}
+			seen[uuid] = struct{}{}
+			out = append(out, uuid)
+		}
+	}
+	return out
+}
+
+// Publish implements Adapter. The in-memory adapter has no peers.
+func (*MemoryAdapter) Publish([]byte) error { return nil }
+
+// Subscribe implements Adapter. The in-memory adapter has no peers, so
+// the handler is never called.
+func (*MemoryAdapter) Subscribe(func([]byte)) error { return nil }
+
+// Close implements Adapter.
+func (*MemoryAdapter) Close() error { return nil }
+
+// adapterRef boxes the active Adapter so it can be swapped atomically.
+type adapterRef struct {
+	Adapter
+	// local is true for the plain MemoryAdapter: there are no peers, so
+	// encoding packets would be wasted work and a miss on EmitTo is
+	// definitive.
+	local bool
+}
+
+var currentAdapter atomic.Pointer[adapterRef]
+
+func init() {
+	currentAdapter.Store(&adapterRef{Adapter: NewMemoryAdapter(), local: true})
+}
+
+// adapter returns the active Adapter.
+func adapter() *adapterRef {
+	return currentAdapter.Load()
+}
+
+// SetAdapter installs a as the Adapter used by every broadcast and room
+// operation and subscribes it to packets from other nodes. Call it once
+// during startup, before the first connection is accepted: membership
+// recorded by the previous adapter is not migrated, and the previous
+// adapter is not closed.
+func SetAdapter(a Adapter) error {
+	if a == nil {
+		a = NewMemoryAdapter()
+	}
+	if err := a.Subscribe(deliverPacket); err != nil {
+		return err
+	}
+	_, local := a.(*MemoryAdapter)
+	currentAdapter.Store(&adapterRef{Adapter: a, local: local})
+	return nil
+}
+
+// Packet kinds carried between nodes.
+const (
+	// packetMessage mirrors Broadcast / EmitTo: Data is sent via Emit.
+	packetMessage = iota + 1
+	// packetEvent mirrors To(...).Emit: Event + Args sent via EmitArgs.
+	packetEvent
+	// packetFire mirrors the package-level Fire: no wire frame, listeners
+	// only.
+	packetFire
+)
+
+// adapterPacket is the node-to-node envelope handed to Adapter.Publish.
+// Short JSON keys keep the pub/sub payload small.
+type adapterPacket struct {
+	Kind  int      `json:"k"`
+	Event string   `json:"e,omitempty"`
+	Data  []byte   `json:"d,omitempty"`
+	Args  [][]byte `json:"a,omitempty"`
+	MType int      `json:"t,omitempty"`
+	// UUIDs restricts delivery to these connections (EmitTo/EmitToList).
+	UUIDs []string `json:"u,omitempty"`
+	// Rooms / Except select targets like BroadcastOperator.
+	Rooms  []string `json:"r,omitempty"`
+	Except []string `json:"x,omitempty"`
+	// ExceptUUID is the originating connection for Broadcast(except=true)
+	// and Websocket.To.
+	ExceptUUID string `json:"xu,omitempty"`
+	// Nsp restricts a packetEvent to one namespace.
+	Nsp string `json:"n,omitempty"`
+}
+
+// publish forwards p to the other nodes. With the in-memory adapter this
+// is free. Failures are reported through Logger and returned.
+func publish(p *adapterPacket) error {
+	ref := adapter()
+	if ref.local {
+		return nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := ref.Publish(b); err != nil {
+		logf("error", "adapter_publish_failure", "kind", p.Kind, "err", err.Error())
+		return err
+	}
+	return nil
+}
+
+// deliverPacket is the Subscribe handler: it decodes a packet published
+// by another node and delivers it to matching local connections.
+// Malformed packets are logged and dropped.
+func deliverPacket(packet []byte) {
+	var p adapterPacket
+	if err := json.Unmarshal(packet, &p); err != nil {
+		logf("warn", "adapter_bad_packet", "err", err.Error())
+		return
+	}
+	switch p.Kind {
+	case packetMessage:
+		var mType []int
+		if p.MType != 0 {
+			mType = []int{p.MType}
+		}
+		if len(p.UUIDs) > 0 {
+			for _, uuid := range p.UUIDs {
+				if conn, err := pool.get(uuid); err == nil && conn.IsAlive() {
+					conn.Emit(p.Data, mType...)
+				} else {
+					captureParkedMessage([]string{uuid}, "", p.Data, mType...)
+				}
+			}
+			return
+		}
+		for uuid, conn := range pool.all() {
+			if uuid != p.ExceptUUID {
+				conn.Emit(p.Data, mType...)
+			}
+		}
+		captureParkedMessage(nil, p.ExceptUUID, p.Data, mType...)
+	case packetEvent:
+		op := &BroadcastOperator{rooms: p.Rooms, except: p.Except, exceptUUID: p.ExceptUUID, nsp: p.Nsp}
+		op.emitLocal(p.Event, p.Args)
+	case packetFire:
+		fireGlobalEvent(p.Event, p.Data, nil)
+	default:
+		logf("warn", "adapter_bad_packet", "kind", p.Kind)
+	}
+}
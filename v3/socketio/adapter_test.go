@@ -0,0 +1,157 @@
+package socketio
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingAdapter is a cross-node Adapter stand-in: it keeps membership
+// in an embedded MemoryAdapter and records every published packet.
+type recordingAdapter struct {
+	*MemoryAdapter
+	mu         sync.Mutex
+	published  [][]byte
+	handler    func([]byte)
+	publishErr error
+}
+
+func (r *recordingAdapter) Publish(packet []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.published = append(r.published, append([]byte(nil), packet...))
+	return r.publishErr
+}
+
+func (r *recordingAdapter) Subscribe(handler func([]byte)) error {
+	r.handler = handler
+	return nil
+}
+
+func (r *recordingAdapter) packets(t *testing.T) []adapterPacket {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]adapterPacket, 0, len(r.published))
+	for _, b := range r.published {
+		var p adapterPacket
+		require.NoError(t, json.Unmarshal(b, &p))
+		out = append(out, p)
+	}
+	return out
+}
+
+func useRecordingAdapter(t *testing.T) *recordingAdapter {
+	t.Helper()
+	a := &recordingAdapter{MemoryAdapter: NewMemoryAdapter()}
+	require.NoError(t, SetAdapter(a))
+	t.Cleanup(func() { _ = SetAdapter(nil) })
+	return a
+}
+
+func TestMemoryAdapterDoesNotPublish(t *testing.T) {
+	resetSIOGlobals(t)
+	require.True(t, adapter().local)
+
+	// An unknown target is still an error with the in-memory adapter.
+	require.ErrorIs(t, EmitTo("missing", []byte(`"x"`)), ErrorInvalidConnection)
+}
+
+func TestAdapterPublishesBroadcasts(t *testing.T) {
+	resetSIOGlobals(t)
+	a := useRecordingAdapter(t)
+
+	kws := createWS()
+	kws.queue = make(chan message, 8)
+	pool.set(kws)
+	kws.Join("lobby")
+	require.Equal(t, []string{"lobby"}, a.Rooms(kws.UUID))
+
+	Broadcast([]byte(`"all"`))
+	kws.Broadcast([]byte(`"others"`), true)
+	require.NoError(t, EmitTo("remote-uuid", []byte(`"direct"`)))
+	EmitToList([]string{kws.UUID, "r1", "r2"}, []byte(`"list"`))
+	require.NoError(t, To("lobby").Except("muted").Emit("news", []byte(`1`)))
+	Fire("custom", []byte(`"f"`))
+
+	got := a.packets(t)
+	require.Len(t, got, 6)
+	require.Equal(t, adapterPacket{Kind: packetMessage, Data: []byte(`"all"`)}, got[0])
+	require.Equal(t, kws.UUID, got[1].ExceptUUID)
+	require.Equal(t, []string{"remote-uuid"}, got[2].UUIDs)
+	require.Equal(t, []string{"r1", "r2"}, got[3].UUIDs)
+	require.Equal(t, adapterPacket{
+		Kind:   packetEvent,
+		Event:  "news",
+		Args:   [][]byte{[]byte(`1`)},
+		Rooms:  []string{"lobby"},
+		Except: []string{"muted"},
+	}, got[4])
+	require.Equal(t, adapterPacket{Kind: packetFire, Event: "custom", Data: []byte(`"f"`)}, got[5])
+
+	// Local delivery happened before publishing: "all", "list" and the
+	// room event. "others" skipped the sender.
+	require.Len(t, kws.queue, 3)
+}
+
+func TestAdapterDeliversRemotePackets(t *testing.T) {
+	resetSIOGlobals(t)
+	a := useRecordingAdapter(t)
+	require.NotNil(t, a.handler)
+
+	member, outsider := createWS(), createWS()
+	member.queue = make(chan message, 8)
+	outsider.queue = make(chan message, 8)
+	pool.set(member)
+	pool.set(outsider)
+	member.Join("lobby")
+
+	packet, err := json.Marshal(adapterPacket{
+		Kind:  packetEvent,
+		Event: "news",
+		Args:  [][]byte{[]byte(`"hi"`)},
+		Rooms: []string{"lobby"},
+	})
+	require.NoError(t, err)
+	a.handler(packet)
+
+	select {
+	case msg := <-member.queue:
+		require.Equal(t, `42["news","hi"]`, string(msg.data))
+	case <-time.After(time.Second):
+		t.Fatal("room member did not receive the remote event")
+	}
+	require.Empty(t, outsider.queue)
+
+	packet, err = json.Marshal(adapterPacket{Kind: packetMessage, Data: []byte(`"dm"`), UUIDs: []string{outsider.UUID}})
+	require.NoError(t, err)
+	a.handler(packet)
+	require.Len(t, outsider.queue, 1)
+	require.Empty(t, member.queue)
+
+	// Garbage is dropped without panicking.
+	a.handler([]byte(`not json`))
+}
+
+func TestAdapterPublishErrorFiresEventError(t *testing.T) {
+	resetSIOGlobals(t)
+	a := useRecordingAdapter(t)
+	a.publishErr = errors.New("broker down")
+
+	errCh := make(chan error, 1)
+	On(EventError, func(ep *EventPayload) {
+		select {
+		case errCh <- ep.Error:
+		default:
+		}
+	})
+
+	kws := createWS()
+	pool.set(kws)
+	require.ErrorIs(t, kws.EmitTo("remote-uuid", []byte(`"x"`)), a.publishErr)
+	require.ErrorIs(t, <-errCh, a.publishErr)
+}
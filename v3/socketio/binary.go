@@ -0,0 +1,233 @@
+package socketio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// MaxBinaryAttachments caps the attachment count a client may announce in
+// a BINARY_EVENT / BINARY_ACK header ("5<n>-" / "6<n>-"). The server
+// buffers every attachment until the last one arrives, so the cap bounds
+// per-connection memory at MaxBinaryAttachments * MaxPayload. A header
+// above the cap tears the connection down. Zero or negative disables the
+// cap.
+var MaxBinaryAttachments = 10
+
+var (
+	// ErrBinaryAttachmentsExceeded is raised when a binary packet header
+	// announces more than MaxBinaryAttachments attachments.
+	ErrBinaryAttachmentsExceeded = errors.New("socketio: binary packet exceeds MaxBinaryAttachments")
+	// ErrInvalidBinaryPacket is raised for a malformed binary packet
+	// header or a placeholder that does not reference a received
+	// attachment.
+	ErrInvalidBinaryPacket = errors.New("socketio: invalid binary packet")
+	// ErrBinaryPacketIncomplete is raised when a text Socket.IO packet
+	// arrives while the attachments of a previous binary packet are still
+	// outstanding.
+	ErrBinaryPacketIncomplete = errors.New("socketio: got text packet while reconstructing a binary packet")
+)
+
+// binaryPacket is an inbound BINARY_EVENT / BINARY_ACK whose attachments
+// are still being collected. Only the read goroutine (WebSocket) or the
+// postGate holder (polling) touches it, so it needs no lock.
+type binaryPacket struct {
+	sioType byte
+	// header is the packet after the "<n>-" count: the optional
+	// namespace, ack id and JSON array, exactly as for "2" / "3".
+	header      []byte
+	attachments [][]byte
+	want        int
+}
+
+// startBinaryPacket parses the "<n>-" attachment count of a binary packet
+// (data is the payload after the '5' / '6' type byte) and either
+// dispatches it immediately (n == 0) or parks it until n binary frames
+// have arrived.
+func (kws *Websocket) startBinaryPacket(sioType byte, data []byte) {
+	i := 0
+	for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+		i++
+	}
+	if i == 0 || i >= len(data) || data[i] != '-' || i > 9 {
+		kws.fireEvent(EventError, data, ErrInvalidBinaryPacket)
+		return
+	}
+	n, _ := strconv.Atoi(string(data[:i]))
+	if MaxBinaryAttachments > 0 && n > MaxBinaryAttachments {
+		logf("warn", "binary_attachments_exceeded", "uuid", kws.UUID, "count", n, "limit", MaxBinaryAttachments)
+		kws.fireEvent(EventError, nil, ErrBinaryAttachmentsExceeded)
+		kws.disconnected(ErrBinaryAttachmentsExceeded)
+		return
+	}
+	p := &binaryPacket{
+		sioType: sioType,
+		// Own the header: on WebSocket it aliases the read buffer, which
+		// the next ReadMessage for the first attachment overwrites.
+		header: append([]byte(nil), data[i+1:]...),
+		want:   n,
+	}
+	if n == 0 {
+		kws.finishBinaryPacket(p)
+		return
+	}
+	p.attachments = make([][]byte, 0, n)
+	kws.pendingBinary = p
+}
+
+// addAttachment hands a binary frame to the pending binary packet, if
+// any, and reports whether it was consumed. data must already be owned by
+// the caller. Binary frames that arrive with no binary packet pending are
+// not consumed and keep surfacing as EventMessage.
+func (kws *Websocket) addAttachment(data []byte) bool {
+	p := kws.pendingBinary
+	if p == nil {
+		return false
+	}
+	p.attachments = append(p.attachments, data)
+	if len(p.attachments) == p.want {
+		kws.pendingBinary = nil
+		kws.finishBinaryPacket(p)
+	}
+	return true
+}
+
+// finishBinaryPacket dispatches a fully reassembled binary packet through
+// the same event / ack paths as its text counterpart.
+func (kws *Websocket) finishBinaryPacket(p *binaryPacket) {
+	packetNS, data := splitSIONamespace(p.header)
+	switch p.sioType {
+	case sioBinaryEvent:
+		kws.dispatchSIOEvent(p.header, packetNS, data, p.attachments)
+	case sioBinaryAck:
+		kws.dispatchSIOAck(packetNS, data, p.attachments)
+	}
+}
+
+// placeholderMarker is the key socket.io uses for attachment slots.
+var placeholderMarker = []byte(`"_placeholder"`)
+
+// substitutePlaceholders replaces every top-level
+// {"_placeholder":true,"num":i} argument with attachments[i] in place.
+// Placeholders nested deeper inside an argument are left as-is; their
+// bytes are reachable through EventPayload.Attachments.
+func substitutePlaceholders(args, attachments [][]byte) error {
+	for i, raw := range args {
+		if len(raw) == 0 || raw[0] != '{' || !bytes.Contains(raw, placeholderMarker) {
+			continue
+		}
+		var ph struct {
+			Placeholder bool `json:"_placeholder"`
+			Num         *int `json:"num"`
+		}
+		if err := json.Unmarshal(raw, &ph); err != nil || !ph.Placeholder {
+			continue
+		}
+		if ph.Num == nil || *ph.Num < 0 || *ph.Num >= len(attachments) {
+			return fmt.Errorf("%w: placeholder references missing attachment", ErrInvalidBinaryPacket)
+		}
+		args[i] = attachments[*ph.Num]
+	}
+	return nil
+}
+
+// encodeBinaryArgs turns EmitBinary / AckBinary arguments into raw-JSON
+// args plus the attachments they reference. []byte values become
+// attachments behind a placeholder; json.RawMessage is passed through (raw
+// text is JSON-encoded, as in EmitArgs); anything else goes through
+// json.Marshal.
+func encodeBinaryArgs(args []any) (jsonArgs, attachments [][]byte, err error) {
+	jsonArgs = make([][]byte, 0, len(args))
+	for _, a := range args {
+		switch v := a.(type) {
+		case []byte:
+			jsonArgs = append(jsonArgs, []byte(`{"_placeholder":true,"num":`+strconv.Itoa(len(attachments))+`}`))
+			attachments = append(attachments, v)
+		case json.RawMessage:
+			if len(v) == 0 {
+				v = json.RawMessage("null")
+			}
+			jsonArgs = append(jsonArgs, normalizeJSONArg(v))
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, nil, err
+			}
+			jsonArgs = append(jsonArgs, b)
+		}
+	}
+	return jsonArgs, attachments, nil
+}
+
+// toBinaryPacket rewrites a text EVENT ("42...") or ACK ("43...") frame
+// built by buildSIOEventWithAck / buildSIOAck into its binary form
+// ("45<n>-..." / "46<n>-...").
+func toBinaryPacket(frame []byte, n int) []byte {
+	out := make([]byte, 0, len(frame)+4)
+	out = append(out, eioMessage)
+	switch frame[1] {
+	case sioEvent:
+		out = append(out, sioBinaryEvent)
+	default:
+		out = append(out, sioBinaryAck)
+	}
+	out = strconv.AppendInt(out, int64(n), 10)
+	out = append(out, '-')
+	return append(out, frame[2:]...)
+}
+
+// writeBinary queues frame and its attachments as one unit so concurrent
+// writers cannot interleave frames between a binary packet header and its
+// attachments. Without attachments it is a plain text write.
+func (kws *Websocket) writeBinary(frame []byte, attachments [][]byte) {
+	if len(attachments) == 0 {
+		kws.write(TextMessage, frame)
+		return
+	}
+	kws.writeMessage(message{
+		mType:       TextMessage,
+		data:        toBinaryPacket(frame, len(attachments)),
+		attachments: attachments,
+	})
+}
+
+// EmitBinary sends a named event whose arguments may carry binary data.
+// Each []byte argument is sent as a binary attachment, so the JS client
+// receives it as an ArrayBuffer (or Buffer on Node); json.RawMessage is
+// passed through as JSON; any other value is encoded with json.Marshal.
+// Only top-level []byte arguments become attachments; a []byte nested in
+// a struct is base64-encoded by json.Marshal as usual.
+//
+// When no argument is a []byte the event goes out as a plain EVENT
+// packet. Returns ErrReservedEventName for reserved names and the
+// json.Marshal error for unencodable arguments; nothing is sent in either
+// case.
+func (kws *Websocket) EmitBinary(event string, args ...any) error {
+	if isReservedEventName(event) {
+		return ErrReservedEventName
+	}
+	jsonArgs, attachments, err := encodeBinaryArgs(args)
+	if err != nil {
+		return err
+	}
+	kws.writeBinary(buildSIOEventWithAck(kws.getNamespace(), 0, false, event, jsonArgs), attachments)
+	metrics().EventSent(event)
+	return nil
+}
+
+// AckBinary is the binary-capable form of Ack: []byte arguments are sent
+// as attachments of a BINARY_ACK packet, with the same argument rules as
+// EmitBinary. It shares Ack's once-only guard.
+func (ep *EventPayload) AckBinary(args ...any) error {
+	jsonArgs, attachments, err := encodeBinaryArgs(args)
+	if err != nil {
+		return err
+	}
+	if err := ep.claimAck(); err != nil {
+		return err
+	}
+	ep.Kws.writeBinary(buildSIOAck(ep.Kws.getNamespace(), ep.AckID, jsonArgs), attachments)
+	return nil
+}
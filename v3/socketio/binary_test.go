@@ -0,0 +1,233 @@
+package socketio
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubstitutePlaceholders(t *testing.T) {
+	attachments := [][]byte{{0x01}, {0x02, 0x03}}
+
+	args := [][]byte{
+		[]byte(`{"_placeholder":true,"num":1}`),
+		[]byte(`"text"`),
+		[]byte(`{"nested":{"_placeholder":true,"num":0}}`),
+		[]byte(`{"_placeholder":false,"num":0}`),
+	}
+	require.NoError(t, substitutePlaceholders(args, attachments))
+	require.Equal(t, []byte{0x02, 0x03}, args[0])
+	require.Equal(t, `"text"`, string(args[1]))
+	require.Equal(t, `{"nested":{"_placeholder":true,"num":0}}`, string(args[2]))
+	require.Equal(t, `{"_placeholder":false,"num":0}`, string(args[3]))
+
+	for _, bad := range []string{
+		`{"_placeholder":true,"num":2}`,
+		`{"_placeholder":true,"num":-1}`,
+		`{"_placeholder":true}`,
+	} {
+		require.ErrorIs(t, substitutePlaceholders([][]byte{[]byte(bad)}, attachments), ErrInvalidBinaryPacket, bad)
+	}
+}
+
+func TestEncodeBinaryArgs(t *testing.T) {
+	args, attachments, err := encodeBinaryArgs([]any{
+		[]byte{0xde, 0xad},
+		"hi",
+		[]byte("raw"),
+		map[string]int{"n": 1},
+	})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{{0xde, 0xad}, []byte("raw")}, attachments)
+	require.Equal(t, `{"_placeholder":true,"num":0}`, string(args[0]))
+	require.Equal(t, `"hi"`, string(args[1]))
+	require.Equal(t, `{"_placeholder":true,"num":1}`, string(args[2]))
+	require.Equal(t, `{"n":1}`, string(args[3]))
+
+	frame := buildSIOEventWithAck([]byte("/admin"), 0, false, "up", args)
+	require.Equal(t,
+		`452-/admin,["up",{"_placeholder":true,"num":0},"hi",{"_placeholder":true,"num":1},{"n":1}]`,
+		string(toBinaryPacket(frame, len(attachments))))
+	require.Equal(t, `461-7[{"_placeholder":true,"num":0}]`,
+		string(toBinaryPacket(buildSIOAck(nil, 7, args[:1]), 1)))
+}
+
+// TestSocketIOBinaryEventRoundTrip sends a BINARY_EVENT with an ack id
+// over WebSocket and answers it with a BINARY_ACK through AckBinary.
+func TestSocketIOBinaryEventRoundTrip(t *testing.T) {
+	resetSIOGlobals(t)
+
+	type got struct {
+		args        [][]byte
+		attachments [][]byte
+	}
+	gotCh := make(chan got, 1)
+	On("upload", func(ep *EventPayload) {
+		gotCh <- got{args: ep.Args, attachments: ep.Attachments}
+		require.NoError(t, ep.AckBinary([]byte{0xca, 0xfe}, "stored"))
+		require.ErrorIs(t, ep.AckBinary(), ErrAckAlreadySent)
+	})
+
+	ln, teardown := newSIOTestServer(t, func(_ *Websocket) {})
+	defer teardown()
+	conn := dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, sioHandshake(t, conn))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage,
+		[]byte(`452-3["upload",{"_placeholder":true,"num":0},{"name":"a.bin","blob":{"_placeholder":true,"num":1}}]`)))
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, []byte{0x01, 0x02}))
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, []byte{0x03}))
+
+	select {
+	case g := <-gotCh:
+		require.Equal(t, []byte{0x01, 0x02}, g.args[0])
+		require.Equal(t, `{"name":"a.bin","blob":{"_placeholder":true,"num":1}}`, string(g.args[1]))
+		require.Equal(t, [][]byte{{0x01, 0x02}, {0x03}}, g.attachments)
+	case <-time.After(3 * time.Second):
+		t.Fatal("binary event not dispatched")
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	tp, msg, err := sioReadSkipPings(conn)
+	require.NoError(t, err)
+	require.Equal(t, websocket.TextMessage, tp)
+	require.Equal(t, `461-3[{"_placeholder":true,"num":0},"stored"]`, string(msg))
+	tp, msg, err = sioReadSkipPings(conn)
+	require.NoError(t, err)
+	require.Equal(t, websocket.BinaryMessage, tp)
+	require.Equal(t, []byte{0xca, 0xfe}, msg)
+}
+
+// TestSocketIOEmitBinaryAndBinaryAck checks EmitBinary framing and that a
+// BINARY_ACK from the client resolves the matching outbound ack.
+func TestSocketIOEmitBinaryAndBinaryAck(t *testing.T) {
+	resetSIOGlobals(t)
+
+	kwsCh := make(chan *Websocket, 1)
+	ln, teardown := newSIOTestServer(t, func(kws *Websocket) { kwsCh <- kws })
+	defer teardown()
+	conn := dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, sioHandshake(t, conn))
+	kws := <-kwsCh
+
+	require.NoError(t, kws.EmitBinary("file", "a.bin", []byte{0x00, 0xff}))
+	require.ErrorIs(t, kws.EmitBinary(EventDisconnect), ErrReservedEventName)
+
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, msg, err := sioReadSkipPings(conn)
+	require.NoError(t, err)
+	require.Equal(t, `451-["file","a.bin",{"_placeholder":true,"num":0}]`, string(msg))
+	tp, msg, err := sioReadSkipPings(conn)
+	require.NoError(t, err)
+	require.Equal(t, websocket.BinaryMessage, tp)
+	require.Equal(t, []byte{0x00, 0xff}, msg)
+
+	// No []byte argument: plain EVENT.
+	require.NoError(t, kws.EmitBinary("plain", 1))
+	_, msg, err = sioReadSkipPings(conn)
+	require.NoError(t, err)
+	require.Equal(t, `42["plain",1]`, string(msg))
+
+	result := make(chan [][]byte, 1)
+	kws.EmitWithAckArgs("fetch", nil, func(args [][]byte, err error) {
+		require.NoError(t, err)
+		result <- args
+	})
+	_, msg, err = sioReadSkipPings(conn)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(msg), "42"), "got %q", msg)
+	ackID := strings.TrimSuffix(strings.TrimPrefix(string(msg), "42"), `["fetch"]`)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`461-`+ackID+`[{"_placeholder":true,"num":0}]`)))
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, []byte("payload")))
+	select {
+	case args := <-result:
+		require.Equal(t, [][]byte{[]byte("payload")}, args)
+	case <-time.After(3 * time.Second):
+		t.Fatal("binary ack not delivered")
+	}
+}
+
+func TestSocketIOBinaryProtocolErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		frames []string
+		want   error
+	}{
+		{
+			name:   "text packet before attachments complete",
+			frames: []string{`451-["up",{"_placeholder":true,"num":0}]`, `42["other"]`},
+			want:   ErrBinaryPacketIncomplete,
+		},
+		{
+			name:   "too many attachments",
+			frames: []string{`4599-["up"]`},
+			want:   ErrBinaryAttachmentsExceeded,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resetSIOGlobals(t)
+
+			disc := make(chan error, 1)
+			On(EventDisconnect, func(ep *EventPayload) { disc <- ep.Error })
+
+			ln, teardown := newSIOTestServer(t, func(_ *Websocket) {})
+			defer teardown()
+			conn := dialSIO(t, ln)
+			defer func() { _ = conn.Close() }()
+			require.NoError(t, sioHandshake(t, conn))
+
+			for _, f := range tc.frames {
+				require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(f)))
+			}
+			select {
+			case err := <-disc:
+				require.ErrorIs(t, err, tc.want)
+			case <-time.After(3 * time.Second):
+				t.Fatal("connection not torn down")
+			}
+		})
+	}
+}
+
+// TestPollingBinaryEvent covers attachment reassembly and EmitBinary
+// framing on the long-polling transport.
+func TestPollingBinaryEvent(t *testing.T) {
+	resetSIOGlobals(t)
+
+	gotCh := make(chan []byte, 1)
+	On("upload", func(ep *EventPayload) {
+		gotCh <- ep.Args[0]
+		require.NoError(t, ep.Kws.EmitBinary("stored", []byte("ok")))
+	})
+
+	_, c, td := newPollingTestServer(t, func(_ *Websocket) {})
+	defer td()
+
+	sid, _, _ := pollOpen(t, c)
+	_, _ = pollPost(t, c, sid, []byte(`40`))
+	_, _ = pollGet(t, c, sid) // drain CONNECT ack
+
+	// "hello" base64-encoded with the "b" prefix, batched behind the header.
+	body := `451-["upload",{"_placeholder":true,"num":0}]` + "\x1e" + "baGVsbG8="
+	_, st := pollPost(t, c, sid, []byte(body))
+	require.Equal(t, http.StatusOK, st)
+
+	select {
+	case data := <-gotCh:
+		require.Equal(t, "hello", string(data))
+	case <-time.After(2 * time.Second):
+		t.Fatal("binary event not dispatched")
+	}
+
+	resp, st := pollGet(t, c, sid)
+	require.Equal(t, http.StatusOK, st)
+	require.Equal(t, `451-["stored",{"_placeholder":true,"num":0}]`+"\x1e"+"bb2s=", string(resp))
+}
@@ -0,0 +1,240 @@
+package socketio
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"unicode/utf8"
+)
+
+// EnableEIO3 opts in to Engine.IO v3 / Socket.IO v2, the protocol of
+// socket.io-client 2.x (and the Android, Swift and Unity clients built on
+// it). When true, handshakes with EIO=3 are accepted next to EIO=4 on the
+// same route; when false (the default) they are rejected with HTTP 400 as
+// before.
+//
+// An EIO=3 session differs from a v4 one on the wire only; the listener
+// API is the same:
+//
+//   - The OPEN packet carries no maxPayload.
+//   - The client sends PING and the server answers PONG; the server only
+//     enforces PingInterval + PingTimeout.
+//   - The server connects the client to "/" right after OPEN, without
+//     waiting for a CONNECT, and CONNECT acks carry no sid.
+//   - A namespace CONNECT may carry a query string ("40/admin?token=x,"),
+//     exposed as a JSON object through HandshakeAuth.
+//   - Polling payloads are length-prefixed ("<len>:<packet>") instead of
+//     RS-separated, and WebSocket binary frames carry a leading packet
+//     type byte.
+//
+// Connection state recovery is not available to EIO=3 sessions.
+//
+// Read once per request; mutate before serving connections.
+var EnableEIO3 = false
+
+// ErrInvalidEIO3Payload is surfaced via EventError when an EIO=3 polling
+// body is not a well-formed length-prefixed payload. Packets after the
+// malformed one are dropped.
+var ErrInvalidEIO3Payload = errors.New("socketio: invalid EIO=3 polling payload")
+
+// eio3OpenPacket is the Engine.IO v3 OPEN payload.
+type eio3OpenPacket struct {
+	SID          string   `json:"sid"`
+	Upgrades     []string `json:"upgrades"`
+	PingInterval int      `json:"pingInterval"`
+	PingTimeout  int      `json:"pingTimeout"`
+}
+
+// isEIO3Request reports whether the handshake asks for Engine.IO v3 and
+// EnableEIO3 allows it.
+func isEIO3Request(eio string) bool {
+	return EnableEIO3 && eio == "3"
+}
+
+// buildEIO3OpenFrame is buildEIOOpenFrame for Engine.IO v3.
+func buildEIO3OpenFrame(sid string) ([]byte, error) {
+	data, err := json.Marshal(eio3OpenPacket{
+		SID:          sid,
+		Upgrades:     []string{},
+		PingInterval: int(PingInterval.Milliseconds()),
+		PingTimeout:  int(PingTimeout.Milliseconds()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{eioOpen}, data...), nil
+}
+
+// openFrame builds the OPEN frame matching the session's protocol.
+func (kws *Websocket) openFrame() ([]byte, error) {
+	if kws.eio3 {
+		return buildEIO3OpenFrame(kws.UUID)
+	}
+	return buildEIOOpenFrame(kws.UUID)
+}
+
+// splitEIO3Namespace splits the query string off a Socket.IO v2 CONNECT
+// namespace ("/admin?token=x") and returns it as a JSON object, the
+// closest v2 equivalent of the v5 auth payload. auth is nil without a
+// query.
+func splitEIO3Namespace(ns []byte) (namespace, auth []byte) {
+	i := bytes.IndexByte(ns, '?')
+	if i < 0 {
+		return ns, nil
+	}
+	values, err := url.ParseQuery(string(ns[i+1:]))
+	if err != nil || len(values) == 0 {
+		return ns[:i], nil
+	}
+	query := make(map[string]string, len(values))
+	for k, v := range values {
+		query[k] = v[0]
+	}
+	auth, _ = json.Marshal(query)
+	return ns[:i], auth
+}
+
+// eio3BinaryFrame prefixes an outbound WebSocket binary frame with the
+// MESSAGE packet type, as Engine.IO v3 expects.
+func eio3BinaryFrame(data []byte) []byte {
+	out := make([]byte, 0, len(data)+1)
+	out = append(out, 4)
+	return append(out, data...)
+}
+
+// utf16Len returns the length of s in UTF-16 code units, the unit of the
+// Engine.IO v3 payload length prefix (JavaScript String.length).
+func utf16Len(s []byte) int {
+	n := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeRune(s)
+		s = s[size:]
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// utf16Prefix returns the byte length of the first n UTF-16 code units of
+// s, or -1 when s is shorter than that.
+func utf16Prefix(s []byte, n int) int {
+	i := 0
+	for n > 0 {
+		if i >= len(s) {
+			return -1
+		}
+		r, size := utf8.DecodeRune(s[i:])
+		if r >= 0x10000 {
+			n -= 2
+		} else {
+			n--
+		}
+		i += size
+	}
+	if n < 0 {
+		return -1
+	}
+	return i
+}
+
+// encodeEIO3PollingFrames is encodePollingFrames for Engine.IO v3:
+// every packet is prefixed with its length and a colon. Binary frames
+// queued as "b<base64>" are sent as "b4<base64>", the v3 text form of a
+// binary MESSAGE.
+func encodeEIO3PollingFrames(frames [][]byte) []byte {
+	var buf []byte
+	for _, f := range frames {
+		if len(f) > 0 && f[0] == 'b' {
+			n := 2 + len(f) - 1
+			buf = strconv.AppendInt(buf, int64(n), 10)
+			buf = append(buf, ':', 'b', '4')
+			buf = append(buf, f[1:]...)
+			continue
+		}
+		buf = strconv.AppendInt(buf, int64(utf16Len(f)), 10)
+		buf = append(buf, ':')
+		buf = append(buf, f...)
+	}
+	return buf
+}
+
+// forEachEIO3Packet walks an Engine.IO v3 polling body and calls fn for
+// every packet until fn returns false. binary packets are passed as
+// decoded attachment bytes with binary set. It understands both the
+// string payload ("<len>:<packet>...") and the binary payload
+// (<0|1><len digits>0xFF<packet>...) that XHR2 clients send when a
+// batch holds binary data.
+func forEachEIO3Packet(body []byte, fn func(packet []byte, binary bool) bool) error {
+	if len(body) > 0 && (body[0] == 0 || body[0] == 1) {
+		return forEachEIO3BinaryPacket(body, fn)
+	}
+	for len(body) > 0 {
+		colon := bytes.IndexByte(body, ':')
+		if colon <= 0 || colon > 9 {
+			return ErrInvalidEIO3Payload
+		}
+		n, err := strconv.Atoi(string(body[:colon]))
+		if err != nil || n < 0 {
+			return ErrInvalidEIO3Payload
+		}
+		body = body[colon+1:]
+		size := utf16Prefix(body, n)
+		if size < 0 {
+			return ErrInvalidEIO3Payload
+		}
+		packet := body[:size]
+		body = body[size:]
+		if len(packet) >= 2 && packet[0] == 'b' {
+			// "b<type><base64>": only MESSAGE carries binary data.
+			decoded := make([]byte, base64.StdEncoding.DecodedLen(len(packet)-2))
+			m, err := base64.StdEncoding.Decode(decoded, packet[2:])
+			if err != nil {
+				return ErrInvalidEIO3Payload
+			}
+			if !fn(decoded[:m], true) {
+				return nil
+			}
+			continue
+		}
+		if !fn(packet, false) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func forEachEIO3BinaryPacket(body []byte, fn func(packet []byte, binary bool) bool) error {
+	for len(body) > 0 {
+		isBinary := body[0] == 1
+		i := 1
+		n := 0
+		for ; i < len(body) && body[i] != 0xFF; i++ {
+			if body[i] > 9 || i > 10 {
+				return ErrInvalidEIO3Payload
+			}
+			n = n*10 + int(body[i])
+		}
+		if i >= len(body) || len(body)-i-1 < n {
+			return ErrInvalidEIO3Payload
+		}
+		packet := body[i+1 : i+1+n]
+		body = body[i+1+n:]
+		if isBinary {
+			// The first byte is the packet type, MESSAGE for data.
+			if len(packet) == 0 {
+				return ErrInvalidEIO3Payload
+			}
+			packet = packet[1:]
+		}
+		if !fn(packet, isBinary) {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package socketio
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// enableEIO3 turns the Engine.IO v3 compatibility mode on for one test.
+func enableEIO3(t *testing.T) {
+	t.Helper()
+	prev := EnableEIO3
+	EnableEIO3 = true
+	t.Cleanup(func() { EnableEIO3 = prev })
+}
+
+// dialSIOEIO3 is dialSIO with EIO=3 in the handshake query.
+func dialSIOEIO3(t *testing.T, ln *fasthttputil.InmemoryListener) *websocket.Conn {
+	t.Helper()
+	dialer := &websocket.Dialer{
+		NetDial: func(_, _ string) (net.Conn, error) {
+			return ln.Dial()
+		},
+		HandshakeTimeout: 10 * time.Second,
+	}
+	conn, _, err := dialer.Dial("ws://"+ln.Addr().String()+"/?EIO=3&transport=websocket", nil)
+	require.NoError(t, err)
+	return conn
+}
+
+func TestEIO3PollingFraming(t *testing.T) {
+	// "é" is one UTF-16 unit in two bytes; "😀" two units in four bytes.
+	body := encodeEIO3PollingFrames([][]byte{
+		[]byte(`42["a","é"]`),
+		[]byte(`42["😀"]`),
+		[]byte("bAQI="),
+	})
+	require.Equal(t, `11:42["a","é"]8:42["😀"]6:b4AQI=`, string(body))
+
+	type packet struct {
+		data   string
+		binary bool
+	}
+	var got []packet
+	err := forEachEIO3Packet(body, func(p []byte, binary bool) bool {
+		got = append(got, packet{string(p), binary})
+		return true
+	})
+	require.NoError(t, err)
+	require.Equal(t, []packet{
+		{`42["a","é"]`, false},
+		{`42["😀"]`, false},
+		{"\x01\x02", true},
+	}, got)
+
+	// XHR2 binary payload: a string packet "2" then binary MESSAGE 0xAA.
+	got = got[:0]
+	err = forEachEIO3Packet([]byte{0, 1, 0xFF, '2', 1, 2, 0xFF, 4, 0xAA}, func(p []byte, binary bool) bool {
+		got = append(got, packet{string(p), binary})
+		return true
+	})
+	require.NoError(t, err)
+	require.Equal(t, []packet{{"2", false}, {"\xAA", true}}, got)
+
+	for _, bad := range []string{"x", "5:42", ":", "3x:abc", "4:b4!!"} {
+		err := forEachEIO3Packet([]byte(bad), func([]byte, bool) bool { return true })
+		require.ErrorIs(t, err, ErrInvalidEIO3Payload, bad)
+	}
+}
+
+func TestSplitEIO3Namespace(t *testing.T) {
+	ns, auth := splitEIO3Namespace([]byte("/admin?token=x"))
+	require.Equal(t, "/admin", string(ns))
+	require.JSONEq(t, `{"token":"x"}`, string(auth))
+
+	ns, auth = splitEIO3Namespace([]byte("/admin"))
+	require.Equal(t, "/admin", string(ns))
+	require.Nil(t, auth)
+}
+
+func TestEIO3RejectedByDefault(t *testing.T) {
+	resetSIOGlobals(t)
+	ln, teardown := newSIOTestServer(t, func(_ *Websocket) {})
+	defer teardown()
+
+	dialer := &websocket.Dialer{
+		NetDial: func(_, _ string) (net.Conn, error) {
+			return ln.Dial()
+		},
+		HandshakeTimeout: 10 * time.Second,
+	}
+	_, resp, err := dialer.Dial("ws://"+ln.Addr().String()+"/?EIO=3&transport=websocket", nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// TestEIO3WebSocketSession runs a Socket.IO v2 session over WebSocket:
+// implicit connect to "/", client-driven heartbeat, events and binary
+// frames with the packet type byte.
+func TestEIO3WebSocketSession(t *testing.T) {
+	enableEIO3(t)
+	resetSIOGlobals(t)
+
+	events := make(chan *EventPayload, 2)
+	On("echo", func(ep *EventPayload) { events <- ep })
+	kwsCh := make(chan *Websocket, 1)
+	ln, teardown := newSIOTestServer(t, func(kws *Websocket) { kwsCh <- kws })
+	defer teardown()
+
+	conn := dialSIOEIO3(t, ln)
+	defer func() { _ = conn.Close() }()
+
+	open := readText(t, conn)
+	require.Equal(t, byte(eioOpen), open[0])
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal([]byte(open[1:]), &fields))
+	require.NotEmpty(t, fields["sid"])
+	require.Contains(t, fields, "pingInterval")
+	require.NotContains(t, fields, "maxPayload")
+
+	// Connected to "/" without a CONNECT from the client.
+	require.Equal(t, "40", readText(t, conn))
+	kws := receiveSocket(t, kwsCh)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("2probe")))
+	require.Equal(t, "3probe", readText(t, conn))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`42["echo","hi"]`)))
+	select {
+	case ep := <-events:
+		require.Equal(t, `"hi"`, string(ep.Args[0]))
+	case <-time.After(3 * time.Second):
+		t.Fatal("event not dispatched")
+	}
+
+	require.NoError(t, kws.EmitBinary("bin", []byte{0x01, 0x02}))
+	require.Equal(t, `451-["bin",{"_placeholder":true,"num":0}]`, readText(t, conn))
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	mType, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, websocket.BinaryMessage, mType)
+	require.Equal(t, []byte{4, 0x01, 0x02}, data)
+}
+
+// TestEIO3PollingSession runs a Socket.IO v2 session over long-polling
+// with length-prefixed payloads.
+func TestEIO3PollingSession(t *testing.T) {
+	enableEIO3(t)
+	resetSIOGlobals(t)
+
+	events := make(chan *EventPayload, 1)
+	On("echo", func(ep *EventPayload) { events <- ep })
+	_, c, td := newPollingTestServer(t, func(kws *Websocket) { kws.Emit([]byte(`"welcome"`)) })
+	defer td()
+
+	get := func(query string) []byte {
+		t.Helper()
+		resp, err := c.Get("http://test/?EIO=3&transport=polling" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return body
+	}
+
+	body := get("")
+	colon := bytes.IndexByte(body, ':')
+	require.Positive(t, colon, "got %q", body)
+	var open eio3OpenPacket
+	require.NoError(t, json.Unmarshal(body[colon+2:], &open), "got %q", body)
+	require.NotEmpty(t, open.SID)
+
+	// The implicit CONNECT ack and the callback's emit.
+	require.Equal(t, `2:4023:42["message","welcome"]`, string(get("&sid="+open.SID)))
+
+	resp, err := c.Post("http://test/?EIO=3&transport=polling&sid="+open.SID,
+		"text/plain;charset=UTF-8", bytes.NewReader([]byte(`1:215:42["echo","hi"]`)))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	select {
+	case ep := <-events:
+		require.Equal(t, `"hi"`, string(ep.Args[0]))
+	case <-time.After(3 * time.Second):
+		t.Fatal("event not dispatched")
+	}
+	require.Equal(t, "1:3", string(get("&sid="+open.SID)))
+}
@@ -0,0 +1,130 @@
+package socketio
+
+import "sync/atomic"
+
+// Transport names passed to MetricsCollector.
+const (
+	TransportWebSocket = "websocket"
+	TransportPolling   = "polling"
+)
+
+// MetricsCollector receives counters from the socketio internals. Install
+// one with SetMetricsCollector; the socketio/prometheusmetrics and
+// socketio/otelmetrics packages provide ready-made implementations.
+//
+// Implementations must be safe for concurrent use and must not block or
+// call back into the socketio package: every method is invoked
+// synchronously on the connection hot path.
+type MetricsCollector interface {
+	// ConnectionOpened is called when a client completes its handshake
+	// and ConnectionClosed when that connection ends. transport is
+	// TransportWebSocket or TransportPolling. Namespaces multiplexed on
+	// a connection are not counted separately.
+	ConnectionOpened(transport string)
+	ConnectionClosed(transport string)
+	// HandshakeFailed is called when a handshake or namespace CONNECT is
+	// refused. reason is the message the Logger hook receives for it:
+	// "eio_version_mismatch", "invalid_namespace", "unknown_namespace",
+	// "invalid_auth_payload", "namespace_rejected", "handshake_timeout"
+	// (a polling session that never sent CONNECT) or "handshake_failure"
+	// (any other transport or protocol error).
+	HandshakeFailed(reason string)
+	// EventReceived is called for every inbound client event, before
+	// rate limiting. Event names without a registered listener are
+	// reported as "", so clients cannot grow the label set.
+	EventReceived(event string)
+	// EventSent is called once per connection an event is emitted to.
+	EventSent(event string)
+	// AckTimedOut is called when an EmitWithAck callback fails with
+	// ErrAckTimeout.
+	AckTimedOut()
+	// SendQueueOverflow is called when a frame does not fit the
+	// connection's send queue: it is dropped with ErrSendQueueOverflow
+	// when dropped is true (DropFramesOnOverflow), otherwise the
+	// connection is closed.
+	SendQueueOverflow(transport string, dropped bool)
+	// PollingSessionOpened and PollingSessionClosed track Engine.IO
+	// polling sessions, from the OPEN response until the sid expires,
+	// including sessions that never complete the handshake.
+	PollingSessionOpened()
+	PollingSessionClosed()
+}
+
+// metricsRef boxes the installed collector so it can be swapped
+// atomically.
+type metricsRef struct {
+	MetricsCollector
+}
+
+var currentMetrics atomic.Pointer[metricsRef]
+
+// SetMetricsCollector installs c as the receiver of the package's
+// metrics; nil turns collection off. Install it before serving
+// connections, or the gauges of connections opened earlier go negative
+// when they close.
+func SetMetricsCollector(c MetricsCollector) {
+	if c == nil {
+		currentMetrics.Store(nil)
+		return
+	}
+	currentMetrics.Store(&metricsRef{c})
+}
+
+// metrics returns the installed collector, or a no-op one.
+func metrics() MetricsCollector {
+	if ref := currentMetrics.Load(); ref != nil {
+		return ref.MetricsCollector
+	}
+	return noopMetrics{}
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ConnectionOpened(string)        {}
+func (noopMetrics) ConnectionClosed(string)        {}
+func (noopMetrics) HandshakeFailed(string)         {}
+func (noopMetrics) EventReceived(string)           {}
+func (noopMetrics) EventSent(string)               {}
+func (noopMetrics) AckTimedOut()                   {}
+func (noopMetrics) SendQueueOverflow(string, bool) {}
+func (noopMetrics) PollingSessionOpened()          {}
+func (noopMetrics) PollingSessionClosed()          {}
+
+// transport returns the name of the transport kws is bound to.
+func (kws *Websocket) transport() string {
+	if kws.pollQ != nil {
+		return TransportPolling
+	}
+	return TransportWebSocket
+}
+
+// connectionOpened counts kws as an open connection until
+// connectionClosed. Child namespace sockets are not counted.
+func (kws *Websocket) connectionOpened() {
+	if kws.parent == nil && kws.metricsOpen.CompareAndSwap(false, true) {
+		metrics().ConnectionOpened(kws.transport())
+	}
+}
+
+func (kws *Websocket) connectionClosed() {
+	if kws.metricsOpen.CompareAndSwap(true, false) {
+		metrics().ConnectionClosed(kws.transport())
+	}
+}
+
+// rejectHandshake logs a refused handshake or namespace CONNECT and
+// counts it under reason.
+func rejectHandshake(reason string, fields ...any) {
+	logf("warn", reason, fields...)
+	metrics().HandshakeFailed(reason)
+}
+
+// hasListener reports whether event has a listener, globally or on
+// namespace ns.
+func hasListener(ns []byte, event string) bool {
+	if len(listeners.get(event)) > 0 {
+		return true
+	}
+	nsp := lookupNamespace(ns)
+	return nsp != nil && len(nsp.listeners.get(event)) > 0
+}
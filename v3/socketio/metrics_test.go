@@ -0,0 +1,122 @@
+package socketio
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMetrics records every MetricsCollector call as a string.
+type recordingMetrics struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (m *recordingMetrics) add(call string) {
+	m.mu.Lock()
+	m.calls = append(m.calls, call)
+	m.mu.Unlock()
+}
+
+func (m *recordingMetrics) snapshot() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.calls...)
+}
+
+func (m *recordingMetrics) ConnectionOpened(transport string) { m.add("open " + transport) }
+func (m *recordingMetrics) ConnectionClosed(transport string) { m.add("close " + transport) }
+func (m *recordingMetrics) HandshakeFailed(reason string)     { m.add("handshake " + reason) }
+func (m *recordingMetrics) EventReceived(event string)        { m.add("in " + event) }
+func (m *recordingMetrics) EventSent(event string)            { m.add("out " + event) }
+func (m *recordingMetrics) AckTimedOut()                      { m.add("ack_timeout") }
+func (m *recordingMetrics) PollingSessionOpened()             { m.add("poll open") }
+func (m *recordingMetrics) PollingSessionClosed()             { m.add("poll close") }
+func (m *recordingMetrics) SendQueueOverflow(transport string, dropped bool) {
+	if dropped {
+		m.add("overflow drop " + transport)
+	} else {
+		m.add("overflow disconnect " + transport)
+	}
+}
+
+func installMetrics(t *testing.T) *recordingMetrics {
+	t.Helper()
+	m := &recordingMetrics{}
+	SetMetricsCollector(m)
+	t.Cleanup(func() { SetMetricsCollector(nil) })
+	return m
+}
+
+func waitForCall(t *testing.T, m *recordingMetrics, call string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		for _, c := range m.snapshot() {
+			if c == call {
+				return true
+			}
+		}
+		return false
+	}, 3*time.Second, 10*time.Millisecond, "no %q in %v", call, m.snapshot())
+}
+
+func TestMetricsWebSocketLifecycle(t *testing.T) {
+	resetSIOGlobals(t)
+	m := installMetrics(t)
+
+	got := make(chan struct{}, 1)
+	On("hello", func(ep *EventPayload) {
+		ep.Kws.EmitEvent("welcome", []byte(`1`))
+		got <- struct{}{}
+	})
+	kwsCh := make(chan *Websocket, 1)
+	ln, teardown := newSIOTestServer(t, func(kws *Websocket) { kwsCh <- kws })
+	defer teardown()
+
+	conn := dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, sioHandshake(t, conn))
+	kws := receiveSocket(t, kwsCh)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`42["hello"]`)))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`42["random-name"]`)))
+	<-got
+	require.Equal(t, `42["welcome",1]`, readText(t, conn))
+
+	kws.EmitWithAckTimeout("q", nil, 20*time.Millisecond, func([]byte, error) {})
+	waitForCall(t, m, "ack_timeout")
+
+	kws.Close()
+	waitForCall(t, m, "close websocket")
+	require.Subset(t, m.snapshot(), []string{"open websocket", "in hello", "in ", "out welcome", "out q"})
+
+	// A refused namespace is counted by reason.
+	Of("/admin")
+	conn2 := dialSIO(t, ln)
+	defer func() { _ = conn2.Close() }()
+	require.Equal(t, byte(eioOpen), readText(t, conn2)[0])
+	require.NoError(t, conn2.WriteMessage(websocket.TextMessage, []byte("40/nope,")))
+	frame := readText(t, conn2)
+	require.True(t, strings.HasPrefix(frame, "44/nope,"), "got %q", frame)
+	waitForCall(t, m, "handshake unknown_namespace")
+}
+
+func TestMetricsPollingSession(t *testing.T) {
+	resetSIOGlobals(t)
+	m := installMetrics(t)
+	_, c, td := newPollingTestServer(t, func(_ *Websocket) {})
+	defer td()
+
+	sid, _, _ := pollOpen(t, c)
+	_, status := pollPost(t, c, sid, []byte("40"))
+	require.Equal(t, 200, status)
+	waitForCall(t, m, "open polling")
+	_, _ = pollPost(t, c, sid, []byte("1"))
+	waitForCall(t, m, "poll close")
+	waitForCall(t, m, "close polling")
+	require.Equal(t, "poll open", m.snapshot()[0])
+}
@@ -0,0 +1,446 @@
+package socketio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrMiddlewareTimeout rejects a namespace CONNECT whose middleware chain
+// did not finish (every middleware calling next) within HandshakeTimeout.
+var ErrMiddlewareTimeout = errors.New("socketio: namespace middleware did not call next")
+
+// MiddlewareFunc runs before a client is admitted to a namespace. It must
+// call next exactly once: next(nil) passes control to the following
+// middleware, next(err) rejects the CONNECT with a CONNECT_ERROR whose
+// message is err.Error(). Pass a *ConnectError to also send data to the
+// client. next may be called from another goroutine; the chain waits up
+// to HandshakeTimeout for it.
+//
+// kws is the socket being admitted: HandshakeAuth, Query, Locals and the
+// attribute setters are usable, but the socket is not connected yet and
+// nothing emitted from a middleware reaches the client.
+type MiddlewareFunc func(kws *Websocket, next func(error))
+
+// ConnectError is a middleware rejection that carries data for the
+// client. It is sent as CONNECT_ERROR {"message": Message, "data": Data}
+// and surfaces on the client as the connect_error Error with err.data
+// set. Data must be JSON-encodable.
+type ConnectError struct {
+	Message string
+	Data    any
+}
+
+func (e *ConnectError) Error() string {
+	return e.Message
+}
+
+// Use appends middleware to the chain of the root namespace "/", which
+// every client that does not name a namespace connects to. It runs after
+// the SIO CONNECT is parsed and before the New callback; a rejection is
+// answered with CONNECT_ERROR and ends the handshake. It is shorthand for
+// Of("/").Use; register middleware for other namespaces on their
+// Namespace.
+func Use(middleware MiddlewareFunc) {
+	Of("/").Use(middleware)
+}
+
+// Namespace is a Socket.IO namespace ("/", "/admin", ...). Obtain one with
+// Of. Listeners, connect handlers and middleware registered on a namespace
+// apply only to sockets connected to it.
+type Namespace struct {
+	name      string
+	listeners *safeListeners
+	writeMu   sync.Mutex
+	// connect and middlewares are copy-on-write slices, read lock-free
+	// on every CONNECT.
+	connect     atomic.Pointer[[]func(*Websocket)]
+	middlewares atomic.Pointer[[]MiddlewareFunc]
+}
+
+// namespaceRegistry is the copy-on-write Of registry, keyed by name.
+type namespaceRegistry struct {
+	writeMu sync.Mutex
+	m       atomic.Pointer[map[string]*Namespace]
+}
+
+var namespaces = func() *namespaceRegistry {
+	r := &namespaceRegistry{}
+	empty := make(map[string]*Namespace)
+	r.m.Store(&empty)
+	return r
+}()
+
+func (r *namespaceRegistry) get(name string) *Namespace {
+	return (*r.m.Load())[name]
+}
+
+func (r *namespaceRegistry) getOrCreate(name string) *Namespace {
+	if n := r.get(name); n != nil {
+		return n
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	cur := r.m.Load()
+	if n := (*cur)[name]; n != nil {
+		return n
+	}
+	n := &Namespace{name: name, listeners: &safeListeners{}}
+	empty := make(map[string][]eventCallback)
+	n.listeners.m.Store(&empty)
+	next := make(map[string]*Namespace, len(*cur)+1)
+	for k, v := range *cur {
+		next[k] = v
+	}
+	next[name] = n
+	r.m.Store(&next)
+	return n
+}
+
+// strict reports whether a namespace other than "/" has been registered.
+// From then on only "/" and registered namespaces accept CONNECTs.
+func (r *namespaceRegistry) strict() bool {
+	for name := range *r.m.Load() {
+		if name != "/" {
+			return true
+		}
+	}
+	return false
+}
+
+//nolint:unused
+func (r *namespaceRegistry) reset() {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	empty := make(map[string]*Namespace)
+	r.m.Store(&empty)
+}
+
+// Of returns the namespace called name, registering it on first use. ""
+// and "/" both name the root namespace; a missing leading "/" is added.
+//
+// Until a namespace other than "/" is registered, clients may connect to
+// any syntactically valid namespace. After that, a CONNECT for an
+// unregistered namespace is answered with CONNECT_ERROR
+// {"message":"Invalid namespace"}.
+//
+// Panics when name contains characters outside [A-Za-z0-9._-/].
+func Of(name string) *Namespace {
+	if name == "" || name[0] != '/' {
+		name = "/" + name
+	}
+	if !isValidNamespace([]byte(name)) {
+		panic(fmt.Sprintf("socketio: invalid namespace name %q", name))
+	}
+	return namespaces.getOrCreate(name)
+}
+
+// nsName maps wire namespace bytes (nil for root) to a registry name.
+func nsName(ns []byte) string {
+	if len(ns) == 0 {
+		return "/"
+	}
+	return string(ns)
+}
+
+// lookupNamespace returns the registered namespace for wire bytes ns, or
+// nil when none is registered.
+func lookupNamespace(ns []byte) *Namespace {
+	return namespaces.get(nsName(ns))
+}
+
+// namespaceAllowed reports whether a client may CONNECT to ns.
+func namespaceAllowed(ns []byte) bool {
+	name := nsName(ns)
+	return name == "/" || namespaces.get(name) != nil || !namespaces.strict()
+}
+
+// Name returns the namespace name, e.g. "/" or "/admin".
+func (n *Namespace) Name() string {
+	return n.name
+}
+
+// On registers callback for event on sockets connected to this
+// namespace. Package-level On listeners still fire for every namespace;
+// namespace listeners run after them. Concurrency semantics match On.
+func (n *Namespace) On(event string, callback eventCallback) {
+	n.listeners.set(event, callback)
+}
+
+// OnConnect registers a handler that runs for every socket admitted to
+// this namespace, after the middleware chain and the CONNECT ack and
+// before EventConnect fires. On the first namespace of a connection it
+// runs after the New callback. Handlers run in registration order; one
+// that closes the socket stops the remaining handlers.
+func (n *Namespace) OnConnect(handler func(kws *Websocket)) {
+	n.writeMu.Lock()
+	defer n.writeMu.Unlock()
+	var cur []func(*Websocket)
+	if p := n.connect.Load(); p != nil {
+		cur = *p
+	}
+	next := append(append(make([]func(*Websocket), 0, len(cur)+1), cur...), handler)
+	n.connect.Store(&next)
+}
+
+// Use appends middleware to the chain a CONNECT to this namespace must
+// pass. Middleware runs in registration order.
+func (n *Namespace) Use(middleware MiddlewareFunc) {
+	n.writeMu.Lock()
+	defer n.writeMu.Unlock()
+	var cur []MiddlewareFunc
+	if p := n.middlewares.Load(); p != nil {
+		cur = *p
+	}
+	next := append(append(make([]MiddlewareFunc, 0, len(cur)+1), cur...), middleware)
+	n.middlewares.Store(&next)
+}
+
+// To returns a broadcast builder that targets the given rooms within this
+// namespace. With no rooms it targets every socket of the namespace.
+func (n *Namespace) To(rooms ...string) *BroadcastOperator {
+	return &BroadcastOperator{rooms: append([]string(nil), rooms...), nsp: n.name}
+}
+
+// Except returns a broadcast builder for every socket of this namespace
+// outside the given rooms.
+func (n *Namespace) Except(rooms ...string) *BroadcastOperator {
+	return &BroadcastOperator{except: append([]string(nil), rooms...), nsp: n.name}
+}
+
+// Emit sends event to every socket connected to this namespace, on this
+// node and, through the Adapter, on the others. See BroadcastOperator.Emit.
+func (n *Namespace) Emit(event string, args ...[]byte) error {
+	return n.To().Emit(event, args...)
+}
+
+// authorize runs the middleware chain of the namespace kws is connecting
+// to. A nil namespace or an empty chain admits the socket.
+func authorize(kws *Websocket) error {
+	n := lookupNamespace(kws.getNamespace())
+	if n == nil {
+		return nil
+	}
+	p := n.middlewares.Load()
+	if p == nil || len(*p) == 0 {
+		return nil
+	}
+	chain := *p
+
+	result := make(chan error, 1)
+	finish := func(err error) {
+		select {
+		case result <- err:
+		default:
+		}
+	}
+	var step func(i int)
+	step = func(i int) {
+		if i == len(chain) {
+			finish(nil)
+			return
+		}
+		var once sync.Once
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logf("error", "middleware_panic", "uuid", kws.UUID, "namespace", n.name, "panic", fmt.Sprintf("%v", r))
+					finish(fmt.Errorf("socketio: middleware panic: %v", r))
+				}
+			}()
+			chain[i](kws, func(err error) {
+				once.Do(func() {
+					if err != nil {
+						finish(err)
+						return
+					}
+					step(i + 1)
+				})
+			})
+		}()
+	}
+	step(0)
+
+	if HandshakeTimeout <= 0 {
+		return <-result
+	}
+	timer := time.NewTimer(HandshakeTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		return err
+	case <-timer.C:
+		return ErrMiddlewareTimeout
+	}
+}
+
+// connectErrorMessage encodes err as a CONNECT_ERROR payload, with the
+// data of a wrapped *ConnectError.
+func connectErrorMessage(err error) string {
+	payload := struct {
+		Message string `json:"message"`
+		Data    any    `json:"data,omitempty"`
+	}{Message: err.Error()}
+	var ce *ConnectError
+	if errors.As(err, &ce) {
+		payload.Data = ce.Data
+	}
+	b, mErr := json.Marshal(payload)
+	if mErr != nil && payload.Data != nil {
+		// Unencodable data: still send the message.
+		payload.Data = nil
+		b, mErr = json.Marshal(payload)
+	}
+	if mErr != nil {
+		return `{"message":"connection rejected"}`
+	}
+	return string(b)
+}
+
+// runConnectHandlers runs the OnConnect handlers of the namespace kws is
+// connected to. It reports false when a handler panicked or closed the
+// socket, in which case EventConnect must not fire.
+func (kws *Websocket) runConnectHandlers() bool {
+	n := lookupNamespace(kws.getNamespace())
+	if n == nil {
+		return true
+	}
+	p := n.connect.Load()
+	if p == nil {
+		return true
+	}
+	for _, handler := range *p {
+		if r := runUserCallback(handler, kws); r != nil {
+			logf("error", "namespace_connect_panic", "uuid", kws.UUID, "namespace", n.name, "panic", fmt.Sprintf("%v", r))
+			kws.disconnected(fmt.Errorf("socketio: namespace connect handler panic: %v", r))
+			return false
+		}
+		if !kws.IsAlive() {
+			return false
+		}
+	}
+	return true
+}
+
+// writeConnectAck queues a SIO CONNECT ack carrying sid for ns (no
+// payload on Socket.IO v2).
+func (kws *Websocket) writeConnectAck(ns []byte, sid string) {
+	var payload []byte
+	if !kws.eio3 {
+		var err error
+		payload, err = json.Marshal(struct {
+			SID string `json:"sid"`
+		}{SID: sid})
+		if err != nil {
+			return
+		}
+	}
+	kws.write(TextMessage, buildSIOConnectAck(ns, payload))
+}
+
+// namespaceSocket returns the socket bound to wire namespace ns on this
+// connection: kws itself for the namespace of the first CONNECT, a child
+// socket for any namespace connected later, nil otherwise.
+func (kws *Websocket) namespaceSocket(ns []byte) *Websocket {
+	if bytes.Equal(ns, kws.getNamespace()) {
+		return kws
+	}
+	kws.childrenMu.Lock()
+	defer kws.childrenMu.Unlock()
+	return kws.children[string(ns)]
+}
+
+// connectNamespace handles a CONNECT for an additional namespace on an
+// established connection. The new socket gets its own UUID, attributes
+// and ack-id space and shares the transport of kws. A rejected CONNECT is
+// answered with CONNECT_ERROR and leaves the connection open.
+func (kws *Websocket) connectNamespace(ns, auth []byte) {
+	if existing := kws.namespaceSocket(ns); existing != nil {
+		kws.writeConnectAck(ns, existing.GetUUID())
+		return
+	}
+	if !isValidAuthPayload(auth) {
+		rejectHandshake("invalid_auth_payload", "uuid", kws.UUID, "namespace", string(ns), "size", len(auth))
+		_ = kws.writeConnectError(ns, `{"message":"Invalid auth payload"}`)
+		return
+	}
+	if !namespaceAllowed(ns) {
+		rejectHandshake("unknown_namespace", "uuid", kws.UUID, "namespace", string(ns))
+		_ = kws.writeConnectError(ns, `{"message":"Invalid namespace"}`)
+		return
+	}
+
+	child := &Websocket{
+		parent:    kws,
+		Locals:    kws.Locals,
+		Params:    kws.Params,
+		Query:     kws.Query,
+		Cookies:   kws.Cookies,
+		done:      make(chan struct{}, 1),
+		namespace: append([]byte(nil), ns...),
+	}
+	if len(auth) > 0 {
+		child.handshakeAuth = append(json.RawMessage(nil), auth...)
+	}
+	child.isAlive.Store(true)
+	child.UUID = child.createUUID()
+
+	if err := authorize(child); err != nil {
+		rejectHandshake("namespace_rejected", "uuid", kws.UUID, "namespace", string(ns), "err", err.Error())
+		adapter().DelAll(child.UUID)
+		_ = kws.writeConnectError(ns, connectErrorMessage(err))
+		return
+	}
+
+	pool.set(child)
+	if !kws.addChild(child) {
+		pool.delete(child.GetUUID())
+		adapter().DelAll(child.GetUUID())
+		return
+	}
+	kws.writeConnectAck(ns, child.GetUUID())
+	if !child.runConnectHandlers() {
+		return
+	}
+	child.fireEvent(EventConnect, nil, nil)
+}
+
+// addChild registers child under its namespace. It fails once kws is
+// disconnected, so a child can never outlive its transport.
+func (kws *Websocket) addChild(child *Websocket) bool {
+	kws.childrenMu.Lock()
+	defer kws.childrenMu.Unlock()
+	if !kws.IsAlive() {
+		return false
+	}
+	if kws.children == nil {
+		kws.children = make(map[string]*Websocket)
+	}
+	kws.children[string(child.namespace)] = child
+	return true
+}
+
+// removeChild forgets child after it disconnected on its own.
+func (kws *Websocket) removeChild(child *Websocket) {
+	kws.childrenMu.Lock()
+	defer kws.childrenMu.Unlock()
+	if kws.children[string(child.namespace)] == child {
+		delete(kws.children, string(child.namespace))
+	}
+}
+
+// detachChildren empties the child set and returns its former members.
+func (kws *Websocket) detachChildren() []*Websocket {
+	kws.childrenMu.Lock()
+	defer kws.childrenMu.Unlock()
+	out := make([]*Websocket, 0, len(kws.children))
+	for _, c := range kws.children {
+		out = append(out, c)
+	}
+	kws.children = nil
+	return out
+}
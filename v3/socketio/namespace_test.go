@@ -0,0 +1,293 @@
+package socketio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// readText reads the next non-PING text frame within 3 seconds.
+func readText(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, msg, err := sioReadSkipPings(conn)
+	require.NoError(t, err)
+	return string(msg)
+}
+
+// connectNamespace sends a namespace CONNECT and returns the sid of the
+// acked socket.
+func connectNamespace(t *testing.T, conn *websocket.Conn, ns string) string {
+	t.Helper()
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("40"+ns+",")))
+	ack := readText(t, conn)
+	require.True(t, strings.HasPrefix(ack, "40"+ns+","), "got %q", ack)
+	var body struct {
+		SID string `json:"sid"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(ack, "40"+ns+",")), &body))
+	require.NotEmpty(t, body.SID)
+	return body.SID
+}
+
+func receiveSocket(t *testing.T, ch <-chan *Websocket) *Websocket {
+	t.Helper()
+	select {
+	case kws := <-ch:
+		return kws
+	case <-time.After(3 * time.Second):
+		t.Fatal("socket not delivered")
+		return nil
+	}
+}
+
+func TestOfNormalizesNames(t *testing.T) {
+	resetSIOGlobals(t)
+
+	require.Same(t, Of("/"), Of(""))
+	require.Same(t, Of("/admin"), Of("admin"))
+	require.Equal(t, "/admin", Of("admin").Name())
+	require.Panics(t, func() { Of("/bad,name") })
+}
+
+// TestNamespacesMultiplexed connects "/" and "/admin" over one WebSocket
+// and checks listener scoping and the separate ack-id spaces.
+func TestNamespacesMultiplexed(t *testing.T) {
+	resetSIOGlobals(t)
+
+	adminCh := make(chan *Websocket, 1)
+	Of("/admin").OnConnect(func(kws *Websocket) { adminCh <- kws })
+
+	type hit struct{ scope, namespace string }
+	hits := make(chan hit, 8)
+	On("ping", func(ep *EventPayload) { hits <- hit{"global", ep.Namespace} })
+	Of("/").On("ping", func(ep *EventPayload) { hits <- hit{"root", ep.Namespace} })
+	Of("/admin").On("ping", func(ep *EventPayload) { hits <- hit{"admin", ep.Namespace} })
+
+	rootCh := make(chan *Websocket, 1)
+	ln, teardown := newSIOTestServer(t, func(kws *Websocket) { rootCh <- kws })
+	defer teardown()
+	conn := dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, sioHandshake(t, conn))
+	root := receiveSocket(t, rootCh)
+
+	sid := connectNamespace(t, conn, "/admin")
+	admin := receiveSocket(t, adminCh)
+	require.Equal(t, sid, admin.GetUUID())
+	require.NotEqual(t, root.GetUUID(), admin.GetUUID())
+	require.Len(t, pool.all(), 2)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`42/admin,["ping"]`)))
+	require.Equal(t, hit{"global", "/admin"}, <-hits)
+	require.Equal(t, hit{"admin", "/admin"}, <-hits)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`42["ping"]`)))
+	require.Equal(t, hit{"global", "/"}, <-hits)
+	require.Equal(t, hit{"root", "/"}, <-hits)
+
+	// Both sockets hand out ack id 1; each ack resolves its own namespace.
+	rootAck := make(chan [][]byte, 1)
+	adminAck := make(chan [][]byte, 1)
+	root.EmitWithAckArgs("q", nil, func(args [][]byte, _ error) { rootAck <- args })
+	require.Equal(t, `421["q"]`, readText(t, conn))
+	admin.EmitWithAckArgs("q", nil, func(args [][]byte, _ error) { adminAck <- args })
+	require.Equal(t, `42/admin,1["q"]`, readText(t, conn))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`43/admin,1["a"]`)))
+	require.Equal(t, [][]byte{[]byte(`"a"`)}, <-adminAck)
+	require.Empty(t, rootAck)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`431["r"]`)))
+	require.Equal(t, [][]byte{[]byte(`"r"`)}, <-rootAck)
+
+	// Namespace broadcasts only reach that namespace.
+	require.NoError(t, Of("/admin").Emit("news", []byte(`1`)))
+	require.Equal(t, `42/admin,["news",1]`, readText(t, conn))
+	require.NoError(t, Of("/").Emit("news", []byte(`2`)))
+	require.Equal(t, `42["news",2]`, readText(t, conn))
+}
+
+func TestNamespaceMiddlewareAndRegistry(t *testing.T) {
+	resetSIOGlobals(t)
+
+	Of("/admin").Use(func(kws *Websocket, next func(error)) {
+		var auth struct {
+			Token string `json:"token"`
+		}
+		_ = json.Unmarshal(kws.HandshakeAuth(), &auth)
+		if auth.Token != "secret" {
+			next(errors.New("unauthorized"))
+			return
+		}
+		// next may be called asynchronously.
+		go next(nil)
+	})
+	connected := make(chan string, 1)
+	Of("/admin").OnConnect(func(kws *Websocket) { connected <- string(kws.HandshakeAuth()) })
+
+	ln, teardown := newSIOTestServer(t, func(_ *Websocket) {})
+	defer teardown()
+	conn := dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, sioHandshake(t, conn))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`40/admin,{"token":"nope"}`)))
+	require.Equal(t, `44/admin,{"message":"unauthorized"}`, readText(t, conn))
+
+	// Once a namespace is registered, unknown namespaces are refused.
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`40/other,`)))
+	require.Equal(t, `44/other,{"message":"Invalid namespace"}`, readText(t, conn))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`40/admin,{"token":"secret"}`)))
+	require.True(t, strings.HasPrefix(readText(t, conn), `40/admin,{"sid":`))
+	require.Equal(t, `{"token":"secret"}`, <-connected)
+	require.Len(t, pool.all(), 2)
+}
+
+// TestNamespaceMiddlewareRejectsFirstConnect checks that a rejected
+// first CONNECT ends the handshake.
+func TestNamespaceMiddlewareRejectsFirstConnect(t *testing.T) {
+	resetSIOGlobals(t)
+
+	Of("/").Use(func(_ *Websocket, next func(error)) { next(errors.New("go away")) })
+	called := make(chan struct{}, 1)
+	ln, teardown := newSIOTestServer(t, func(_ *Websocket) { called <- struct{}{} })
+	defer teardown()
+	conn := dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+
+	_, _, err := conn.ReadMessage() // EIO OPEN
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("40")))
+	require.Equal(t, `44{"message":"go away"}`, readText(t, conn))
+	require.Empty(t, called)
+}
+
+func TestNamespaceDisconnect(t *testing.T) {
+	resetSIOGlobals(t)
+
+	disc := make(chan string, 4)
+	On(EventDisconnect, func(ep *EventPayload) { disc <- ep.Namespace })
+	adminCh := make(chan *Websocket, 2)
+	Of("/admin").OnConnect(func(kws *Websocket) { adminCh <- kws })
+
+	rootCh := make(chan *Websocket, 1)
+	ln, teardown := newSIOTestServer(t, func(kws *Websocket) { rootCh <- kws })
+	defer teardown()
+	conn := dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, sioHandshake(t, conn))
+	root := receiveSocket(t, rootCh)
+
+	// Client leaves "/admin": only the child goes away.
+	connectNamespace(t, conn, "/admin")
+	admin := receiveSocket(t, adminCh)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`41/admin,`)))
+	require.Equal(t, "/admin", <-disc)
+	require.False(t, admin.IsAlive())
+	require.True(t, root.IsAlive())
+	require.Nil(t, root.namespaceSocket([]byte("/admin")))
+
+	// Server closes "/admin": a namespaced DISCONNECT, transport stays.
+	connectNamespace(t, conn, "/admin")
+	admin = receiveSocket(t, adminCh)
+	admin.Close()
+	require.Equal(t, `41/admin,`, readText(t, conn))
+	require.Equal(t, "/admin", <-disc)
+	require.True(t, root.IsAlive())
+
+	// Closing the transport takes every namespace with it.
+	connectNamespace(t, conn, "/admin")
+	admin = receiveSocket(t, adminCh)
+	root.Close()
+	require.ElementsMatch(t, []string{"/admin", "/"}, []string{<-disc, <-disc})
+	require.False(t, admin.IsAlive())
+	require.Empty(t, pool.all())
+}
+
+// TestPollingNamespacesMultiplexed batches two namespace CONNECTs into
+// one polling POST and routes an event to the second namespace.
+func TestPollingNamespacesMultiplexed(t *testing.T) {
+	resetSIOGlobals(t)
+
+	got := make(chan string, 1)
+	Of("/chat").On("say", func(ep *EventPayload) {
+		got <- ep.Namespace
+		ep.Kws.EmitArgs("said", ep.Args...)
+	})
+
+	_, c, td := newPollingTestServer(t, func(_ *Websocket) {})
+	defer td()
+
+	sid, _, _ := pollOpen(t, c)
+	_, _ = pollPost(t, c, sid, []byte("40\x1e40/chat,"))
+	body, _ := pollGet(t, c, sid)
+	frames := strings.Split(string(body), "\x1e")
+	require.Len(t, frames, 2)
+	require.True(t, strings.HasPrefix(frames[0], `40{"sid":`), frames[0])
+	require.True(t, strings.HasPrefix(frames[1], `40/chat,{"sid":`), frames[1])
+
+	_, _ = pollPost(t, c, sid, []byte(`42/chat,["say","hi"]`))
+	require.Equal(t, "/chat", <-got)
+	body, _ = pollGet(t, c, sid)
+	require.Equal(t, `42/chat,["said","hi"]`, string(body))
+}
+
+func TestConnectErrorMessage(t *testing.T) {
+	require.JSONEq(t, `{"message":"nope"}`, connectErrorMessage(errors.New("nope")))
+	require.JSONEq(t, `{"message":"auth: banned","data":{"until":"tomorrow"}}`,
+		connectErrorMessage(fmt.Errorf("auth: %w", &ConnectError{Message: "banned", Data: map[string]string{"until": "tomorrow"}})))
+	require.JSONEq(t, `{"message":"auth: banned"}`,
+		connectErrorMessage(fmt.Errorf("auth: %w", &ConnectError{Message: "banned", Data: func() {}})))
+}
+
+// TestUseRejectsBeforeCallback checks that package-level Use guards the
+// root namespace: the chain sees the auth payload, a rejection carries
+// its data and the New callback never runs.
+func TestUseRejectsBeforeCallback(t *testing.T) {
+	resetSIOGlobals(t)
+
+	order := make(chan string, 4)
+	Use(func(kws *Websocket, next func(error)) {
+		order <- "first"
+		kws.SetAttribute("checked", true)
+		next(nil)
+	})
+	Use(func(kws *Websocket, next func(error)) {
+		order <- "second"
+		var auth struct {
+			Token string `json:"token"`
+		}
+		_ = json.Unmarshal(kws.HandshakeAuth(), &auth)
+		if auth.Token == "" {
+			next(&ConnectError{Message: "unauthorized", Data: map[string]int{"code": 401}})
+			return
+		}
+		next(nil)
+	})
+	connected := make(chan bool, 1)
+	ln, teardown := newSIOTestServer(t, func(kws *Websocket) {
+		connected <- kws.GetAttribute("checked") == true
+	})
+	defer teardown()
+
+	conn := dialSIO(t, ln)
+	_, _, err := conn.ReadMessage() // EIO OPEN
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("40")))
+	require.Equal(t, `44{"message":"unauthorized","data":{"code":401}}`, readText(t, conn))
+	require.Equal(t, "first", <-order)
+	require.Equal(t, "second", <-order)
+	require.Empty(t, connected)
+	_ = conn.Close()
+
+	conn = dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+	sioHandshakeWithConnect(t, conn, `{"token":"t"}`)
+	require.True(t, <-connected)
+}
@@ -0,0 +1,160 @@
+// Package otelmetrics provides a socketio.MetricsCollector that records
+// the socketio internals with OpenTelemetry metric instruments.
+//
+//	collector, err := otelmetrics.New(otelmetrics.Config{MeterProvider: provider})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	socketio.SetMetricsCollector(collector)
+package otelmetrics
+
+import (
+	"context"
+
+	"github.com/gofiber/contrib/v3/socketio"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ScopeName is the instrumentation scope of the meter.
+const ScopeName = "github.com/gofiber/contrib/v3/socketio"
+
+// Instrument names.
+const (
+	MetricConnections        = "socketio.connections"
+	MetricHandshakeFailures  = "socketio.handshake.failures"
+	MetricEventsReceived     = "socketio.events.received"
+	MetricEventsSent         = "socketio.events.sent"
+	MetricAckTimeouts        = "socketio.ack.timeouts"
+	MetricSendQueueOverflows = "socketio.send_queue.overflows"
+	MetricPollingSessions    = "socketio.polling.sessions"
+)
+
+// Config controls the collector.
+type Config struct {
+	// MeterProvider creates the meter the instruments belong to.
+	//
+	// Optional. Default: otel.GetMeterProvider()
+	MeterProvider metric.MeterProvider
+}
+
+// Collector is a socketio.MetricsCollector backed by OpenTelemetry
+// instruments. Connections and polling sessions are up-down counters;
+// everything else is a counter. Attributes are "socketio.transport",
+// "socketio.reason", "socketio.event" and, on overflows,
+// "socketio.action" ("drop" or "disconnect"). The MetricsCollector
+// methods carry no context, so measurements are recorded against
+// context.Background().
+type Collector struct {
+	connections       metric.Int64UpDownCounter
+	handshakeFailures metric.Int64Counter
+	eventsReceived    metric.Int64Counter
+	eventsSent        metric.Int64Counter
+	ackTimeouts       metric.Int64Counter
+	overflows         metric.Int64Counter
+	pollingSessions   metric.Int64UpDownCounter
+}
+
+var _ socketio.MetricsCollector = (*Collector)(nil)
+
+// New creates a Collector, or returns the error of the first instrument
+// that could not be created.
+func New(config ...Config) (*Collector, error) {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.MeterProvider == nil {
+		cfg.MeterProvider = otel.GetMeterProvider()
+	}
+	meter := cfg.MeterProvider.Meter(ScopeName)
+
+	c := &Collector{}
+	var err error
+	if c.connections, err = meter.Int64UpDownCounter(MetricConnections,
+		metric.WithUnit("{connection}"), metric.WithDescription("Open Socket.IO connections.")); err != nil {
+		return nil, err
+	}
+	if c.handshakeFailures, err = meter.Int64Counter(MetricHandshakeFailures,
+		metric.WithUnit("{handshake}"), metric.WithDescription("Refused or failed Socket.IO handshakes.")); err != nil {
+		return nil, err
+	}
+	if c.eventsReceived, err = meter.Int64Counter(MetricEventsReceived,
+		metric.WithUnit("{event}"), metric.WithDescription("Events received from clients.")); err != nil {
+		return nil, err
+	}
+	if c.eventsSent, err = meter.Int64Counter(MetricEventsSent,
+		metric.WithUnit("{event}"), metric.WithDescription("Events emitted to clients, counted per recipient.")); err != nil {
+		return nil, err
+	}
+	if c.ackTimeouts, err = meter.Int64Counter(MetricAckTimeouts,
+		metric.WithUnit("{ack}"), metric.WithDescription("EmitWithAck callbacks that timed out.")); err != nil {
+		return nil, err
+	}
+	if c.overflows, err = meter.Int64Counter(MetricSendQueueOverflows,
+		metric.WithUnit("{frame}"), metric.WithDescription("Frames that did not fit a send queue.")); err != nil {
+		return nil, err
+	}
+	if c.pollingSessions, err = meter.Int64UpDownCounter(MetricPollingSessions,
+		metric.WithUnit("{session}"), metric.WithDescription("Open Engine.IO polling sessions.")); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func transport(name string) metric.MeasurementOption {
+	return metric.WithAttributes(attribute.String("socketio.transport", name))
+}
+
+// ConnectionOpened implements socketio.MetricsCollector.
+func (c *Collector) ConnectionOpened(name string) {
+	c.connections.Add(context.Background(), 1, transport(name))
+}
+
+// ConnectionClosed implements socketio.MetricsCollector.
+func (c *Collector) ConnectionClosed(name string) {
+	c.connections.Add(context.Background(), -1, transport(name))
+}
+
+// HandshakeFailed implements socketio.MetricsCollector.
+func (c *Collector) HandshakeFailed(reason string) {
+	c.handshakeFailures.Add(context.Background(), 1, metric.WithAttributes(attribute.String("socketio.reason", reason)))
+}
+
+// EventReceived implements socketio.MetricsCollector.
+func (c *Collector) EventReceived(event string) {
+	c.eventsReceived.Add(context.Background(), 1, metric.WithAttributes(attribute.String("socketio.event", event)))
+}
+
+// EventSent implements socketio.MetricsCollector.
+func (c *Collector) EventSent(event string) {
+	c.eventsSent.Add(context.Background(), 1, metric.WithAttributes(attribute.String("socketio.event", event)))
+}
+
+// AckTimedOut implements socketio.MetricsCollector.
+func (c *Collector) AckTimedOut() {
+	c.ackTimeouts.Add(context.Background(), 1)
+}
+
+// SendQueueOverflow implements socketio.MetricsCollector.
+func (c *Collector) SendQueueOverflow(name string, dropped bool) {
+	action := "disconnect"
+	if dropped {
+		action = "drop"
+	}
+	c.overflows.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("socketio.transport", name),
+		attribute.String("socketio.action", action),
+	))
+}
+
+// PollingSessionOpened implements socketio.MetricsCollector.
+func (c *Collector) PollingSessionOpened() {
+	c.pollingSessions.Add(context.Background(), 1)
+}
+
+// PollingSessionClosed implements socketio.MetricsCollector.
+func (c *Collector) PollingSessionClosed() {
+	c.pollingSessions.Add(context.Background(), -1)
+}
@@ -0,0 +1,63 @@
+package otelmetrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gofiber/contrib/v3/socketio"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func Test_Collector_Records(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	c, err := New(Config{MeterProvider: provider})
+	require.NoError(t, err)
+
+	c.ConnectionOpened(socketio.TransportWebSocket)
+	c.ConnectionOpened(socketio.TransportWebSocket)
+	c.ConnectionClosed(socketio.TransportWebSocket)
+	c.HandshakeFailed("namespace_rejected")
+	c.EventReceived("chat")
+	c.EventSent("chat")
+	c.AckTimedOut()
+	c.AckTimedOut()
+	c.SendQueueOverflow(socketio.TransportPolling, false)
+	c.PollingSessionOpened()
+	c.PollingSessionClosed()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Equal(t, ScopeName, rm.ScopeMetrics[0].Scope.Name)
+
+	sums := make(map[string]metricdata.Sum[int64])
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		sums[m.Name] = m.Data.(metricdata.Sum[int64])
+	}
+	value := func(name string, attrs ...attribute.KeyValue) int64 {
+		t.Helper()
+		want := attribute.NewSet(attrs...)
+		for _, dp := range sums[name].DataPoints {
+			if dp.Attributes.Equals(&want) {
+				return dp.Value
+			}
+		}
+		t.Fatalf("no %s data point with %v", name, attrs)
+		return 0
+	}
+
+	require.Equal(t, int64(1), value(MetricConnections, attribute.String("socketio.transport", "websocket")))
+	require.Equal(t, int64(1), value(MetricHandshakeFailures, attribute.String("socketio.reason", "namespace_rejected")))
+	require.Equal(t, int64(1), value(MetricEventsReceived, attribute.String("socketio.event", "chat")))
+	require.Equal(t, int64(1), value(MetricEventsSent, attribute.String("socketio.event", "chat")))
+	require.Equal(t, int64(2), value(MetricAckTimeouts))
+	require.Equal(t, int64(1), value(MetricSendQueueOverflows,
+		attribute.String("socketio.transport", "polling"), attribute.String("socketio.action", "disconnect")))
+	require.Equal(t, int64(0), value(MetricPollingSessions))
+	require.False(t, sums[MetricConnections].IsMonotonic)
+	require.True(t, sums[MetricAckTimeouts].IsMonotonic)
+}
@@ -55,6 +55,35 @@ var MaxPollWait = 30 * time.Second
 // bound. Set to zero to disable the cap (not recommended).
 var PollQueueMaxFrames = 1024
 
+// pollSessionRegistry indexes live polling sessions by Engine.IO sid. The
+// sid starts out equal to the session UUID but, unlike the pool key, does
+// not follow SetUUID or connection state recovery, so the client keeps
+// reaching its session.
+type pollSessionRegistry struct {
+	sync.RWMutex
+	m map[string]*Websocket
+}
+
+var pollSessions = &pollSessionRegistry{m: make(map[string]*Websocket)}
+
+func (r *pollSessionRegistry) set(sid string, kws *Websocket) {
+	r.Lock()
+	r.m[sid] = kws
+	r.Unlock()
+}
+
+func (r *pollSessionRegistry) get(sid string) *Websocket {
+	r.RLock()
+	defer r.RUnlock()
+	return r.m[sid]
+}
+
+func (r *pollSessionRegistry) delete(sid string) {
+	r.Lock()
+	delete(r.m, sid)
+	r.Unlock()
+}
+
 // pollQueue is the per-session outbound buffer for HTTP long-polling.
 // Frames enqueued by any write-path goroutine (Emit, pong heartbeat, late
 // SIO CONNECT ack, Close) are drained by the long-poll GET handler in
@@ -108,22 +137,24 @@ const (
 	enqueueRejectedDisconnect
 )
 
-// enqueue appends a frame to the buffer. Caller transfers ownership of
-// frame; callers must not mutate it after the call. The return value
-// instructs the caller how to react to the queue's overflow policy.
-func (q *pollQueue) enqueue(frame []byte) enqueueResult {
+// enqueue appends frames to the buffer as one unit: either all of them
+// are buffered or none is, so a binary packet header is never separated
+// from its attachments. Caller transfers ownership of the frames; callers
+// must not mutate them after the call. The return value instructs the
+// caller how to react to the queue's overflow policy.
+func (q *pollQueue) enqueue(frames ...[]byte) enqueueResult {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 	if q.closed {
 		return enqueueOK
 	}
-	if PollQueueMaxFrames > 0 && len(q.frames) >= PollQueueMaxFrames {
+	if PollQueueMaxFrames > 0 && len(q.frames)+len(frames) > PollQueueMaxFrames {
 		if DropFramesOnOverflow {
 			return enqueueDroppedQueueFull
 		}
 		return enqueueRejectedDisconnect
 	}
-	q.frames = append(q.frames, frame)
+	q.frames = append(q.frames, frames...)
 	q.signalLocked()
 	return enqueueOK
 }
@@ -285,15 +316,14 @@ func handlePolling(c fiber.Ctx, callback func(kws *Websocket)) (handled bool, er
 	}
 
 	// Existing session lookup.
-	kwsAny, lookupErr := pool.get(sid)
-	if lookupErr != nil {
+	kws := pollSessions.get(sid)
+	if kws == nil {
+		if _, err := pool.get(sid); err == nil {
+			// sid resolves to a non-polling session: transport mismatch.
+			return true, writePollingError(c, 3)
+		}
 		return true, writePollingError(c, 1)
 	}
-	kws, ok := kwsAny.(*Websocket)
-	if !ok || kws.pollQ == nil {
-		// sid resolves to a non-polling session: transport mismatch.
-		return true, writePollingError(c, 3)
-	}
 
 	switch method {
 	case fiber.MethodGet:
@@ -314,6 +344,7 @@ func openPollingSession(c fiber.Ctx, callback func(kws *Websocket)) error {
 		queue: make(chan message, SendQueueSize),
 		done:  make(chan struct{}, 1),
 		pollQ: newPollQueue(),
+		eio3:  isEIO3Request(c.Query("EIO")),
 	}
 	kws.UUID = kws.createUUID()
 	kws.isAlive.Store(true)
@@ -373,9 +404,12 @@ func openPollingSession(c fiber.Ctx, callback func(kws *Websocket)) error {
 	kws.Cookies = newLookupFunc(cookiesSnap)
 	kws.pollCallback = callback
 
+	kws.engineSID = kws.UUID
 	pool.set(kws)
+	pollSessions.set(kws.engineSID, kws)
+	metrics().PollingSessionOpened()
 
-	frame, err := buildEIOOpenFrame(kws.UUID)
+	frame, err := kws.openFrame()
 	if err != nil {
 		kws.disconnected(err)
 		return writePollingError(c, 3)
@@ -407,11 +441,20 @@ func openPollingSession(c fiber.Ctx, callback func(kws *Websocket)) error {
 	if HandshakeTimeout > 0 {
 		kws.handshakeTimer.Store(time.AfterFunc(HandshakeTimeout, func() {
 			if !kws.connectFired.Load() {
+				metrics().HandshakeFailed("handshake_timeout")
 				kws.disconnected(ErrHandshakeClosed)
 			}
 		}))
 	}
 
+	if kws.eio3 {
+		// Socket.IO v2: the client is connected to "/" without sending
+		// a CONNECT. The ack (or CONNECT_ERROR) is delivered by the
+		// next poll.
+		kws.handleSIOPacket([]byte{sioConnect})
+		frame = encodeEIO3PollingFrames([][]byte{frame})
+	}
+
 	c.Set(fiber.HeaderContentType, "text/plain; charset=UTF-8")
 	c.Set(fiber.HeaderCacheControl, "no-store")
 	return c.Send(frame)
@@ -443,7 +486,12 @@ func drainPolling(c fiber.Ctx, kws *Websocket) error {
 	// either drains new frames or blocks on its own context.
 	kws.pollGate.Store(false)
 
-	body := encodePollingFrames(frames)
+	var body []byte
+	if kws.eio3 {
+		body = encodeEIO3PollingFrames(frames)
+	} else {
+		body = encodePollingFrames(frames)
+	}
 
 	c.Set(fiber.HeaderContentType, "text/plain; charset=UTF-8")
 	c.Set(fiber.HeaderCacheControl, "no-store")
@@ -548,55 +596,79 @@ func ingestPolling(c fiber.Ctx, kws *Websocket) error {
 	// socketio.go:1960.
 	kws.lastPongNanos.Store(time.Now().UnixNano())
 
-	rest := body
 	count := 0
-	for len(rest) > 0 {
+	// ingest dispatches one packet and reports whether to go on with the
+	// rest of the body.
+	ingest := func(packet []byte, binary bool) bool {
 		if count >= MaxBatchPackets {
 			kws.fireEvent(EventError, nil, ErrBatchPacketsExceeded)
-			break
+			return false
 		}
-		idx := bytes.IndexByte(rest, eioPacketSeparator)
-		var packet []byte
-		if idx < 0 {
-			packet, rest = rest, nil
-		} else {
-			packet, rest = rest[:idx], rest[idx+1:]
-		}
-		if len(packet) == 0 {
-			continue
-		}
-		// Binary packet encoding: "b" prefix + base64 payload. Decode
-		// inline and surface as an EventMessage with the raw bytes,
-		// matching the WebSocket BinaryMessage path in read(). The
-		// decoded slice owns its memory; safe to surface to listeners
-		// even after fasthttp recycles the request body.
-		if packet[0] == 'b' {
+		if binary {
+			// Binary data: hand it to the pending binary packet as an
+			// attachment, or surface it as an EventMessage with the
+			// raw bytes, matching the WebSocket BinaryMessage path in
+			// read().
 			if !kws.connectFired.Load() {
 				kws.fireEvent(EventError, packet, ErrPollingBeforeConnect)
 				kws.disconnected(ErrPollingBeforeConnect)
-				break
+				return false
 			}
-			decoded := make([]byte, base64.StdEncoding.DecodedLen(len(packet)-1))
-			n, decErr := base64.StdEncoding.Decode(decoded, packet[1:])
-			if decErr != nil {
-				kws.fireEvent(EventError, packet, decErr)
+			if !kws.addAttachment(packet) {
+				kws.fireEvent(EventMessage, packet, nil)
+			}
+		} else {
+			// packet is a sub-slice of the body buffer we already
+			// copied at the top of this function; safe to hand to the
+			// dispatcher (and through it to listener callbacks) without
+			// an additional per-packet allocation.
+			kws.dispatchEIOPacket(packet)
+		}
+		count++
+		return kws.IsAlive()
+	}
+
+	if kws.eio3 {
+		if err := forEachEIO3Packet(body, ingest); err != nil {
+			kws.fireEvent(EventError, nil, err)
+		}
+	} else {
+		rest := body
+		for len(rest) > 0 {
+			idx := bytes.IndexByte(rest, eioPacketSeparator)
+			var packet []byte
+			if idx < 0 {
+				packet, rest = rest, nil
+			} else {
+				packet, rest = rest[:idx], rest[idx+1:]
+			}
+			if len(packet) == 0 {
 				continue
 			}
-			kws.fireEvent(EventMessage, decoded[:n], nil)
-			count++
-			if !kws.IsAlive() {
+			// Binary packet encoding: "b" prefix + base64 payload. The
+			// decoded slice owns its memory; safe to surface to
+			// listeners even after fasthttp recycles the request body.
+			if packet[0] == 'b' {
+				if !kws.connectFired.Load() {
+					kws.fireEvent(EventError, packet, ErrPollingBeforeConnect)
+					kws.disconnected(ErrPollingBeforeConnect)
+					break
+				}
+				decoded := make([]byte, base64.StdEncoding.DecodedLen(len(packet)-1))
+				n, decErr := base64.StdEncoding.Decode(decoded, packet[1:])
+				if decErr != nil {
+					kws.fireEvent(EventError, packet, decErr)
+					continue
+				}
+				packet = decoded[:n]
+				if !ingest(packet, true) {
+					break
+				}
+				continue
+			}
+			if !ingest(packet, false) {
 				break
 			}
-			continue
-		}
-		// packet is a sub-slice of the body buffer we already copied
-		// at the top of this function; safe to hand to the dispatcher
-		// (and through it to listener callbacks) without an
-		// additional per-packet allocation.
-		kws.dispatchEIOPacket(packet)
-		count++
-		if !kws.IsAlive() {
-			break
 		}
 	}
 
@@ -0,0 +1,172 @@
+// Package prometheusmetrics provides a socketio.MetricsCollector that
+// exports the socketio internals as Prometheus metrics.
+//
+//	socketio.SetMetricsCollector(prometheusmetrics.New())
+//	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+package prometheusmetrics
+
+import (
+	"github.com/gofiber/contrib/v3/socketio"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Config controls the collector.
+type Config struct {
+	// Namespace prefixes every metric name.
+	//
+	// Optional. Default: "socketio"
+	Namespace string
+
+	// Subsystem prefixes every metric name after Namespace.
+	//
+	// Optional. Default: ""
+	Subsystem string
+
+	// Registerer the metrics are registered with. New panics if they
+	// are already registered there; use a distinct Namespace or
+	// Subsystem for a second collector.
+	//
+	// Optional. Default: prometheus.DefaultRegisterer
+	Registerer prometheus.Registerer
+}
+
+// ConfigDefault is the default config.
+var ConfigDefault = Config{
+	Namespace:  "socketio",
+	Registerer: prometheus.DefaultRegisterer,
+}
+
+// Collector is a socketio.MetricsCollector backed by Prometheus metrics:
+//
+//	socketio_connections{transport}                        gauge
+//	socketio_handshake_failures_total{reason}              counter
+//	socketio_events_received_total{event}                  counter
+//	socketio_events_sent_total{event}                      counter
+//	socketio_ack_timeouts_total                            counter
+//	socketio_send_queue_overflows_total{transport,action}  counter
+//	socketio_polling_sessions                              gauge
+//
+// action is "drop" or "disconnect", after DropFramesOnOverflow.
+type Collector struct {
+	connections       *prometheus.GaugeVec
+	handshakeFailures *prometheus.CounterVec
+	eventsReceived    *prometheus.CounterVec
+	eventsSent        *prometheus.CounterVec
+	ackTimeouts       prometheus.Counter
+	overflows         *prometheus.CounterVec
+	pollingSessions   prometheus.Gauge
+}
+
+var _ socketio.MetricsCollector = (*Collector)(nil)
+
+// New creates a Collector and registers its metrics.
+func New(config ...Config) *Collector {
+	cfg := configDefault(config...)
+	name := func(n string) string {
+		return prometheus.BuildFQName(cfg.Namespace, cfg.Subsystem, n)
+	}
+
+	c := &Collector{
+		connections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: name("connections"),
+			Help: "Open Socket.IO connections.",
+		}, []string{"transport"}),
+		handshakeFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: name("handshake_failures_total"),
+			Help: "Refused or failed Socket.IO handshakes.",
+		}, []string{"reason"}),
+		eventsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: name("events_received_total"),
+			Help: "Events received from clients.",
+		}, []string{"event"}),
+		eventsSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: name("events_sent_total"),
+			Help: "Events emitted to clients, counted per recipient.",
+		}, []string{"event"}),
+		ackTimeouts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: name("ack_timeouts_total"),
+			Help: "EmitWithAck callbacks that timed out.",
+		}),
+		overflows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: name("send_queue_overflows_total"),
+			Help: "Frames that did not fit a send queue.",
+		}, []string{"transport", "action"}),
+		pollingSessions: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: name("polling_sessions"),
+			Help: "Open Engine.IO polling sessions.",
+		}),
+	}
+	cfg.Registerer.MustRegister(
+		c.connections,
+		c.handshakeFailures,
+		c.eventsReceived,
+		c.eventsSent,
+		c.ackTimeouts,
+		c.overflows,
+		c.pollingSessions,
+	)
+	return c
+}
+
+func configDefault(config ...Config) Config {
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+	cfg := config[0]
+	if cfg.Namespace == "" {
+		cfg.Namespace = ConfigDefault.Namespace
+	}
+	if cfg.Registerer == nil {
+		cfg.Registerer = ConfigDefault.Registerer
+	}
+	return cfg
+}
+
+// ConnectionOpened implements socketio.MetricsCollector.
+func (c *Collector) ConnectionOpened(transport string) {
+	c.connections.WithLabelValues(transport).Inc()
+}
+
+// ConnectionClosed implements socketio.MetricsCollector.
+func (c *Collector) ConnectionClosed(transport string) {
+	c.connections.WithLabelValues(transport).Dec()
+}
+
+// HandshakeFailed implements socketio.MetricsCollector.
+func (c *Collector) HandshakeFailed(reason string) {
+	c.handshakeFailures.WithLabelValues(reason).Inc()
+}
+
+// EventReceived implements socketio.MetricsCollector.
+func (c *Collector) EventReceived(event string) {
+	c.eventsReceived.WithLabelValues(event).Inc()
+}
+
+// EventSent implements socketio.MetricsCollector.
+func (c *Collector) EventSent(event string) {
+	c.eventsSent.WithLabelValues(event).Inc()
+}
+
+// AckTimedOut implements socketio.MetricsCollector.
+func (c *Collector) AckTimedOut() {
+	c.ackTimeouts.Inc()
+}
+
+// SendQueueOverflow implements socketio.MetricsCollector.
+func (c *Collector) SendQueueOverflow(transport string, dropped bool) {
+	action := "disconnect"
+	if dropped {
+		action = "drop"
+	}
+	c.overflows.WithLabelValues(transport, action).Inc()
+}
+
+// PollingSessionOpened implements socketio.MetricsCollector.
+func (c *Collector) PollingSessionOpened() {
+	c.pollingSessions.Inc()
+}
+
+// PollingSessionClosed implements socketio.MetricsCollector.
+func (c *Collector) PollingSessionClosed() {
+	c.pollingSessions.Dec()
+}
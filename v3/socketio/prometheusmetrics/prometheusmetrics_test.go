@@ -0,0 +1,65 @@
+package prometheusmetrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gofiber/contrib/v3/socketio"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Collector_Records(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	c := New(Config{Registerer: registry})
+
+	c.ConnectionOpened(socketio.TransportWebSocket)
+	c.ConnectionOpened(socketio.TransportWebSocket)
+	c.ConnectionClosed(socketio.TransportWebSocket)
+	c.ConnectionOpened(socketio.TransportPolling)
+	c.HandshakeFailed("unknown_namespace")
+	c.EventReceived("chat")
+	c.EventSent("chat")
+	c.EventSent("chat")
+	c.AckTimedOut()
+	c.SendQueueOverflow(socketio.TransportWebSocket, true)
+	c.PollingSessionOpened()
+
+	require.InDelta(t, 1, testutil.ToFloat64(c.connections.WithLabelValues("websocket")), 0)
+	require.InDelta(t, 1, testutil.ToFloat64(c.connections.WithLabelValues("polling")), 0)
+	require.InDelta(t, 2, testutil.ToFloat64(c.eventsSent.WithLabelValues("chat")), 0)
+	require.InDelta(t, 1, testutil.ToFloat64(c.overflows.WithLabelValues("websocket", "drop")), 0)
+
+	expected := `
+# HELP socketio_ack_timeouts_total EmitWithAck callbacks that timed out.
+# TYPE socketio_ack_timeouts_total counter
+socketio_ack_timeouts_total 1
+# HELP socketio_handshake_failures_total Refused or failed Socket.IO handshakes.
+# TYPE socketio_handshake_failures_total counter
+socketio_handshake_failures_total{reason="unknown_namespace"} 1
+# HELP socketio_polling_sessions Open Engine.IO polling sessions.
+# TYPE socketio_polling_sessions gauge
+socketio_polling_sessions 1
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"socketio_ack_timeouts_total", "socketio_handshake_failures_total", "socketio_polling_sessions"))
+}
+
+func Test_New_NamespaceAndSubsystem(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	New(Config{Registerer: registry, Namespace: "app", Subsystem: "ws"})
+	// A second collector with other names registers alongside.
+	New(Config{Registerer: registry})
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	names := make([]string, 0, len(families))
+	for _, f := range families {
+		names = append(names, f.GetName())
+	}
+	require.Contains(t, names, "app_ws_polling_sessions")
+	require.Contains(t, names, "socketio_polling_sessions")
+
+	require.Panics(t, func() { New(Config{Registerer: registry}) })
+}
@@ -0,0 +1,298 @@
+package socketio
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRateLimited is surfaced via EventError (and, with
+// RateLimitDisconnect, as the EventDisconnect error) when an inbound
+// event exceeds its rate limit.
+var ErrRateLimited = errors.New("socketio: event rate limit exceeded")
+
+// AllEvents is the event name that makes a rate limit apply to every
+// inbound event, on top of any limit set for the event's own name.
+const AllEvents = "*"
+
+// RateLimitPolicy is what happens to an inbound event that exceeds its
+// rate limit.
+type RateLimitPolicy int
+
+const (
+	// RateLimitDrop discards the event without telling anyone but the
+	// Logger hook.
+	RateLimitDrop RateLimitPolicy = iota
+	// RateLimitError discards the event and fires EventError with an
+	// error wrapping ErrRateLimited.
+	RateLimitError
+	// RateLimitDisconnect fires EventError like RateLimitError, then
+	// closes the connection. EventDisconnect listeners receive the
+	// same error.
+	RateLimitDisconnect
+)
+
+func (p RateLimitPolicy) String() string {
+	switch p {
+	case RateLimitDrop:
+		return "drop"
+	case RateLimitError:
+		return "error"
+	case RateLimitDisconnect:
+		return "disconnect"
+	default:
+		return "unknown"
+	}
+}
+
+// RateLimit is a token bucket for inbound events: it holds up to Burst
+// tokens, refills at Rate tokens per second and every event takes one.
+// A Burst below 1 is treated as 1. A Rate of 0 or less removes the
+// limit.
+type RateLimit struct {
+	Rate   float64
+	Burst  int
+	Policy RateLimitPolicy
+}
+
+// LimitEvents sets the per-connection rate limit of event, or of every
+// event when event is AllEvents. Each Engine.IO connection gets its own
+// buckets, shared by the namespaces multiplexed on it. Only client
+// EVENT and BINARY_EVENT packets are limited; acks, heartbeats and
+// lifecycle packets are not.
+//
+// An event is checked against its own per-connection limit, then the
+// AllEvents per-connection limit, then the global limits (see
+// LimitEventsGlobal), and the first bucket that is empty decides the
+// policy. A rejected event takes no token from any bucket. Call it during startup; buckets of open connections pick a
+// changed limit up on their next event.
+func LimitEvents(event string, limit RateLimit) {
+	rateLimits.set(false, event, limit)
+}
+
+// LimitEventsGlobal is LimitEvents with one bucket for all connections
+// of this process, to cap the total rate an event is handled at.
+func LimitEventsGlobal(event string, limit RateLimit) {
+	rateLimits.set(true, event, limit)
+}
+
+// tokenBucket is one RateLimit bucket. limited counts the events it has
+// rejected and burst those of the current run of rejections, so a flood
+// is logged once when it starts and once when it ends rather than once
+// per event.
+type tokenBucket struct {
+	mu      sync.Mutex
+	limit   RateLimit
+	tokens  float64
+	last    time.Time
+	limited uint64
+	burst   uint64
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	b := &tokenBucket{}
+	b.reset(limit, now)
+	return b
+}
+
+func (b *tokenBucket) reset(limit RateLimit, now time.Time) {
+	b.limit = limit
+	b.tokens = float64(limit.Burst)
+	b.last = now
+}
+
+// take consumes a token if one is available. When it is not, it returns
+// the number of events rejected so far and whether this rejection
+// starts a new burst. When it is, limited is the number of events
+// rejected by the burst this token ends, or 0.
+func (b *tokenBucket) take(now time.Time) (ok bool, limited uint64, first bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.limit.Rate
+		if burst := float64(b.limit.Burst); b.tokens > burst {
+			b.tokens = burst
+		}
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		limited, b.burst = b.burst, 0
+		return true, limited, false
+	}
+	b.limited++
+	b.burst++
+	return false, b.limited, b.burst == 1
+}
+
+// refund returns a token taken for an event another bucket rejected.
+func (b *tokenBucket) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if burst := float64(b.limit.Burst); b.tokens+1 > burst {
+		b.tokens = burst
+		return
+	}
+	b.tokens++
+}
+
+// rateLimitConfig is an immutable snapshot of the configured limits.
+// global holds the process-wide buckets, created with the snapshot.
+type rateLimitConfig struct {
+	conn   map[string]RateLimit
+	global map[string]*tokenBucket
+}
+
+// rateLimitRegistry is the copy-on-write store behind LimitEvents.
+type rateLimitRegistry struct {
+	writeMu sync.Mutex
+	cfg     atomic.Pointer[rateLimitConfig]
+}
+
+var rateLimits = func() *rateLimitRegistry {
+	r := &rateLimitRegistry{}
+	r.cfg.Store(&rateLimitConfig{})
+	return r
+}()
+
+func (r *rateLimitRegistry) set(global bool, event string, limit RateLimit) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	cur := r.cfg.Load()
+	next := &rateLimitConfig{
+		conn:   make(map[string]RateLimit, len(cur.conn)+1),
+		global: make(map[string]*tokenBucket, len(cur.global)+1),
+	}
+	for k, v := range cur.conn {
+		next.conn[k] = v
+	}
+	for k, v := range cur.global {
+		next.global[k] = v
+	}
+	switch {
+	case limit.Rate <= 0 && global:
+		delete(next.global, event)
+	case limit.Rate <= 0:
+		delete(next.conn, event)
+	case global:
+		next.global[event] = newTokenBucket(limit, time.Now())
+	default:
+		next.conn[event] = limit
+	}
+	r.cfg.Store(next)
+}
+
+//nolint:unused
+func (r *rateLimitRegistry) reset() {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.cfg.Store(&rateLimitConfig{})
+}
+
+// connBucket returns the connection's bucket for the limit of key,
+// creating it on first use and resetting it when the limit changed.
+func (kws *Websocket) connBucket(key string, limit RateLimit, now time.Time) *tokenBucket {
+	kws.rateMu.Lock()
+	defer kws.rateMu.Unlock()
+	b := kws.rateBuckets[key]
+	if b == nil {
+		if kws.rateBuckets == nil {
+			kws.rateBuckets = make(map[string]*tokenBucket)
+		}
+		b = newTokenBucket(limit, now)
+		kws.rateBuckets[key] = b
+		return b
+	}
+	b.mu.Lock()
+	if b.limit != limit {
+		b.reset(limit, now)
+	}
+	b.mu.Unlock()
+	return b
+}
+
+// allowEvent applies the configured rate limits to an inbound event and
+// carries out the policy of the limit it exceeds. It reports whether the
+// event may be dispatched.
+func (kws *Websocket) allowEvent(event string, payload []byte) bool {
+	cfg := rateLimits.cfg.Load()
+	if len(cfg.conn) == 0 && len(cfg.global) == 0 {
+		return true
+	}
+	owner := kws
+	if kws.parent != nil {
+		owner = kws.parent
+	}
+	keys := []string{event, AllEvents}
+	if event == AllEvents {
+		keys = keys[:1]
+	}
+	now := time.Now()
+	// Tokens are taken bucket by bucket and given back when a later
+	// bucket rejects the event, so a throttled client does not drain
+	// the buckets checked before the one that throttles it.
+	var taken []*tokenBucket
+	refund := func() {
+		for _, b := range taken {
+			b.refund()
+		}
+	}
+	for _, key := range keys {
+		if limit, ok := cfg.conn[key]; ok {
+			b := owner.connBucket(key, limit, now)
+			if !kws.takeToken(b, limit.Policy, "connection", event, payload, now) {
+				refund()
+				return false
+			}
+			taken = append(taken, b)
+		}
+	}
+	for _, key := range keys {
+		// Global buckets are never reset, so their limit is read
+		// without the bucket lock.
+		if b := cfg.global[key]; b != nil {
+			if !kws.takeToken(b, b.limit.Policy, "global", event, payload, now) {
+				refund()
+				return false
+			}
+			taken = append(taken, b)
+		}
+	}
+	return true
+}
+
+// takeToken takes a token from b. When the bucket is empty it logs the
+// start of the burst and applies policy; the first token after a burst
+// logs how many events the burst rejected.
+func (kws *Websocket) takeToken(b *tokenBucket, policy RateLimitPolicy, scope, event string, payload []byte, now time.Time) bool {
+	ok, limited, first := b.take(now)
+	if ok {
+		if limited > 0 {
+			logf("warn", "rate_limit_recovered", "uuid", kws.GetUUID(), "event", event, "scope", scope,
+				"policy", policy.String(), "limited", limited)
+		}
+		return true
+	}
+	if first {
+		logf("warn", "rate_limited", "uuid", kws.GetUUID(), "event", event, "scope", scope,
+			"policy", policy.String(), "limited", limited)
+	}
+	if policy == RateLimitDrop {
+		return false
+	}
+	err := fmt.Errorf("%w: %s limit for %q", ErrRateLimited, scope, event)
+	kws.fireEvent(EventError, payload, err)
+	if policy == RateLimitDisconnect {
+		owner := kws
+		if kws.parent != nil {
+			owner = kws.parent
+		}
+		owner.closeWithError(err)
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package socketio
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(RateLimit{Rate: 2, Burst: 2}, now)
+
+	for i := 0; i < 2; i++ {
+		ok, _, _ := b.take(now)
+		require.True(t, ok)
+	}
+	ok, limited, first := b.take(now)
+	require.False(t, ok)
+	require.Equal(t, uint64(1), limited)
+	require.True(t, first)
+	ok, limited, first = b.take(now)
+	require.False(t, ok)
+	require.Equal(t, uint64(2), limited)
+	require.False(t, first)
+
+	// Half a second refills one token at 2/s; a long pause refills up
+	// to Burst only.
+	// The token that ends a burst reports what it rejected.
+	ok, limited, _ = b.take(now.Add(500 * time.Millisecond))
+	require.True(t, ok)
+	require.Equal(t, uint64(2), limited)
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		ok, _, _ = b.take(now)
+		require.True(t, ok)
+	}
+	ok, limited, first = b.take(now)
+	require.False(t, ok)
+	require.Equal(t, uint64(3), limited)
+	require.True(t, first)
+
+	// A refund never exceeds Burst.
+	b.refund()
+	b.refund()
+	b.refund()
+	require.InDelta(t, 2, b.tokens, 0.01)
+}
+
+// TestRateLimitRejectionTakesNoToken checks that an event rejected by a
+// later bucket leaves the earlier ones untouched, and that the end of a
+// burst is logged with its count.
+func TestRateLimitRejectionTakesNoToken(t *testing.T) {
+	resetSIOGlobals(t)
+	var logMu sync.Mutex
+	var recovered [][]any
+	Logger = func(_, msg string, fields ...any) {
+		if msg == "rate_limit_recovered" {
+			logMu.Lock()
+			recovered = append(recovered, fields)
+			logMu.Unlock()
+		}
+	}
+	t.Cleanup(func() { Logger = nil })
+
+	LimitEvents("chat", RateLimit{Rate: 0.001, Burst: 3, Policy: RateLimitDrop})
+	LimitEvents(AllEvents, RateLimit{Rate: 20, Burst: 1, Policy: RateLimitDrop})
+
+	kws := &Websocket{}
+	require.True(t, kws.allowEvent("chat", nil))
+	require.False(t, kws.allowEvent("chat", nil))
+	require.False(t, kws.allowEvent("chat", nil))
+	require.InDelta(t, 2, kws.rateBuckets["chat"].tokens, 0.01)
+
+	// AllEvents refills a token every 50ms.
+	time.Sleep(60 * time.Millisecond)
+	require.True(t, kws.allowEvent("chat", nil))
+	require.InDelta(t, 1, kws.rateBuckets["chat"].tokens, 0.01)
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	require.Len(t, recovered, 1)
+	require.Contains(t, recovered[0], "chat")
+	require.Contains(t, recovered[0], uint64(2))
+}
+
+// TestRateLimitPolicies floods a connection and checks each policy.
+func TestRateLimitPolicies(t *testing.T) {
+	resetSIOGlobals(t)
+	var logMu sync.Mutex
+	var logged [][]any
+	Logger = func(_, msg string, fields ...any) {
+		if msg == "rate_limited" {
+			logMu.Lock()
+			logged = append(logged, fields)
+			logMu.Unlock()
+		}
+	}
+	t.Cleanup(func() { Logger = nil })
+
+	LimitEvents("chat", RateLimit{Rate: 0.001, Burst: 2, Policy: RateLimitDrop})
+	LimitEvents("move", RateLimit{Rate: 0.001, Burst: 1, Policy: RateLimitError})
+	LimitEventsGlobal("vote", RateLimit{Rate: 0.001, Burst: 1, Policy: RateLimitDisconnect})
+
+	events := make(chan string, 16)
+	for _, name := range []string{"chat", "move", "vote", "ping"} {
+		On(name, func(ep *EventPayload) { events <- ep.Name })
+	}
+	errs := make(chan error, 4)
+	On(EventError, func(ep *EventPayload) { errs <- ep.Error })
+	disc := make(chan error, 1)
+	On(EventDisconnect, func(ep *EventPayload) { disc <- ep.Error })
+
+	ln, teardown := newSIOTestServer(t, func(_ *Websocket) {})
+	defer teardown()
+	conn := dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, sioHandshake(t, conn))
+
+	send := func(event string) {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`42["`+event+`"]`)))
+	}
+	next := func() string {
+		select {
+		case name := <-events:
+			return name
+		case <-time.After(3 * time.Second):
+			t.Fatal("event not dispatched")
+			return ""
+		}
+	}
+
+	// Events are handled in order, so the "ping" after each flood shows
+	// which of the flood got through.
+	for i := 0; i < 4; i++ {
+		send("chat")
+	}
+	send("ping")
+	require.Equal(t, []string{"chat", "chat", "ping"}, []string{next(), next(), next()})
+
+	send("move")
+	send("move")
+	send("ping")
+	require.Equal(t, []string{"move", "ping"}, []string{next(), next()})
+	require.ErrorIs(t, <-errs, ErrRateLimited)
+
+	send("vote")
+	require.Equal(t, "vote", next())
+	send("vote")
+	select {
+	case err := <-disc:
+		require.ErrorIs(t, err, ErrRateLimited)
+	case <-time.After(3 * time.Second):
+		t.Fatal("connection not closed")
+	}
+	require.ErrorIs(t, <-errs, ErrRateLimited)
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	// One entry per burst: chat, move and vote.
+	require.Len(t, logged, 3)
+	require.Contains(t, logged[0], "chat")
+	require.Contains(t, logged[0], uint64(1))
+}
@@ -0,0 +1,384 @@
+package socketio
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Connection state recovery (socket.io v4 "connectionStateRecovery").
+//
+// When RecoveryWindow is positive every session is given a private id
+// (pid), sent in the CONNECT ack next to the sid, and every event it is
+// sent carries an offset as its last argument. When the connection drops
+// for any reason other than an explicit disconnect (client "41" or server
+// Close), the session is parked for RecoveryWindow: its UUID, attributes,
+// rooms and the last RecoveryBufferSize events are kept, and broadcasts
+// addressed to it keep being buffered. A client that reconnects within
+// the window with {"pid":..., "offset":...} in its CONNECT auth gets the
+// parked state back and is sent every event after offset.
+var (
+	// RecoveryWindow is how long a dropped session stays recoverable.
+	// Zero (the default) disables connection state recovery: no pid is
+	// issued and no offsets are added to events. The socket.io server
+	// default is 2 * time.Minute.
+	RecoveryWindow time.Duration
+	// RecoveryBufferSize caps the events buffered per session for
+	// replay. A client whose offset has already been evicted cannot
+	// recover and starts a fresh session. Zero or negative disables the
+	// cap.
+	RecoveryBufferSize = 256
+)
+
+// recoveryBuffer numbers the recoverable events of one session and keeps
+// the most recent ones for replay.
+type recoveryBuffer struct {
+	pid string
+
+	mu  sync.Mutex
+	seq uint64
+	// packets holds at most RecoveryBufferSize events, oldest first,
+	// with consecutive offsets ending at seq.
+	packets []bufferedPacket
+}
+
+type bufferedPacket struct {
+	offset uint64
+	msg    message
+}
+
+func newRecoveryBuffer() *recoveryBuffer {
+	return &recoveryBuffer{pid: uuid.New().String()}
+}
+
+// record tags msg with the next offset, buffers it and returns the tagged
+// copy.
+func (b *recoveryBuffer) record(msg message) message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	msg.data = appendOffset(msg.data, b.seq)
+	if RecoveryBufferSize > 0 && len(b.packets) >= RecoveryBufferSize {
+		n := copy(b.packets, b.packets[len(b.packets)-RecoveryBufferSize+1:])
+		b.packets = b.packets[:n]
+	}
+	b.packets = append(b.packets, bufferedPacket{offset: b.seq, msg: msg})
+	return msg
+}
+
+// since returns the buffered events after offset. ok is false when offset
+// is unknown or older than the buffer, i.e. some events in between are
+// lost. An empty offset means the client has not received any event yet.
+func (b *recoveryBuffer) since(offset string) (missed []message, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var after uint64
+	if offset != "" {
+		n, err := strconv.ParseUint(offset, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		after = n
+	}
+	// The buffer covers offsets (seq-len, seq].
+	oldest := b.seq - uint64(len(b.packets))
+	if after > b.seq || after < oldest {
+		return nil, false
+	}
+	for _, p := range b.packets[after-oldest:] {
+		missed = append(missed, p.msg)
+	}
+	return missed, true
+}
+
+// appendOffset adds offset as the last argument of an event frame ending
+// in ']'.
+func appendOffset(frame []byte, offset uint64) []byte {
+	out := make([]byte, 0, len(frame)+24)
+	out = append(out, frame[:len(frame)-1]...)
+	out = append(out, ',', '"')
+	out = strconv.AppendUint(out, offset, 10)
+	return append(out, '"', ']')
+}
+
+// isRecoverable reports whether msg is an EVENT or BINARY_EVENT without
+// an ack id. Events that expect an ack are not buffered: their callback
+// has already failed with ErrAckDisconnected by the time a replay could
+// happen.
+func isRecoverable(msg message) bool {
+	d := msg.data
+	if msg.mType != TextMessage || len(d) < 4 || d[0] != eioMessage || d[len(d)-1] != ']' {
+		return false
+	}
+	i := 2
+	switch d[1] {
+	case sioEvent:
+	case sioBinaryEvent:
+		for i < len(d) && d[i] >= '0' && d[i] <= '9' {
+			i++
+		}
+		if i >= len(d) || d[i] != '-' {
+			return false
+		}
+		i++
+	default:
+		return false
+	}
+	if i < len(d) && d[i] == '/' {
+		for i < len(d) && d[i] != ',' {
+			i++
+		}
+		i++
+	}
+	return i < len(d) && d[i] == '['
+}
+
+// parkedSession is the state of a dropped session kept for RecoveryWindow.
+type parkedSession struct {
+	uuid       string
+	namespace  string
+	attributes map[string]interface{}
+	rooms      []string
+	buf        *recoveryBuffer
+	timer      *time.Timer
+}
+
+// parked indexes parked sessions by pid.
+var parked = struct {
+	sync.Mutex
+	m map[string]*parkedSession
+}{m: make(map[string]*parkedSession)}
+
+// parkState snapshots the state of a dropped session for recovery, or
+// returns nil when the session is not recoverable. disconnected calls it
+// while the session is still in its rooms and hands the result to park
+// once the session has left the pool, so a quick reconnect cannot take
+// the UUID back before the old connection is gone.
+func (kws *Websocket) parkState() *parkedSession {
+	buf := kws.recovery.Load()
+	if buf == nil || kws.noRecovery.Load() || RecoveryWindow <= 0 {
+		return nil
+	}
+	kws.mu.RLock()
+	s := &parkedSession{
+		uuid:       kws.UUID,
+		namespace:  nsName(kws.namespace),
+		attributes: make(map[string]interface{}, len(kws.attributes)),
+		buf:        buf,
+	}
+	for k, v := range kws.attributes {
+		s.attributes[k] = v
+	}
+	kws.mu.RUnlock()
+	s.rooms = adapter().Rooms(s.uuid)
+	return s
+}
+
+// recoverable reports whether kws is a dropped session that will be
+// parked but is not yet. From the disconnect until park, it is neither
+// alive nor seen by captureParked.
+func (kws *Websocket) recoverable() bool {
+	return kws.recovery.Load() != nil && RecoveryWindow > 0 &&
+		!kws.noRecovery.Load() && !kws.parked.Load()
+}
+
+// bufferDropped records msg for replay when kws is recoverable, so
+// events sent between the disconnect and park are not lost. An event
+// racing park may be buffered twice, once here and once by
+// captureParked.
+func (kws *Websocket) bufferDropped(msg message) {
+	if kws.recoverable() && isRecoverable(msg) {
+		kws.recovery.Load().record(msg)
+	}
+}
+
+// park keeps s for RecoveryWindow.
+func park(s *parkedSession) {
+	pid := s.buf.pid
+	parked.Lock()
+	defer parked.Unlock()
+	if old := parked.m[pid]; old != nil {
+		old.timer.Stop()
+	}
+	s.timer = time.AfterFunc(RecoveryWindow, func() {
+		parked.Lock()
+		defer parked.Unlock()
+		if parked.m[pid] == s {
+			delete(parked.m, pid)
+		}
+	})
+	parked.m[pid] = s
+}
+
+// unpark removes and returns the parked session for pid, or nil.
+func unpark(pid string) *parkedSession {
+	parked.Lock()
+	defer parked.Unlock()
+	s := parked.m[pid]
+	if s == nil {
+		return nil
+	}
+	s.timer.Stop()
+	delete(parked.m, pid)
+	return s
+}
+
+// captureParked buffers an event for every parked session match selects,
+// so it is replayed if the client comes back. frame builds the event for
+// the session's namespace.
+func captureParked(match func(s *parkedSession) bool, frame func(ns []byte) []byte) {
+	parked.Lock()
+	defer parked.Unlock()
+	for _, s := range parked.m {
+		if !match(s) {
+			continue
+		}
+		var ns []byte
+		if s.namespace != "/" {
+			ns = []byte(s.namespace)
+		}
+		s.buf.record(message{mType: TextMessage, data: frame(ns)})
+	}
+}
+
+// captureParkedMessage buffers an Emit-style message for every parked
+// session except exceptUUID (or only for the sessions in uuids when it is
+// non-empty) and reports whether any session took it. Binary frames are
+// not recoverable.
+func captureParkedMessage(uuids []string, exceptUUID string, data []byte, mType ...int) bool {
+	if RecoveryWindow <= 0 || (len(mType) > 0 && mType[0] != TextMessage) {
+		return false
+	}
+	hit := false
+	captureParked(func(s *parkedSession) bool {
+		if s.uuid == exceptUUID {
+			return false
+		}
+		if len(uuids) > 0 && !contains(uuids, s.uuid) {
+			return false
+		}
+		hit = true
+		return true
+	}, func(ns []byte) []byte {
+		return buildSIOEvent(ns, EventMessage, data)
+	})
+	return hit
+}
+
+// parkedMatch reports whether a parked session is selected by the
+// operator, using the rooms it had when it was parked.
+func (b *BroadcastOperator) parkedMatch(s *parkedSession) bool {
+	if (b.nsp != "" && s.namespace != b.nsp) || s.uuid == b.exceptUUID {
+		return false
+	}
+	inAny := func(names []string) bool {
+		if contains(names, s.uuid) {
+			return true
+		}
+		for _, r := range s.rooms {
+			if contains(names, r) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(b.rooms) > 0 && !inAny(b.rooms) {
+		return false
+	}
+	return !inAny(b.except)
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// recoveryAuth is the part of the CONNECT auth payload the socket.io
+// client adds when it tries to recover a session.
+type recoveryAuth struct {
+	PID    string `json:"pid"`
+	Offset string `json:"offset"`
+}
+
+// recoverSession restores the parked session named in the CONNECT auth
+// payload, if any and if none of its events were evicted, and returns
+// the events to replay. On success kws has the parked UUID, attributes
+// and rooms, continues the parked offsets and reports Recovered.
+// Otherwise, when recovery is enabled, kws gets a fresh pid.
+func (kws *Websocket) recoverSession() (missed []message) {
+	if RecoveryWindow <= 0 || kws.eio3 {
+		return nil
+	}
+	missed, ok := kws.restore()
+	if !ok {
+		kws.recovery.Store(newRecoveryBuffer())
+	}
+	return missed
+}
+
+func (kws *Websocket) restore() ([]message, bool) {
+	var auth recoveryAuth
+	if json.Unmarshal(kws.HandshakeAuth(), &auth) != nil || auth.PID == "" {
+		return nil, false
+	}
+	s := unpark(auth.PID)
+	if s == nil {
+		logf("warn", "recovery_miss", "uuid", kws.UUID, "reason", "unknown or expired pid")
+		return nil, false
+	}
+	if s.namespace != nsName(kws.getNamespace()) {
+		logf("warn", "recovery_miss", "uuid", kws.UUID, "reason", "namespace mismatch")
+		return nil, false
+	}
+	missed, ok := s.buf.since(auth.Offset)
+	if !ok {
+		logf("warn", "recovery_miss", "uuid", kws.UUID, "reason", "offset not buffered")
+		return nil, false
+	}
+	if err := kws.SetUUID(s.uuid); err != nil {
+		logf("warn", "recovery_miss", "uuid", kws.UUID, "reason", err.Error())
+		return nil, false
+	}
+	kws.mu.Lock()
+	kws.attributes = s.attributes
+	kws.mu.Unlock()
+	if len(s.rooms) > 0 {
+		kws.Join(s.rooms...)
+	}
+	kws.recovery.Store(s.buf)
+	kws.recovered.Store(true)
+	return missed, true
+}
+
+// Recovered reports whether this session was restored by connection
+// state recovery: it kept the UUID, attributes and rooms of a previous
+// connection, and the events it missed were replayed right after the
+// CONNECT ack. Always false unless RecoveryWindow is set.
+func (kws *Websocket) Recovered() bool {
+	return kws.recovered.Load()
+}
+
+// connectAckPayload encodes the CONNECT ack body: the sid, plus the pid
+// when connection state recovery is on for this session. Socket.IO v2
+// acks are empty.
+func (kws *Websocket) connectAckPayload(sid string) []byte {
+	if kws.eio3 {
+		return nil
+	}
+	ack := struct {
+		SID string `json:"sid"`
+		PID string `json:"pid,omitempty"`
+	}{SID: sid}
+	if buf := kws.recovery.Load(); buf != nil {
+		ack.PID = buf.pid
+	}
+	b, _ := json.Marshal(ack)
+	return b
+}
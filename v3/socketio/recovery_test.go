@@ -0,0 +1,252 @@
+package socketio
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// enableRecovery turns connection state recovery on for one test and
+// drops any session it leaves parked. Call it before resetSIOGlobals so
+// its cleanup runs after the test's connections are gone.
+func enableRecovery(t *testing.T, window time.Duration) {
+	t.Helper()
+	prev := RecoveryWindow
+	RecoveryWindow = window
+	t.Cleanup(func() {
+		RecoveryWindow = prev
+		parked.Lock()
+		for pid, s := range parked.m {
+			s.timer.Stop()
+			delete(parked.m, pid)
+		}
+		parked.Unlock()
+	})
+}
+
+type connectAck struct {
+	SID string `json:"sid"`
+	PID string `json:"pid"`
+}
+
+func parseConnectAck(t *testing.T, frame []byte) connectAck {
+	t.Helper()
+	var ack connectAck
+	require.NoError(t, json.Unmarshal(frame[2:], &ack), "ack %q", frame)
+	return ack
+}
+
+func TestRecoveryBuffer(t *testing.T) {
+	prev := RecoveryBufferSize
+	RecoveryBufferSize = 3
+	defer func() { RecoveryBufferSize = prev }()
+
+	b := newRecoveryBuffer()
+	require.NotEmpty(t, b.pid)
+	for i := 1; i <= 5; i++ {
+		n := strconv.Itoa(i)
+		msg := b.record(message{mType: TextMessage, data: []byte(`42["n",` + n + `]`)})
+		require.Equal(t, `42["n",`+n+`,"`+n+`"]`, string(msg.data))
+	}
+
+	missed, ok := b.since("3")
+	require.True(t, ok)
+	require.Len(t, missed, 2)
+	require.Equal(t, `42["n",4,"4"]`, string(missed[0].data))
+
+	missed, ok = b.since("5")
+	require.True(t, ok)
+	require.Empty(t, missed)
+
+	// Offsets 1 and 2 were evicted, and 6 was never sent.
+	for _, offset := range []string{"", "1", "6", "x"} {
+		_, ok = b.since(offset)
+		require.False(t, ok, offset)
+	}
+}
+
+func TestIsRecoverable(t *testing.T) {
+	for frame, want := range map[string]bool{
+		`42["a"]`:         true,
+		`42/admin,["a"]`:  true,
+		`451-["a",{}]`:    true,
+		`421["a"]`:        false,
+		`42/admin,7["a"]`: false,
+		`43["a"]`:         false,
+		`2`:               false,
+		`40{"sid":"x"}`:   false,
+	} {
+		require.Equal(t, want, isRecoverable(message{mType: TextMessage, data: []byte(frame)}), frame)
+	}
+	require.False(t, isRecoverable(message{mType: BinaryMessage, data: []byte(`42["a"]`)}))
+}
+
+// TestRecoverySessionRestored drops a WebSocket mid-session, broadcasts
+// while it is gone and reconnects with the pid and last offset.
+func TestRecoverySessionRestored(t *testing.T) {
+	enableRecovery(t, time.Minute)
+	resetSIOGlobals(t)
+
+	disc := make(chan struct{}, 2)
+	On(EventDisconnect, func(_ *EventPayload) { disc <- struct{}{} })
+	kwsCh := make(chan *Websocket, 2)
+	recovered := make(chan bool, 2)
+	ln, teardown := newSIOTestServer(t, func(kws *Websocket) {
+		recovered <- kws.Recovered()
+		kwsCh <- kws
+	})
+	defer teardown()
+
+	conn := dialSIO(t, ln)
+	first := parseConnectAck(t, sioHandshakeWithConnect(t, conn, ""))
+	require.NotEmpty(t, first.PID)
+	require.False(t, <-recovered)
+	kws := receiveSocket(t, kwsCh)
+	kws.SetAttribute("user", "ann")
+	kws.Join("lobby")
+
+	kws.EmitArgs("hello", []byte(`1`))
+	require.Equal(t, `42["hello",1,"1"]`, readText(t, conn))
+	// Events that expect an ack carry no offset.
+	kws.EmitWithAckArgs("q", nil, func([][]byte, error) {})
+	require.Equal(t, `421["q"]`, readText(t, conn))
+
+	// The transport drops without a DISCONNECT packet.
+	_ = conn.Close()
+	select {
+	case <-disc:
+	case <-time.After(3 * time.Second):
+		t.Fatal("disconnect not detected")
+	}
+
+	require.NoError(t, To("lobby").Emit("news", []byte(`2`)))
+	require.NoError(t, To("elsewhere").Emit("news", []byte(`3`)))
+	require.NoError(t, EmitTo(first.SID, []byte(`"direct"`)))
+
+	conn = dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+	second := parseConnectAck(t, sioHandshakeWithConnect(t, conn, `{"pid":"`+first.PID+`","offset":"1"}`))
+	require.Equal(t, first, second)
+	require.Equal(t, `42["news",2,"2"]`, readText(t, conn))
+	require.Equal(t, `42["message","direct","3"]`, readText(t, conn))
+
+	require.True(t, <-recovered)
+	kws = receiveSocket(t, kwsCh)
+	require.Equal(t, first.SID, kws.GetUUID())
+	require.Equal(t, "ann", kws.GetStringAttribute("user"))
+	require.Equal(t, []string{"lobby"}, adapter().Rooms(first.SID))
+
+	kws.EmitArgs("again")
+	require.Equal(t, `42["again","4"]`, readText(t, conn))
+	kws.Close()
+}
+
+// closeHookMetrics runs onClose from ConnectionClosed, which fires after
+// a dropped socket is marked dead and before it is parked.
+type closeHookMetrics struct {
+	*recordingMetrics
+	once    sync.Once
+	onClose func()
+}
+
+func (m *closeHookMetrics) ConnectionClosed(string) { m.once.Do(m.onClose) }
+
+func TestRecoveryBuffersBeforePark(t *testing.T) {
+	enableRecovery(t, time.Minute)
+	resetSIOGlobals(t)
+
+	kwsCh := make(chan *Websocket, 2)
+	ln, teardown := newSIOTestServer(t, func(kws *Websocket) { kwsCh <- kws })
+	defer teardown()
+
+	conn := dialSIO(t, ln)
+	first := parseConnectAck(t, sioHandshakeWithConnect(t, conn, ""))
+	receiveSocket(t, kwsCh).Join("lobby")
+
+	sent := make(chan struct{})
+	SetMetricsCollector(&closeHookMetrics{recordingMetrics: &recordingMetrics{}, onClose: func() {
+		defer close(sent)
+		require.NoError(t, To("lobby").Emit("news", []byte(`1`)))
+		Broadcast([]byte(`"all"`))
+		require.NoError(t, EmitTo(first.SID, []byte(`"direct"`)))
+	}})
+	t.Cleanup(func() { SetMetricsCollector(nil) })
+
+	_ = conn.Close()
+	select {
+	case <-sent:
+	case <-time.After(3 * time.Second):
+		t.Fatal("disconnect not detected")
+	}
+
+	conn = dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+	second := parseConnectAck(t, sioHandshakeWithConnect(t, conn, `{"pid":"`+first.PID+`","offset":""}`))
+	require.Equal(t, first, second)
+	require.Equal(t, `42["news",1,"1"]`, readText(t, conn))
+	require.Equal(t, `42["message","all","2"]`, readText(t, conn))
+	require.Equal(t, `42["message","direct","3"]`, readText(t, conn))
+	receiveSocket(t, kwsCh).Close()
+}
+
+func TestRecoveryMisses(t *testing.T) {
+	enableRecovery(t, time.Minute)
+	resetSIOGlobals(t)
+
+	kwsCh := make(chan *Websocket, 2)
+	ln, teardown := newSIOTestServer(t, func(kws *Websocket) { kwsCh <- kws })
+	defer teardown()
+
+	// An unknown pid gets a fresh session.
+	conn := dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+	ack := parseConnectAck(t, sioHandshakeWithConnect(t, conn, `{"pid":"nope","offset":"0"}`))
+	require.NotEqual(t, "nope", ack.PID)
+	kws := receiveSocket(t, kwsCh)
+	require.False(t, kws.Recovered())
+
+	// A server Close is final: nothing is parked.
+	kws.Close()
+	parked.Lock()
+	require.Empty(t, parked.m)
+	parked.Unlock()
+}
+
+// TestPollingRecovery restores a polling session; the client keeps
+// talking to its new Engine.IO sid while the socket id is restored.
+func TestPollingRecovery(t *testing.T) {
+	enableRecovery(t, time.Minute)
+	resetSIOGlobals(t)
+
+	kwsCh := make(chan *Websocket, 2)
+	_, c, td := newPollingTestServer(t, func(kws *Websocket) { kwsCh <- kws })
+	defer td()
+
+	sid, _, _ := pollOpen(t, c)
+	_, _ = pollPost(t, c, sid, []byte("40"))
+	body, _ := pollGet(t, c, sid)
+	first := parseConnectAck(t, body)
+	kws := receiveSocket(t, kwsCh)
+	kws.disconnected(ErrHandshakeClosed) // transport lost
+
+	require.NoError(t, To().Emit("news", []byte(`1`)))
+
+	sid, _, _ = pollOpen(t, c)
+	_, _ = pollPost(t, c, sid, []byte(`40{"pid":"`+first.PID+`"}`))
+	body, _ = pollGet(t, c, sid)
+	frames := strings.Split(string(body), "\x1e")
+	require.Len(t, frames, 2)
+	require.Equal(t, first, parseConnectAck(t, []byte(frames[0])))
+	require.Equal(t, `42["news",1,"1"]`, frames[1])
+
+	kws = receiveSocket(t, kwsCh)
+	require.True(t, kws.Recovered())
+	kws.EmitArgs("live")
+	body, _ = pollGet(t, c, sid)
+	require.Equal(t, `42["live","2"]`, string(body))
+}
@@ -0,0 +1,188 @@
+// Package redisadapter provides a socketio.Adapter that fans broadcasts
+// out to every server instance over Redis pub/sub.
+//
+// It is built on a github.com/gofiber/storage/redis/v3 store and shares
+// its connection:
+//
+//	store := redis.New(redis.Config{URL: "redis://localhost:6379"})
+//	adapter := redisadapter.New(redisadapter.Config{Store: store})
+//	if err := socketio.SetAdapter(adapter); err != nil {
+//		log.Fatal(err)
+//	}
+//	defer adapter.Close()
+package redisadapter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/v3/socketio"
+	fiberredis "github.com/gofiber/storage/redis/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+// Config controls the Redis adapter.
+type Config struct {
+	// Store is the Fiber Redis storage whose connection is used for
+	// PUBLISH and SUBSCRIBE, so the adapter reuses the application's
+	// existing pool. The caller owns the storage lifecycle and should
+	// close it after the adapter.
+	//
+	// Required.
+	Store *fiberredis.Storage
+
+	// Channel is the pub/sub channel shared by every node of the
+	// deployment. Use a distinct channel per independent socketio
+	// deployment sharing the same Redis.
+	//
+	// Optional. Default: "fiber:socketio"
+	Channel string
+
+	// PublishTimeout bounds a single PUBLISH round trip.
+	//
+	// Optional. Default: 5 * time.Second
+	PublishTimeout time.Duration
+}
+
+// ConfigDefault is the default config.
+var ConfigDefault = Config{
+	Channel:        "fiber:socketio",
+	PublishTimeout: 5 * time.Second,
+}
+
+// ErrStoreRequired is returned by Publish and Subscribe when Config.Store
+// is nil.
+var ErrStoreRequired = errors.New("socketio redis adapter: store is required")
+
+// nodeIDLen is the length of the hex node id prefixed to every message.
+const nodeIDLen = 16
+
+// redisClient is the subset of go-redis the adapter uses.
+type redisClient interface {
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// Adapter is a socketio.Adapter backed by Redis pub/sub. Room membership
+// is tracked per node by the embedded socketio.MemoryAdapter; only
+// broadcast packets travel through Redis.
+type Adapter struct {
+	*socketio.MemoryAdapter
+
+	config Config
+	client redisClient
+	// nodeID prefixes every published message so a node can skip its own
+	// packets when Redis echoes them back.
+	nodeID string
+
+	mu     sync.Mutex
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+// New creates a Redis adapter. Install it with socketio.SetAdapter, which
+// opens the subscription.
+func New(config ...Config) *Adapter {
+	cfg := configDefault(config...)
+	a := &Adapter{
+		MemoryAdapter: socketio.NewMemoryAdapter(),
+		config:        cfg,
+		nodeID:        newNodeID(),
+	}
+	if cfg.Store != nil {
+		a.client = cfg.Store.Conn()
+	}
+	return a
+}
+
+func configDefault(config ...Config) Config {
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+	cfg := config[0]
+	if cfg.Channel == "" {
+		cfg.Channel = ConfigDefault.Channel
+	}
+	if cfg.PublishTimeout <= 0 {
+		cfg.PublishTimeout = ConfigDefault.PublishTimeout
+	}
+	return cfg
+}
+
+func newNodeID() string {
+	var b [nodeIDLen / 2]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Publish implements socketio.Adapter.
+func (a *Adapter) Publish(packet []byte) error {
+	if a.client == nil {
+		return ErrStoreRequired
+	}
+	msg := make([]byte, 0, nodeIDLen+len(packet))
+	msg = append(msg, a.nodeID...)
+	msg = append(msg, packet...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.config.PublishTimeout)
+	defer cancel()
+	return a.client.Publish(ctx, a.config.Channel, msg).Err()
+}
+
+// Subscribe implements socketio.Adapter. It blocks until Redis confirms
+// the subscription, then hands every packet published by another node
+// to handler from a single background goroutine, preserving order.
+func (a *Adapter) Subscribe(handler func(packet []byte)) error {
+	if a.client == nil {
+		return ErrStoreRequired
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.pubsub != nil {
+		return errors.New("socketio redis adapter: already subscribed")
+	}
+
+	ctx := context.Background()
+	pubsub := a.client.Subscribe(ctx, a.config.Channel)
+	// Receive waits for the SUBSCRIBE confirmation so packets published
+	// right after SetAdapter returns are not missed.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return err
+	}
+	a.pubsub = pubsub
+	a.done = make(chan struct{})
+
+	ch := pubsub.Channel()
+	go func() {
+		defer close(a.done)
+		for msg := range ch {
+			payload := msg.Payload
+			if len(payload) < nodeIDLen || payload[:nodeIDLen] == a.nodeID {
+				continue
+			}
+			handler([]byte(payload[nodeIDLen:]))
+		}
+	}()
+	return nil
+}
+
+// Close implements socketio.Adapter. It ends the subscription and waits
+// for the delivery goroutine to exit. The Redis client is left open; it
+// belongs to the caller.
+func (a *Adapter) Close() error {
+	a.mu.Lock()
+	pubsub, done := a.pubsub, a.done
+	a.pubsub = nil
+	a.mu.Unlock()
+	if pubsub == nil {
+		return nil
+	}
+	err := pubsub.Close()
+	<-done
+	return err
+}
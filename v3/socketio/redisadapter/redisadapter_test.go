@@ -0,0 +1,152 @@
+package redisadapter
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/contrib/v3/socketio"
+	"github.com/gofiber/fiber/v3"
+	fiberredis "github.com/gofiber/storage/redis/v3"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func newTestStore(t *testing.T) *fiberredis.Storage {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	store := fiberredis.New(fiberredis.Config{URL: "redis://" + mr.Addr()})
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+// collect subscribes a and returns a channel fed with every packet it
+// receives from other nodes.
+func collect(t *testing.T, a *Adapter) <-chan []byte {
+	t.Helper()
+	ch := make(chan []byte, 16)
+	require.NoError(t, a.Subscribe(func(packet []byte) { ch <- packet }))
+	t.Cleanup(func() { _ = a.Close() })
+	return ch
+}
+
+func receive(t *testing.T, ch <-chan []byte) []byte {
+	t.Helper()
+	select {
+	case packet := <-ch:
+		return packet
+	case <-time.After(5 * time.Second):
+		t.Fatal("packet not delivered")
+		return nil
+	}
+}
+
+func Test_Adapter_PublishReachesOtherNodesOnly(t *testing.T) {
+	store := newTestStore(t)
+	nodeA := New(Config{Store: store})
+	nodeB := New(Config{Store: store})
+	other := New(Config{Store: store, Channel: "other"})
+
+	fromA := collect(t, nodeA)
+	fromB := collect(t, nodeB)
+	fromOther := collect(t, other)
+
+	require.NoError(t, nodeA.Publish([]byte(`{"k":1}`)))
+	require.Equal(t, `{"k":1}`, string(receive(t, fromB)))
+
+	require.NoError(t, nodeB.Publish([]byte(`{"k":2}`)))
+	require.Equal(t, `{"k":2}`, string(receive(t, fromA)))
+
+	// Nodes never see their own packets, and channels are isolated.
+	require.Empty(t, fromA)
+	require.Empty(t, fromB)
+	require.Empty(t, fromOther)
+}
+
+func Test_Adapter_RequiresStore(t *testing.T) {
+	a := New()
+	require.ErrorIs(t, a.Publish(nil), ErrStoreRequired)
+	require.ErrorIs(t, a.Subscribe(func([]byte) {}), ErrStoreRequired)
+	require.NoError(t, a.Close())
+}
+
+func Test_Adapter_DoubleSubscribe(t *testing.T) {
+	a := New(Config{Store: newTestStore(t)})
+	collect(t, a)
+	require.Error(t, a.Subscribe(func([]byte) {}))
+}
+
+func Test_Adapter_Membership(t *testing.T) {
+	a := New()
+	a.AddAll("sid", "b", "a")
+	require.Equal(t, []string{"a", "b"}, a.Rooms("sid"))
+	require.Equal(t, []string{"sid"}, a.Members("a"))
+	a.DelAll("sid")
+	require.Nil(t, a.Rooms("sid"))
+}
+
+// Test_Adapter_CrossNodeRoomEmit runs a real socketio node using the Redis
+// adapter next to a bare "remote" adapter and checks that a room emit
+// leaves through Redis and that a packet arriving from Redis is delivered
+// to the local room member.
+func Test_Adapter_CrossNodeRoomEmit(t *testing.T) {
+	store := newTestStore(t)
+	local := New(Config{Store: store})
+	require.NoError(t, socketio.SetAdapter(local))
+	t.Cleanup(func() {
+		_ = socketio.SetAdapter(nil)
+		_ = local.Close()
+	})
+	remote := New(Config{Store: store})
+	fromLocal := collect(t, remote)
+
+	var joined sync.WaitGroup
+	joined.Add(1)
+	app := fiber.New()
+	ln := fasthttputil.NewInmemoryListener()
+	app.Get("/", socketio.New(func(kws *socketio.Websocket) {
+		kws.Join("lobby")
+		joined.Done()
+	}))
+	go func() { _ = app.Listener(ln) }()
+	t.Cleanup(func() {
+		_ = app.Shutdown()
+		_ = ln.Close()
+	})
+
+	dialer := &websocket.Dialer{
+		NetDial:          func(_, _ string) (net.Conn, error) { return ln.Dial() },
+		HandshakeTimeout: 5 * time.Second,
+	}
+	conn, _, err := dialer.Dial("ws://"+ln.Addr().String(), nil)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	_, msg, err := conn.ReadMessage() // EIO OPEN
+	require.NoError(t, err)
+	require.Equal(t, byte('0'), msg[0])
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("40")))
+	_, msg, err = conn.ReadMessage() // SIO CONNECT ack
+	require.NoError(t, err)
+	require.Equal(t, "40", string(msg[:2]))
+	joined.Wait()
+
+	// Outbound: the local member gets the event and the packet is
+	// published for the other nodes.
+	require.NoError(t, socketio.To("lobby").Emit("news", []byte(`"hi"`)))
+	_, msg, err = conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, `42["news","hi"]`, string(msg))
+	packet := receive(t, fromLocal)
+
+	// Inbound: the same packet published by the remote node is delivered
+	// to the local room member.
+	require.NoError(t, remote.Publish(packet))
+	_, msg, err = conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, `42["news","hi"]`, string(msg))
+}
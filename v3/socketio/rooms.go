@@ -0,0 +1,197 @@
+package socketio
+
+// members returns the union of local connection UUIDs in any of names.
+// A name that matches a local connection UUID resolves to that
+// connection, mirroring socket.io where every socket is implicitly in a
+// room named after its own id.
+func members(names []string) map[string]struct{} {
+	out := make(map[string]struct{})
+	for _, uuid := range adapter().Members(names...) {
+		out[uuid] = struct{}{}
+	}
+	for _, name := range names {
+		if _, err := pool.get(name); err == nil {
+			out[name] = struct{}{}
+		}
+	}
+	return out
+}
+
+// Join adds the connection to one or more rooms. Joining a room the
+// connection is already in is a no-op. Calls on a disconnected socket are
+// ignored so a late Join cannot resurrect membership for a dead
+// connection. Concurrency-safe.
+func (kws *Websocket) Join(names ...string) {
+	// pool's read lock pins the UUID against a concurrent SetUUID.
+	pool.RLock()
+	defer pool.RUnlock()
+	if !kws.IsAlive() {
+		return
+	}
+	uuid := kws.GetUUID()
+	a := adapter()
+	a.AddAll(uuid, names...)
+	// disconnected() flips isAlive before calling DelAll, so either
+	// DelAll runs after the AddAll above or we observe the dead socket
+	// here and undo it ourselves.
+	if !kws.IsAlive() {
+		a.DelAll(uuid)
+	}
+}
+
+// Leave removes the connection from room. Leaving a room the connection
+// is not in is a no-op. Concurrency-safe.
+func (kws *Websocket) Leave(room string) {
+	pool.RLock()
+	defer pool.RUnlock()
+	adapter().Del(kws.GetUUID(), room)
+}
+
+// Rooms returns the rooms this connection has joined on this node, sorted
+// by name.
+// The connection's own UUID is not listed even though To(uuid) reaches
+// it. Returns nil when the connection is in no room.
+func (kws *Websocket) Rooms() []string {
+	return adapter().Rooms(kws.GetUUID())
+}
+
+// To returns a broadcast builder targeting the given rooms of this
+// connection's namespace that skips this connection, matching
+// socket.to(room).emit(...) on the socket.io server.
+func (kws *Websocket) To(names ...string) *BroadcastOperator {
+	return &BroadcastOperator{
+		rooms:      append([]string(nil), names...),
+		exceptUUID: kws.GetUUID(),
+		nsp:        nsName(kws.getNamespace()),
+	}
+}
+
+// BroadcastOperator selects a set of connections by room and emits an
+// event to each of them. Build one with To (package-level or on a
+// Websocket) and narrow it with To / Except. Every method returns a new
+// operator, so a partially built operator can be reused safely.
+type BroadcastOperator struct {
+	rooms  []string
+	except []string
+	// exceptUUID is the originating connection when the operator was
+	// built with Websocket.To; it is always skipped.
+	exceptUUID string
+	// nsp restricts delivery to sockets of one namespace ("/",
+	// "/admin", ...); empty selects every namespace.
+	nsp string
+}
+
+// To returns a broadcast builder that targets every connection in any of
+// the given rooms. A room name equal to a connection UUID targets that
+// connection. With no rooms the builder targets every active connection.
+func To(names ...string) *BroadcastOperator {
+	return &BroadcastOperator{rooms: append([]string(nil), names...)}
+}
+
+// To returns a copy of the operator that additionally targets names.
+func (b *BroadcastOperator) To(names ...string) *BroadcastOperator {
+	next := b.clone()
+	next.rooms = append(next.rooms, names...)
+	return next
+}
+
+// Except returns a copy of the operator that skips every connection in
+// any of the given rooms (or whose UUID matches one of names).
+func (b *BroadcastOperator) Except(names ...string) *BroadcastOperator {
+	next := b.clone()
+	next.except = append(next.except, names...)
+	return next
+}
+
+func (b *BroadcastOperator) clone() *BroadcastOperator {
+	return &BroadcastOperator{
+		rooms:      append([]string(nil), b.rooms...),
+		except:     append([]string(nil), b.except...),
+		exceptUUID: b.exceptUUID,
+		nsp:        b.nsp,
+	}
+}
+
+// Emit sends a named event with args to every selected connection, on
+// each connection's own namespace, then publishes it through the Adapter
+// so other nodes deliver it to their own members. Args follow the
+// EmitArgs rules: valid JSON is passed through and raw text is encoded as
+// a JSON string.
+//
+// Returns ErrReservedEventName without sending anything when event is a
+// reserved lifecycle name, and the Adapter's error when publishing fails
+// (local delivery has already happened by then). Delivery is
+// best-effort: connections that disconnect while the broadcast is in
+// flight are skipped silently.
+func (b *BroadcastOperator) Emit(event string, args ...[]byte) error {
+	if isReservedEventName(event) {
+		return ErrReservedEventName
+	}
+	b.emitLocal(event, args)
+	return publish(&adapterPacket{
+		Kind:       packetEvent,
+		Event:      event,
+		Args:       args,
+		Rooms:      b.rooms,
+		Except:     b.except,
+		ExceptUUID: b.exceptUUID,
+		Nsp:        b.nsp,
+	})
+}
+
+// emitLocal delivers event to the selected connections of this node.
+func (b *BroadcastOperator) emitLocal(event string, args [][]byte) {
+	// A dead connection drops the event, or buffers it while it waits
+	// to be parked.
+	for _, conn := range b.targets() {
+		conn.EmitArgs(event, args...)
+	}
+	if RecoveryWindow > 0 && !isReservedEventName(event) {
+		captureParked(b.parkedMatch, func(ns []byte) []byte {
+			return buildSIOEventWithAck(ns, 0, false, event, args)
+		})
+	}
+}
+
+// targets resolves the operator's room selection against the pool.
+func (b *BroadcastOperator) targets() []ws {
+	var excluded map[string]struct{}
+	if len(b.except) > 0 {
+		excluded = members(b.except)
+	}
+	skip := func(uuid string) bool {
+		if uuid == b.exceptUUID && uuid != "" {
+			return true
+		}
+		_, ok := excluded[uuid]
+		return ok
+	}
+
+	var out []ws
+	if len(b.rooms) == 0 {
+		for uuid, conn := range pool.all() {
+			if !skip(uuid) && b.inNamespace(conn) {
+				out = append(out, conn)
+			}
+		}
+		return out
+	}
+	for uuid := range members(b.rooms) {
+		if skip(uuid) {
+			continue
+		}
+		if conn, err := pool.get(uuid); err == nil && b.inNamespace(conn) {
+			out = append(out, conn)
+		}
+	}
+	return out
+}
+
+// inNamespace reports whether conn belongs to the operator's namespace.
+func (b *BroadcastOperator) inNamespace(conn ws) bool {
+	if b.nsp == "" {
+		return true
+	}
+	kws, ok := conn.(*Websocket)
+	return ok && nsName(kws.getNamespace()) == b.nsp
+}
@@ -0,0 +1,114 @@
+package socketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoomIndexJoinLeave(t *testing.T) {
+	resetSIOGlobals(t)
+
+	kws := createWS()
+	kws.done = make(chan struct{}, 1)
+	pool.set(kws)
+	uuid := kws.GetUUID()
+
+	kws.Join("b", "a", "a", "")
+	require.Equal(t, []string{"a", "b"}, kws.Rooms())
+
+	kws.Leave("a")
+	kws.Leave("missing")
+	require.Equal(t, []string{"b"}, kws.Rooms())
+
+	require.NoError(t, kws.SetUUID("renamed"))
+	require.Equal(t, []string{"b"}, kws.Rooms())
+	require.Empty(t, adapter().Rooms(uuid))
+	require.Equal(t, []string{"renamed"}, adapter().Members("b"))
+
+	kws.disconnected(nil)
+	require.Nil(t, kws.Rooms())
+	require.Empty(t, adapter().Members("b"))
+
+	// A Join after disconnect must not leak membership.
+	kws.Join("c")
+	require.Nil(t, kws.Rooms())
+}
+
+func TestBroadcastOperatorTargets(t *testing.T) {
+	resetSIOGlobals(t)
+
+	k1, k2, k3 := createWS(), createWS(), createWS()
+	for _, k := range []*Websocket{k1, k2, k3} {
+		pool.set(k)
+	}
+	k1.Join("a")
+	k2.Join("a", "b")
+
+	uuids := func(op *BroadcastOperator) []string {
+		var out []string
+		for _, conn := range op.targets() {
+			out = append(out, conn.GetUUID())
+		}
+		return out
+	}
+
+	require.ElementsMatch(t, []string{k1.UUID, k2.UUID}, uuids(To("a")))
+	require.ElementsMatch(t, []string{k1.UUID}, uuids(To("a").Except("b")))
+	require.ElementsMatch(t, []string{k2.UUID}, uuids(k1.To("a")))
+	require.ElementsMatch(t, []string{k1.UUID, k2.UUID, k3.UUID}, uuids(To()))
+	require.ElementsMatch(t, []string{k2.UUID, k3.UUID}, uuids(To().Except(k1.UUID)))
+	require.ElementsMatch(t, []string{k3.UUID}, uuids(To(k3.UUID)))
+	require.ElementsMatch(t, []string{k2.UUID, k3.UUID}, uuids(To("b").To(k3.UUID)))
+	require.Empty(t, uuids(To("nobody")))
+
+	require.ErrorIs(t, To("a").Emit(EventDisconnect), ErrReservedEventName)
+}
+
+// TestSocketIORoomsEmit verifies room broadcasts end to end: only members
+// of the target room that are not excluded receive the event.
+func TestSocketIORoomsEmit(t *testing.T) {
+	resetSIOGlobals(t)
+
+	joined := make(chan *Websocket, 3)
+	n := 0
+	ln, teardown := newSIOTestServer(t, func(kws *Websocket) {
+		n++
+		switch n {
+		case 1:
+			kws.Join("lobby")
+		case 2:
+			kws.Join("lobby", "muted")
+		}
+		joined <- kws
+	})
+	defer teardown()
+
+	var clients []*websocket.Conn
+	for i := 0; i < 3; i++ {
+		conn := dialSIO(t, ln)
+		defer func() { _ = conn.Close() }()
+		require.NoError(t, sioHandshake(t, conn))
+		select {
+		case <-joined:
+		case <-time.After(5 * time.Second):
+			t.Fatal("connection callback did not run")
+		}
+		clients = append(clients, conn)
+	}
+
+	require.NoError(t, To("lobby").Except("muted").Emit("news", []byte(`"hi"`)))
+
+	_ = clients[0].SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, msg, err := sioReadSkipPings(clients[0])
+	require.NoError(t, err)
+	require.Equal(t, `42["news","hi"]`, string(msg))
+
+	for _, conn := range clients[1:] {
+		_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		_, msg, err := sioReadSkipPings(conn)
+		require.Error(t, err, "unexpected frame %q", msg)
+	}
+}
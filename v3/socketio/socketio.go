@@ -362,6 +362,10 @@ type message struct {
 	data []byte
 	// Message send retries when error
 	retries int
+	// attachments are the binary frames that follow a BINARY_EVENT /
+	// BINARY_ACK header. They travel in the same queue entry as the
+	// header so no other frame can be written between them.
+	attachments [][]byte
 }
 
 // EventPayload is the read-only value passed to every event listener. It
@@ -382,6 +386,9 @@ type EventPayload struct {
 	// Name is the event name as registered with On (e.g. "message",
 	// EventConnect, or any custom event).
 	Name string
+	// Namespace is the namespace of the socket that fired the event, "/"
+	// for the root namespace.
+	Namespace string
 	// SocketUUID is the unique identifier of the originating connection,
 	// captured at dispatch time so it remains stable even if the
 	// connection is concurrently closed.
@@ -399,7 +406,16 @@ type EventPayload struct {
 	// Args are the raw-JSON arguments the client sent with the event.
 	// Each entry is one JSON value; nil for events without args. Use
 	// this to consume socket.emit("event", a, b, c) from the JS client.
+	//
+	// For binary events, an argument that was an ArrayBuffer / Blob /
+	// Buffer on the client holds the raw attachment bytes instead of
+	// JSON.
 	Args [][]byte
+	// Attachments are the binary attachments of a BINARY_EVENT, indexed
+	// by placeholder num. Top-level binary arguments are already
+	// substituted into Args; use Attachments to resolve placeholders
+	// nested inside an object or array argument. nil for text events.
+	Attachments [][]byte
 	// AckID is the Socket.IO ack id attached to this event by the
 	// client. It is meaningful only when HasAck is true. Use Ack to
 	// respond.
@@ -435,6 +451,16 @@ type EventPayload struct {
 // Returns an error if the event has no ack id, the connection is closed, or
 // the ack has already been sent for this payload.
 func (ep *EventPayload) Ack(args ...[]byte) error {
+	if err := ep.claimAck(); err != nil {
+		return err
+	}
+	ep.Kws.write(TextMessage, buildSIOAck(ep.Kws.getNamespace(), ep.AckID, args))
+	return nil
+}
+
+// claimAck checks that an ack may be sent for this payload and flips the
+// shared once-only guard. Shared by Ack and AckBinary.
+func (ep *EventPayload) claimAck() error {
 	if !ep.HasAck {
 		return ErrAckNotRequested
 	}
@@ -450,7 +476,6 @@ func (ep *EventPayload) Ack(args ...[]byte) error {
 	if !ep.ackSent.CompareAndSwap(false, true) {
 		return ErrAckAlreadySent
 	}
-	ep.Kws.write(TextMessage, buildSIOAck(ep.Kws.getNamespace(), ep.AckID, args))
 	return nil
 }
 
@@ -699,7 +724,8 @@ type Websocket struct {
 	// Conn is the underlying Fiber WebSocket connection. Treat it as
 	// read-only from listener callbacks; writes must go through the
 	// Emit/EmitEvent/Broadcast methods so the send goroutine remains
-	// the sole writer.
+	// the sole writer. nil for polling sessions and for sockets of
+	// namespaces connected after the first one on a connection.
 	Conn *websocket.Conn
 	// isAlive reports whether the connection is alive. Accessed lock-free
 	// from every emit path (write/EmitTo/etc.) and the read goroutine.
@@ -796,6 +822,45 @@ type Websocket struct {
 	// value even if the timer fires before the assignment in
 	// openPollingSession is visible.
 	handshakeTimer atomic.Pointer[time.Timer]
+	// pendingBinary is the inbound binary packet whose attachments are
+	// still arriving, nil otherwise. Owned by the reader (see
+	// binaryPacket).
+	pendingBinary *binaryPacket
+	// parent is the connection whose transport this socket shares when it
+	// was created by a later namespace CONNECT; nil for the socket of the
+	// first CONNECT, which owns the transport. Writes of a child socket go
+	// through parent, and its Conn is nil.
+	parent *Websocket
+	// children holds the sockets of namespaces connected after the first
+	// one, keyed by wire namespace (empty for root). Guarded by
+	// childrenMu; nil once the connection is torn down.
+	children   map[string]*Websocket
+	childrenMu sync.Mutex
+	// recovery numbers and buffers outbound events for connection state
+	// recovery; nil unless RecoveryWindow is set. See recovery.go.
+	recovery atomic.Pointer[recoveryBuffer]
+	// recovered flips true when the handshake restored a parked session.
+	recovered atomic.Bool
+	// noRecovery marks an explicit disconnect (server Close or client
+	// DISCONNECT), after which the session is not parked.
+	noRecovery atomic.Bool
+	// parked flips true once a dropped session is parked; until then
+	// writeMessage buffers its events itself, see bufferDropped.
+	parked atomic.Bool
+	// engineSID is the Engine.IO sid of a polling session, the key of its
+	// pollSessions entry. It stays fixed when UUID changes.
+	engineSID string
+	// eio3 marks an Engine.IO v3 / Socket.IO v2 session (see EnableEIO3).
+	// Set before the handshake and never changed.
+	eio3 bool
+	// rateBuckets holds the connection's LimitEvents buckets, keyed by
+	// event name (or AllEvents). Lazily allocated; only used on the
+	// socket that owns the transport. Guarded by rateMu.
+	rateBuckets map[string]*tokenBucket
+	rateMu      sync.Mutex
+	// metricsOpen is set while the connection is counted as open by the
+	// MetricsCollector.
+	metricsOpen atomic.Bool
 }
 
 type safePool struct {
@@ -938,6 +1003,7 @@ func New(callback func(kws *Websocket), config ...websocket.Config) func(fiber.C
 			},
 			queue: make(chan message, SendQueueSize),
 			done:  make(chan struct{}, 1),
+			eio3:  isEIO3Request(c.Query("EIO")),
 			// attributes and outboundAcks are lazy-initialised on first
 			// SetAttribute / EmitWithAck* call. Most idle connections never
 			// touch them; deferring the allocation saves ~560 B per conn at
@@ -967,6 +1033,7 @@ func New(callback func(kws *Websocket), config ...websocket.Config) func(fiber.C
 			kws.disconnected(err)
 			return
 		}
+		kws.connectionOpened()
 
 		// 2. Start the send goroutine before invoking the user callback so that
 		//    Emit/Broadcast calls inside it are flushed in order.
@@ -990,7 +1057,7 @@ func New(callback func(kws *Websocket), config ...websocket.Config) func(fiber.C
 		// If the callback actively closed the socket (for example after
 		// inspecting HandshakeAuth), do not emit EventConnect for a connection
 		// user code already rejected.
-		if !kws.IsAlive() {
+		if !kws.IsAlive() || !kws.runConnectHandlers() {
 			kws.finishRun()
 			return
 		}
@@ -1011,8 +1078,8 @@ func New(callback func(kws *Websocket), config ...websocket.Config) func(fiber.C
 		// query parameter is permitted and defaults to v4 to keep
 		// backwards compatibility with non-strict callers and tests
 		// that dial the WebSocket endpoint directly.
-		if eio := c.Query("EIO"); eio != "" && eio != "4" {
-			logf("warn", "eio_version_mismatch", "requested", eio, "supported", "4", "remote", c.IP())
+		if eio := c.Query("EIO"); eio != "" && eio != "4" && !isEIO3Request(eio) {
+			rejectHandshake("eio_version_mismatch", "requested", eio, "supported", "4", "remote", c.IP())
 			c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
 			return c.Status(fiber.StatusBadRequest).SendString(unsupportedEIOVersionBody)
 		}
@@ -1058,7 +1125,16 @@ func (kws *Websocket) GetUUID() string {
 //  1. Server -> Client: 0{...sid,pingInterval,pingTimeout,maxPayload}
 //  2. Client -> Server: 40 (optionally with namespace, e.g. "40/admin,")
 //  3. Server -> Client: 40{"sid":"..."}
-func (kws *Websocket) handshake() error {
+func (kws *Websocket) handshake() (err error) {
+	// Refusals are counted by rejectHandshake; anything else that ends
+	// the handshake is a transport or protocol failure.
+	refused := false
+	defer func() {
+		if err != nil && !refused {
+			metrics().HandshakeFailed("handshake_failure")
+		}
+	}()
+
 	// Enforce the advertised payload size: prevent malicious clients from
 	// streaming arbitrarily large frames into our memory.
 	if MaxPayload > 0 {
@@ -1066,7 +1142,7 @@ func (kws *Websocket) handshake() error {
 	}
 
 	// 1. Send EIO OPEN
-	frame, err := buildEIOOpenFrame(kws.UUID)
+	frame, err := kws.openFrame()
 	if err != nil {
 		return fmt.Errorf("socketio: marshal EIO OPEN: %w", err)
 	}
@@ -1083,25 +1159,31 @@ func (kws *Websocket) handshake() error {
 	_ = kws.Conn.SetReadDeadline(deadline)
 	defer func() { _ = kws.Conn.SetReadDeadline(time.Time{}) }()
 
-	mType, msg, err := kws.Conn.ReadMessage()
-	if err != nil {
-		return fmt.Errorf("socketio: read SIO CONNECT: %w", err)
-	}
-	if mType == CloseMessage {
-		return ErrHandshakeClosed
-	}
-	if mType != TextMessage || len(msg) < 2 || msg[0] != eioMessage || msg[1] != sioConnect {
-		return fmt.Errorf("socketio: expected SIO CONNECT (40), got type=%d payload=%q", mType, msg)
-	}
+	// Socket.IO v2 clients never send a CONNECT for "/": the server
+	// connects them to it right away.
+	var namespace, authPayload []byte
+	if !kws.eio3 {
+		mType, msg, err := kws.Conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("socketio: read SIO CONNECT: %w", err)
+		}
+		if mType == CloseMessage {
+			return ErrHandshakeClosed
+		}
+		if mType != TextMessage || len(msg) < 2 || msg[0] != eioMessage || msg[1] != sioConnect {
+			return fmt.Errorf("socketio: expected SIO CONNECT (40), got type=%d payload=%q", mType, msg)
+		}
 
-	// Extract optional namespace AND optional auth payload from the CONNECT
-	// packet. Wire format: "40" [ "/namespace," ] [ <json_auth> ]
-	namespace, authPayload := extractSIOConnect(msg[2:])
+		// Extract optional namespace AND optional auth payload from the CONNECT
+		// packet. Wire format: "40" [ "/namespace," ] [ <json_auth> ]
+		namespace, authPayload = extractSIOConnect(msg[2:])
+	}
 
 	// Validate namespace charset to reject malformed prefixes that would
 	// otherwise be echoed back verbatim into every outbound emit.
 	if !isValidNamespace(namespace) {
-		logf("warn", "invalid_namespace", "uuid", kws.UUID, "namespace", string(namespace))
+		refused = true
+		rejectHandshake("invalid_namespace", "uuid", kws.UUID, "namespace", string(namespace))
 		_ = kws.writeConnectError(namespace, `{"message":"invalid namespace"}`)
 		return ErrInvalidNamespace
 	}
@@ -1113,10 +1195,17 @@ func (kws *Websocket) handshake() error {
 	// client cannot stage a large allocation through the handshake before
 	// any user code runs.
 	if !isValidAuthPayload(authPayload) {
-		logf("warn", "invalid_auth_payload", "uuid", kws.UUID, "namespace", string(namespace), "size", len(authPayload))
+		refused = true
+		rejectHandshake("invalid_auth_payload", "uuid", kws.UUID, "namespace", string(namespace), "size", len(authPayload))
 		_ = kws.writeConnectError(namespace, `{"message":"Invalid auth payload"}`)
 		return ErrInvalidAuthPayload
 	}
+	if !namespaceAllowed(namespace) {
+		refused = true
+		rejectHandshake("unknown_namespace", "uuid", kws.UUID, "namespace", string(namespace))
+		_ = kws.writeConnectError(namespace, `{"message":"Invalid namespace"}`)
+		return ErrInvalidNamespace
+	}
 
 	// Store as a fresh slice (msg's backing buffer is owned by the read
 	// loop and may be reused) so concurrent readers via getNamespace see
@@ -1131,17 +1220,34 @@ func (kws *Websocket) handshake() error {
 	}
 	kws.mu.Unlock()
 
-	// 3. Send SIO CONNECT confirmation, mirroring the namespace.
-	payload, err := json.Marshal(struct {
-		SID string `json:"sid"`
-	}{SID: kws.UUID})
-	if err != nil {
-		return fmt.Errorf("socketio: marshal SIO CONNECT: %w", err)
+	// Namespace middleware sees the stored namespace and auth. A
+	// rejection ends the handshake, as an invalid namespace does.
+	if err := authorize(kws); err != nil {
+		refused = true
+		rejectHandshake("namespace_rejected", "uuid", kws.UUID, "namespace", string(namespace), "err", err.Error())
+		_ = kws.writeConnectError(namespace, connectErrorMessage(err))
+		return err
 	}
-	ack := buildSIOConnectAck(namespace, payload)
+
+	// A reconnecting client may take its parked session back.
+	missed := kws.recoverSession()
+
+	// 3. Send SIO CONNECT confirmation, mirroring the namespace, then the
+	//    events a recovered session missed.
+	ack := buildSIOConnectAck(namespace, kws.connectAckPayload(kws.GetUUID()))
 	if err := kws.Conn.WriteMessage(TextMessage, ack); err != nil {
 		return fmt.Errorf("socketio: write SIO CONNECT: %w", err)
 	}
+	for _, msg := range missed {
+		if err := kws.Conn.WriteMessage(msg.mType, msg.data); err != nil {
+			return fmt.Errorf("socketio: replay missed packet: %w", err)
+		}
+		for _, a := range msg.attachments {
+			if err := kws.Conn.WriteMessage(BinaryMessage, a); err != nil {
+				return fmt.Errorf("socketio: replay missed packet: %w", err)
+			}
+		}
+	}
 	return nil
 }
 
@@ -1279,6 +1385,7 @@ func (kws *Websocket) SetUUID(uuid string) error {
 
 	if prevUUID != "" {
 		delete(pool.conn, prevUUID)
+		adapter().Rename(prevUUID, uuid)
 	}
 	pool.conn[uuid] = kws
 	return nil
@@ -1334,19 +1441,31 @@ func (kws *Websocket) GetStringAttribute(key string) string {
 
 // EmitToList sends message to every connection whose UUID appears in uuids.
 // Per-target failures are surfaced as EventError on kws by EmitTo (not
-// returned). See Websocket.Emit for the meaning of mType.
+// returned). UUIDs not held by this node are forwarded to the other nodes
+// in a single Adapter packet when a cross-node Adapter is installed. See
+// Websocket.Emit for the meaning of mType.
 func (kws *Websocket) EmitToList(uuids []string, message []byte, mType ...int) {
+	var remote []string
 	for _, wsUUID := range uuids {
-		_ = kws.EmitTo(wsUUID, message, mType...)
+		if !kws.emitToLocal(wsUUID, message, mType...) {
+			remote = append(remote, wsUUID)
+		}
 	}
+	kws.publishTo(remote, message, mType...)
 }
 
 // EmitToList is the package-level form of Websocket.EmitToList. It sends
 // message to every connection whose UUID appears in uuids. Errors are
 // silently ignored; use the method form to receive them via EventError.
 func EmitToList(uuids []string, message []byte, mType ...int) {
+	var remote []string
 	for _, wsUUID := range uuids {
-		_ = EmitTo(wsUUID, message, mType...)
+		if emitTo(wsUUID, message, mType...) != nil {
+			remote = append(remote, wsUUID)
+		}
+	}
+	if len(remote) > 0 && !adapter().local {
+		_ = publish(messagePacket(remote, "", message, mType...))
 	}
 }
 
@@ -1355,34 +1474,79 @@ func EmitToList(uuids []string, message []byte, mType ...int) {
 // unknown or already closed; in either case an EventError is fired on kws so
 // fan-out callers (EmitToList, Broadcast) do not have to re-fire it. See
 // Websocket.Emit for the meaning of mType.
+//
+// With a cross-node Adapter installed, a UUID not held by this node is
+// forwarded to the other nodes instead and EmitTo returns the Adapter's
+// publish error, if any.
 func (kws *Websocket) EmitTo(uuid string, message []byte, mType ...int) error {
-	conn, err := pool.get(uuid)
-	if err != nil {
-		kws.fireEvent(EventError, []byte(uuid), err)
-		return err
+	if kws.emitToLocal(uuid, message, mType...) {
+		return nil
 	}
-	// pool.get already returned a hit; we only need to verify the conn is
-	// still alive. Dropping the redundant pool.contains saves one RWMutex
-	// RLock per call - meaningful in Broadcast/EmitToList fanout paths.
-	if !conn.IsAlive() {
-		kws.fireEvent(EventError, []byte(uuid), ErrorInvalidConnection)
-		return ErrorInvalidConnection
+	if _, err := pool.get(uuid); err != nil && !adapter().local {
+		return kws.publishTo([]string{uuid}, message, mType...)
 	}
+	kws.fireEvent(EventError, []byte(uuid), ErrorInvalidConnection)
+	return ErrorInvalidConnection
+}
 
-	conn.Emit(message, mType...)
-	return nil
+// emitToLocal delivers message to uuid when this node holds a live
+// connection for it and reports whether it did.
+func (kws *Websocket) emitToLocal(uuid string, message []byte, mType ...int) bool {
+	return emitTo(uuid, message, mType...) == nil
+}
+
+// publishTo forwards message for uuids to the other nodes, firing
+// EventError on kws for every target when there are no other nodes or
+// publishing fails.
+func (kws *Websocket) publishTo(uuids []string, message []byte, mType ...int) error {
+	if len(uuids) == 0 {
+		return nil
+	}
+	err := ErrorInvalidConnection
+	if !adapter().local {
+		err = publish(messagePacket(uuids, "", message, mType...))
+	}
+	if err != nil {
+		for _, uuid := range uuids {
+			kws.fireEvent(EventError, []byte(uuid), err)
+		}
+	}
+	return err
 }
 
 // EmitTo is the package-level form of Websocket.EmitTo. It sends message to
 // the connection identified by uuid and returns ErrorInvalidConnection when
-// the target is unknown or already closed.
+// the target is unknown or already closed. With a cross-node Adapter
+// installed, a UUID not held by this node is forwarded to the other nodes
+// instead.
 func EmitTo(uuid string, message []byte, mType ...int) error {
+	err := emitTo(uuid, message, mType...)
+	if errors.Is(err, ErrorInvalidConnection) && !adapter().local {
+		if _, missing := pool.get(uuid); missing != nil {
+			return publish(messagePacket([]string{uuid}, "", message, mType...))
+		}
+	}
+	return err
+}
+
+// emitTo delivers message to uuid on this node only.
+func emitTo(uuid string, message []byte, mType ...int) error {
 	conn, err := pool.get(uuid)
 	if err != nil {
+		if captureParkedMessage([]string{uuid}, "", message, mType...) {
+			return nil
+		}
 		return err
 	}
-
+	// pool.get already returned a hit; we only need to verify the conn is
+	// still alive. Dropping the redundant pool.contains saves one RWMutex
+	// RLock per call - meaningful in Broadcast/EmitToList fanout paths.
 	if !conn.IsAlive() {
+		if kws, ok := conn.(*Websocket); ok && kws.recoverable() && (len(mType) == 0 || mType[0] == TextMessage) {
+			// Dropped but not parked yet: writeMessage buffers it.
+			kws.Emit(message, mType...)
+			return nil
+		}
 		return ErrorInvalidConnection
 	}
 
@@ -1390,30 +1554,55 @@ func EmitTo(uuid string, message []byte, mType ...int) error {
 	return nil
 }
 
+// messagePacket builds the Adapter packet for Emit-style message delivery.
+func messagePacket(uuids []string, exceptUUID string, message []byte, mType ...int) *adapterPacket {
+	p := &adapterPacket{Kind: packetMessage, Data: message, UUIDs: uuids, ExceptUUID: exceptUUID}
+	if len(mType) > 0 {
+		p.MType = mType[0]
+	}
+	return p
+}
+
 // Broadcast sends message to every active connection in the pool. When except
 // is true the originating connection is skipped. The optional mType selects
 // the WebSocket frame type: omit it (or pass TextMessage) to wrap message as
 // a Socket.IO "message" event; pass BinaryMessage to send the bytes verbatim
-// as a binary frame.
+// as a binary frame. The message is also published through the Adapter so
+// connections on other nodes receive it.
 func (kws *Websocket) Broadcast(message []byte, except bool, mType ...int) {
 	selfUUID := kws.GetUUID()
 	for wsUUID := range pool.all() {
 		if except && selfUUID == wsUUID {
 			continue
 		}
-		// EmitTo fires EventError on failure; no need to re-fire here.
-		_ = kws.EmitTo(wsUUID, message, mType...)
+		// Local fan-out only: a connection that vanished since pool.all
+		// is not forwarded to other nodes, the Adapter packet below
+		// already covers them.
+		if !kws.emitToLocal(wsUUID, message, mType...) {
+			kws.fireEvent(EventError, []byte(wsUUID), ErrorInvalidConnection)
+		}
+	}
+	exceptUUID := ""
+	if except {
+		exceptUUID = selfUUID
+	}
+	captureParkedMessage(nil, exceptUUID, message, mType...)
+	if err := publish(messagePacket(nil, exceptUUID, message, mType...)); err != nil {
+		kws.fireEvent(EventError, message, err)
 	}
 }
 
 // Broadcast is the package-level form of Websocket.Broadcast. It sends
 // message to every active connection in the pool, including the originator
-// (use the method form to skip the originator). See Websocket.Emit for the
-// meaning of mType.
+// (use the method form to skip the originator), and publishes it through
+// the Adapter for the other nodes. See Websocket.Emit for the meaning of
+// mType.
 func Broadcast(message []byte, mType ...int) {
 	for _, kws := range pool.all() {
 		kws.Emit(message, mType...)
 	}
+	captureParkedMessage(nil, "", message, mType...)
+	_ = publish(messagePacket(nil, "", message, mType...))
 }
 
 // Fire delivers a synthetic event to the listeners registered for event on
@@ -1425,10 +1614,12 @@ func (kws *Websocket) Fire(event string, data []byte) {
 }
 
 // Fire delivers a synthetic event to the listeners registered for event on
-// every active connection. It does not produce a wire frame. See
+// every active connection, including connections on other nodes when a
+// cross-node Adapter is installed. It does not produce a wire frame. See
 // Websocket.Fire for the per-connection variant.
 func Fire(event string, data []byte) {
 	fireGlobalEvent(event, data, nil)
+	_ = publish(&adapterPacket{Kind: packetFire, Event: event, Data: data})
 }
 
 // Emit sends message to the client wrapped as a Socket.IO "message" event
@@ -1451,6 +1642,7 @@ func (kws *Websocket) Emit(message []byte, mType ...int) {
 	}
 	if t == TextMessage {
 		kws.write(TextMessage, buildSIOEvent(kws.getNamespace(), EventMessage, message))
+		metrics().EventSent(EventMessage)
 	} else {
 		kws.write(t, message)
 	}
@@ -1473,6 +1665,7 @@ func (kws *Websocket) EmitEvent(event string, data []byte) {
 		args = [][]byte{data}
 	}
 	kws.write(TextMessage, buildSIOEventWithAck(kws.getNamespace(), 0, false, event, args))
+	metrics().EventSent(event)
 }
 
 // EmitArgs sends a named socket.io event with multiple arguments, matching the
@@ -1487,6 +1680,7 @@ func (kws *Websocket) EmitArgs(event string, args ...[]byte) {
 		return
 	}
 	kws.write(TextMessage, buildSIOEventWithAck(kws.getNamespace(), 0, false, event, args))
+	metrics().EventSent(event)
 }
 
 // EmitWithAck sends a named socket.io event and registers a callback that
@@ -1577,6 +1771,7 @@ func (kws *Websocket) EmitWithAckTimeout(event string, data []byte, timeout time
 		args = [][]byte{data}
 	}
 	kws.write(TextMessage, buildSIOEventWithAck(kws.getNamespace(), id, true, event, args))
+	metrics().EventSent(event)
 }
 
 // EmitWithAckArgs is the multi-arg + structured-error variant of
@@ -1598,6 +1793,7 @@ func (kws *Websocket) EmitWithAckArgs(event string, args [][]byte, cb func([][]b
 	}
 	if cb == nil {
 		kws.write(TextMessage, buildSIOEventWithAck(kws.getNamespace(), 0, false, event, args))
+		metrics().EventSent(event)
 		return
 	}
 	kws.outboundAcksMu.Lock()
@@ -1630,6 +1826,7 @@ func (kws *Websocket) EmitWithAckArgs(event string, args [][]byte, cb func([][]b
 	kws.outboundAcksMu.Unlock()
 
 	kws.write(TextMessage, buildSIOEventWithAck(kws.getNamespace(), id, true, event, args))
+	metrics().EventSent(event)
 }
 
 // deliverOutboundAck dispatches an incoming ACK to the registered callback,
@@ -1687,6 +1884,7 @@ func (kws *Websocket) fireAckTimeout(id uint64) {
 		return
 	}
 	logf("warn", "ack_timeout", "uuid", kws.UUID, "ack_id", id)
+	metrics().AckTimedOut()
 	defer func() { _ = recover() }()
 	p.cb(nil, ErrAckTimeout)
 }
@@ -1705,7 +1903,10 @@ func adaptSingleArgAck(cb AckCallback) func(args [][]byte, err error) {
 	}
 }
 
-// Close actively closes the connection from the server side.
+// Close actively closes the connection from the server side. On a socket
+// created by a later namespace CONNECT it only disconnects that namespace;
+// on the socket of the first CONNECT it closes the whole transport,
+// including every other namespace multiplexed on it.
 //
 // It is idempotent: the synchronous DISCONNECT plus close-frame write block
 // runs at most once even when called concurrently. EventClose fires exactly
@@ -1727,10 +1928,16 @@ func adaptSingleArgAck(cb AckCallback) func(args [][]byte, err error) {
 // in-flight Close() write has completed before the handler returns
 // and releaseConn() fires.
 func (kws *Websocket) Close() {
+	kws.closeWithError(nil)
+}
+
+// closeWithError is Close with err handed to EventDisconnect listeners.
+func (kws *Websocket) closeWithError(err error) {
 	if !kws.IsAlive() {
 		return
 	}
 
+	kws.noRecovery.Store(true)
 	kws.closeOnce.Do(func() {
 		// Build the SIO DISCONNECT frame. Per socket.io-protocol v5,
 		// namespaced packets are "41/<ns>," with a trailing comma
@@ -1741,7 +1948,12 @@ func (kws *Websocket) Close() {
 			disconnect = append(disconnect, ',')
 		}
 
-		if kws.pollQ != nil {
+		if kws.parent != nil {
+			// Child namespace socket: leave the namespace only. The
+			// shared transport and its other namespaces stay open, so
+			// the frame goes through the parent's queue like any emit.
+			kws.parent.write(TextMessage, disconnect)
+		} else if kws.pollQ != nil {
 			// Polling: enqueue SIO DISCONNECT and EIO CLOSE so the next
 			// drain (or any in-flight long-poll) delivers them; the
 			// queue is closed by disconnected() below, after which
@@ -1773,7 +1985,7 @@ func (kws *Websocket) Close() {
 		kws.fireEvent(EventClose, nil, nil)
 	})
 
-	kws.disconnected(nil)
+	kws.disconnected(err)
 }
 
 // getNamespace returns the Socket.IO namespace this connection is bound to,
@@ -1848,11 +2060,15 @@ func (kws *Websocket) IsAlive() bool {
 }
 
 // IsPolling reports whether this session is bound to the HTTP long-
-// polling transport rather than to a WebSocket. When true, kws.Conn is
+// polling transport rather than to a WebSocket. Sockets of additional
+// namespaces report the transport they share. When true, kws.Conn is
 // nil; user code that touches kws.Conn directly must guard with this
 // check (or just use the transport-agnostic Emit / Broadcast / Ack /
 // Close API, which works on both transports).
 func (kws *Websocket) IsPolling() bool {
+	if kws.parent != nil {
+		return kws.parent.IsPolling()
+	}
 	return kws.pollQ != nil
 }
 
@@ -1917,7 +2133,8 @@ func (kws *Websocket) pong(ctx context.Context) {
 				return
 			}
 			// Emit a PING only every PingInterval, regardless of tick rate.
-			if time.Since(lastPing) >= interval {
+			// Engine.IO v3 clients ping the server instead.
+			if !kws.eio3 && time.Since(lastPing) >= interval {
 				kws.write(TextMessage, eioPingFrame)
 				lastPing = time.Now()
 			}
@@ -1936,6 +2153,28 @@ func (kws *Websocket) pong(ctx context.Context) {
 // not deadlocked when the send goroutine has died (e.g. after disconnected
 // fired). Calls on already-disconnected sockets are a no-op.
 func (kws *Websocket) write(messageType int, messageBytes []byte) {
+	kws.writeMessage(message{mType: messageType, data: messageBytes})
+}
+
+// writeMessage is write for a prepared queue entry, which may carry
+// binary attachments.
+func (kws *Websocket) writeMessage(msg message) {
+	if !kws.IsAlive() {
+		kws.bufferDropped(msg)
+		return
+	}
+	if buf := kws.recovery.Load(); buf != nil && isRecoverable(msg) {
+		msg = buf.record(msg)
+	}
+	if kws.parent != nil {
+		kws.parent.enqueue(msg)
+		return
+	}
+	kws.enqueue(msg)
+}
+
+// enqueue hands msg to the transport of this connection as is.
+func (kws *Websocket) enqueue(msg message) {
 	if !kws.IsAlive() {
 		return
 	}
@@ -1947,21 +2186,26 @@ func (kws *Websocket) write(messageType int, messageBytes []byte) {
 		// outbound messages are encoded here so user code can call
 		// Emit(data, BinaryMessage) without knowing the active
 		// transport.
-		frame := messageBytes
-		if messageType == BinaryMessage {
-			frame = encodePollingBinary(messageBytes)
+		frame := msg.data
+		if msg.mType == BinaryMessage {
+			frame = encodePollingBinary(msg.data)
+		}
+		frames := [][]byte{frame}
+		for _, a := range msg.attachments {
+			frames = append(frames, encodePollingBinary(a))
 		}
-		switch kws.pollQ.enqueue(frame) {
+		switch kws.pollQ.enqueue(frames...) {
 		case enqueueDroppedQueueFull:
 			logf("warn", "poll_queue_overflow_drop", "uuid", kws.UUID, "cap", PollQueueMaxFrames)
+			metrics().SendQueueOverflow(TransportPolling, true)
 			kws.fireEvent(EventError, nil, ErrSendQueueOverflow)
 		case enqueueRejectedDisconnect:
 			logf("error", "poll_queue_overflow_disconnect", "uuid", kws.UUID, "cap", PollQueueMaxFrames)
+			metrics().SendQueueOverflow(TransportPolling, false)
 			kws.disconnected(ErrSendQueueClosed)
 		}
 		return
 	}
-	msg := message{mType: messageType, data: messageBytes}
 	select {
 	case kws.queue <- msg:
 	default:
@@ -1969,12 +2213,14 @@ func (kws *Websocket) write(messageType int, messageBytes []byte) {
 			// Backpressure: drop the frame and surface an error event,
 			// keeping the connection alive for legitimate burst traffic.
 			logf("warn", "queue_overflow_drop", "uuid", kws.UUID, "queue_cap", cap(kws.queue))
+			metrics().SendQueueOverflow(TransportWebSocket, true)
 			kws.fireEvent(EventError, nil, ErrSendQueueOverflow)
 			return
 		}
 		// Queue is full and send is not draining; tear down rather than
 		// pin the calling goroutine.
 		logf("error", "queue_overflow_disconnect", "uuid", kws.UUID, "queue_cap", cap(kws.queue))
+		metrics().SendQueueOverflow(TransportWebSocket, false)
 		kws.disconnected(ErrSendQueueClosed)
 	}
 }
@@ -2014,8 +2260,21 @@ func (kws *Websocket) send(ctx context.Context) {
 			// against Close()'s Lock(), which writes the SIO DISCONNECT +
 			// close frame directly. Multiple send goroutines do not exist
 			// (only this one), so RLock here only blocks Close().
+			data := msg.data
+			if kws.eio3 && msg.mType == BinaryMessage {
+				data = eio3BinaryFrame(data)
+			}
 			kws.mu.RLock()
-			err := kws.Conn.WriteMessage(msg.mType, msg.data)
+			err := kws.Conn.WriteMessage(msg.mType, data)
+			for _, a := range msg.attachments {
+				if err != nil {
+					break
+				}
+				if kws.eio3 {
+					a = eio3BinaryFrame(a)
+				}
+				err = kws.Conn.WriteMessage(BinaryMessage, a)
+			}
 			kws.mu.RUnlock()
 			if err != nil {
 				kws.disconnected(err)
@@ -2138,13 +2397,21 @@ func (kws *Websocket) read(ctx context.Context) {
 			return
 		}
 
-		// Binary messages (socket.io binary events / raw binary data).
+		// Binary messages: attachments of a pending BINARY_EVENT /
+		// BINARY_ACK, otherwise raw binary data surfaced as EventMessage.
 		// Copy the bytes off the read buffer; listeners may spawn goroutines
 		// that observe payload.Data after the next ReadMessage() reuses msg.
 		if mType == BinaryMessage {
+			if kws.eio3 && len(msg) > 0 {
+				// Engine.IO v3 prefixes binary frames with the packet
+				// type byte (MESSAGE).
+				msg = msg[1:]
+			}
 			data := make([]byte, len(msg))
 			copy(data, msg)
-			kws.fireEvent(EventMessage, data, nil)
+			if !kws.addAttachment(data) {
+				kws.fireEvent(EventMessage, data, nil)
+			}
 			continue
 		}
 
@@ -2160,7 +2427,9 @@ func (kws *Websocket) read(ctx context.Context) {
 		// bytes.IndexByte so we never materialise a [][]byte for a
 		// frame that an attacker could fill with separators (which
 		// bytes.Split would amplify into millions of slice headers).
-		if bytes.IndexByte(msg, eioPacketSeparator) < 0 {
+		// EIO v3 has no batching: a frame is one packet, and RS is
+		// ordinary payload.
+		if kws.eio3 || bytes.IndexByte(msg, eioPacketSeparator) < 0 {
 			kws.dispatchEIOPacket(msg)
 		} else {
 			rest, count := msg, 0
@@ -2201,8 +2470,13 @@ func (kws *Websocket) dispatchEIOPacket(msg []byte) {
 	case eioPing:
 		// In EIO v4 the SERVER sends PING and the CLIENT replies with
 		// PONG. Receiving a PING from the peer means a non-conformant
-		// client (or a v3 client). Ignore quietly rather than echoing
-		// a PONG that would invert the heartbeat direction.
+		// client. Ignore quietly rather than echoing a PONG that would
+		// invert the heartbeat direction. In EIO v3 the client drives
+		// the heartbeat and expects its payload (e.g. "probe") echoed.
+		if kws.eio3 {
+			kws.write(TextMessage, append([]byte{eioPong}, msg[1:]...))
+			kws.fireEvent(EventPing, nil, nil)
+		}
 
 	case eioClose:
 		kws.disconnected(nil)
@@ -2237,70 +2511,42 @@ func (kws *Websocket) handleSIOPacket(payload []byte) {
 		kws.disconnected(ErrPollingBeforeConnect)
 		return
 	}
-	data := payload[1:]
-
-	// Capture optional namespace prefix (e.g., "/admin,") BEFORE stripping
-	// so we can verify it matches the namespace bound to this connection.
-	// ACK ids are per-namespace per the socket.io v5 spec, so a frame whose
-	// namespace does not match the connection's bound namespace must NOT be
-	// allowed to fire a pending callback (or event listener) registered on a
-	// different namespace.
-	var packetNS []byte
-	if len(data) > 0 && data[0] == '/' {
-		if idx := bytes.IndexByte(data, ','); idx >= 0 {
-			packetNS = data[:idx]
-			data = data[idx+1:]
-		} else {
-			packetNS = data
-			data = nil
-		}
+	// Attachments of a binary packet must follow its header back to
+	// back; any other Socket.IO packet in between is a protocol error,
+	// as in the reference decoder.
+	if kws.pendingBinary != nil {
+		kws.pendingBinary = nil
+		kws.fireEvent(EventError, payload, ErrBinaryPacketIncomplete)
+		kws.disconnected(ErrBinaryPacketIncomplete)
+		return
+	}
+	if sioType == sioBinaryEvent || sioType == sioBinaryAck {
+		// "5<n>-[/ns,][id][...]": the attachment count precedes the
+		// namespace, so it is parsed before the namespace split below.
+		kws.startBinaryPacket(sioType, payload[1:])
+		return
 	}
 
+	packetNS, data := splitSIONamespace(payload[1:])
+
 	switch sioType {
 	case sioEvent:
-		// Cross-namespace guard: reject events whose namespace prefix does
-		// not match the namespace bound to this connection. Otherwise a
-		// frame "42/admin,..." arriving on a "/" connection would fire
-		// listeners registered on the root namespace.
-		if !bytes.Equal(packetNS, kws.getNamespace()) {
-			kws.fireEvent(EventError, payload, fmt.Errorf("socketio: cross-namespace event dropped: packet=%q conn=%q", packetNS, kws.getNamespace()))
-			return
-		}
-		ackID, hasAck, rest, err := splitSIOAckID(data)
-		if err != nil {
-			kws.fireEvent(EventError, payload, err)
-			return
-		}
-		eventName, eventArgs, err := parseSIOEvent(rest)
-		if err != nil {
-			kws.fireEvent(EventError, payload, err)
-			return
-		}
-		// Reserved lifecycle event names ("connect", "disconnect",
-		// "connect_error") are fired by the framework only. A client
-		// emitting "42[\"connect\",...]" would otherwise double-fire
-		// EventConnect listeners and bypass our internal lifecycle.
-		if isReservedEventName(eventName) {
-			kws.fireEvent(EventError, payload, fmt.Errorf("socketio: client may not emit reserved event %q", eventName))
-			return
-		}
-		kws.fireEventWithAck(eventName, eventArgs, nil, ackID, hasAck)
+		kws.dispatchSIOEvent(payload, packetNS, data, nil)
 
 	case sioDisconnect:
 		// Per socket.io-protocol v5, "41/<ns>," targets a single namespace and
 		// must NOT tear down sibling namespaces sharing the same EIO
-		// connection. This implementation is single-namespace-per-conn
-		// (kws.namespace is one slice), so we cannot detach a namespace
-		// without ending the conn. Compromise (iter 9): if the inbound
-		// namespace matches the bound one, treat as a real disconnect; if it
-		// does not match, ignore the frame so a malicious or buggy client
-		// cannot kill the conn by addressing a foreign namespace. A full
-		// per-conn namespaces map is deferred to a later iteration.
+		// connection. A namespace connected after the first one is a child
+		// socket and detaches on its own. The namespace of the first CONNECT
+		// owns the transport, so leaving it ends the connection (and every
+		// child with it). A namespace that is not connected is ignored so a
+		// malicious or buggy client cannot kill the conn by addressing a
+		// foreign namespace.
 		ns := extractSIONamespace(payload[1:])
-		if !bytes.Equal(ns, kws.getNamespace()) {
-			return
+		if target := kws.namespaceSocket(ns); target != nil {
+			target.noRecovery.Store(true)
+			target.disconnected(nil)
 		}
-		kws.disconnected(nil)
 
 	case sioConnect:
 		// CONNECT path. For polling sessions the SIO CONNECT packet
@@ -2311,7 +2557,11 @@ func (kws *Websocket) handleSIOPacket(payload []byte) {
 		// validation, namespace/auth capture, and EventConnect dispatch
 		// that handshake() does for WebSocket sessions.
 		ns, auth := extractSIOConnect(payload[1:])
+		if kws.eio3 {
+			ns, auth = splitEIO3Namespace(ns)
+		}
 		if !isValidNamespace(ns) {
+			rejectHandshake("invalid_namespace", "uuid", kws.UUID, "namespace", string(ns))
 			_ = kws.writeConnectError(ns, `{"message":"Invalid namespace"}`)
 			if kws.pollQ != nil {
 				kws.disconnected(ErrInvalidNamespace)
@@ -2326,11 +2576,17 @@ func (kws *Websocket) handleSIOPacket(payload []byte) {
 			// Emit calls inside the callback cannot overtake the namespace
 			// connect confirmation.
 			if !isValidAuthPayload(auth) {
-				logf("warn", "invalid_auth_payload", "uuid", kws.UUID, "namespace", string(ns), "size", len(auth))
+				rejectHandshake("invalid_auth_payload", "uuid", kws.UUID, "namespace", string(ns), "size", len(auth))
 				_ = kws.writeConnectError(ns, `{"message":"Invalid auth payload"}`)
 				kws.disconnected(ErrInvalidAuthPayload)
 				return
 			}
+			if !namespaceAllowed(ns) {
+				rejectHandshake("unknown_namespace", "uuid", kws.UUID, "namespace", string(ns))
+				_ = kws.writeConnectError(ns, `{"message":"Invalid namespace"}`)
+				kws.disconnected(ErrInvalidNamespace)
+				return
+			}
 			if t := kws.handshakeTimer.Load(); t != nil {
 				t.Stop()
 			}
@@ -2342,11 +2598,17 @@ func (kws *Websocket) handleSIOPacket(payload []byte) {
 				copy(kws.handshakeAuth, auth)
 			}
 			kws.mu.Unlock()
-			ackPayload, err := json.Marshal(struct {
-				SID string `json:"sid"`
-			}{SID: kws.UUID})
-			if err == nil {
-				kws.write(TextMessage, buildSIOConnectAck(nsCopy, ackPayload))
+			if err := authorize(kws); err != nil {
+				rejectHandshake("namespace_rejected", "uuid", kws.UUID, "namespace", string(ns), "err", err.Error())
+				_ = kws.writeConnectError(ns, connectErrorMessage(err))
+				kws.disconnected(err)
+				return
+			}
+			missed := kws.recoverSession()
+			kws.write(TextMessage, buildSIOConnectAck(nsCopy, kws.connectAckPayload(kws.GetUUID())))
+			kws.connectionOpened()
+			for _, msg := range missed {
+				kws.enqueue(msg)
 			}
 			pollCallback := kws.pollCallback
 			kws.pollCallback = nil
@@ -2359,55 +2621,135 @@ func (kws *Websocket) handleSIOPacket(payload []byte) {
 				kws.disconnected(nil)
 				return
 			}
+			if !kws.runConnectHandlers() {
+				return
+			}
 			if kws.connectFired.CompareAndSwap(false, true) {
 				kws.fireEvent(EventConnect, nil, nil)
 			}
 			return
 		}
-		// Late namespace CONNECT (after the initial handshake): confirm
-		// via the send queue, mirroring the namespace, so we do not race
-		// the read loop.
-		ackPayload, err := json.Marshal(struct {
-			SID string `json:"sid"`
-		}{SID: kws.GetUUID()})
-		if err == nil {
-			kws.write(TextMessage, buildSIOConnectAck(ns, ackPayload))
-		}
+		// Late namespace CONNECT (after the initial handshake): attach
+		// another namespace to this transport.
+		kws.connectNamespace(ns, auth)
 
 	case sioAck:
-		// 43[/ns,]<id>[<data>] - response to a server-initiated EmitWithAck.
-		// Cross-namespace guard: ACK ids are per-namespace per the
-		// socket.io v5 spec, so a frame "43/admin,7[...]" arriving on a
-		// connection bound to "/" must NOT fire the root-namespace
-		// pending callback id 7. Drop silently to keep the original
-		// callback waiting for a properly-namespaced ack.
-		if !bytes.Equal(packetNS, kws.getNamespace()) {
-			return
+		kws.dispatchSIOAck(packetNS, data, nil)
+
+	default:
+		// Unknown SIO packet type: surface payload as a raw message.
+		kws.fireEvent(EventMessage, payload, nil)
+	}
+}
+
+// splitSIONamespace splits the optional namespace prefix (e.g.
+// "/admin,") off a Socket.IO packet body (the bytes after the type byte,
+// or after the "<n>-" count for binary packets). The namespace is
+// captured BEFORE stripping so callers can route the packet to the
+// socket connected to that namespace. ACK ids are per-namespace per the
+// socket.io v5 spec, so a frame must NOT be allowed to fire a pending
+// callback (or event listener) registered on a different namespace.
+func splitSIONamespace(data []byte) (packetNS, rest []byte) {
+	if len(data) > 0 && data[0] == '/' {
+		if idx := bytes.IndexByte(data, ','); idx >= 0 {
+			return data[:idx], data[idx+1:]
 		}
-		ackID, has, rest, err := splitSIOAckID(data)
-		if err != nil || !has {
+		return data, nil
+	}
+	return nil, data
+}
+
+// dispatchSIOEvent fires the listeners for an EVENT packet, or a
+// reassembled BINARY_EVENT when attachments is non-nil, on the socket
+// connected to packetNS. payload is the whole packet, used as context for
+// EventError.
+func (kws *Websocket) dispatchSIOEvent(payload, packetNS, data []byte, attachments [][]byte) {
+	// Cross-namespace guard: reject events for a namespace this
+	// connection has not joined. Otherwise a frame "42/admin,..."
+	// arriving on a "/" connection would fire listeners registered on
+	// the root namespace.
+	target := kws.namespaceSocket(packetNS)
+	if target == nil {
+		kws.fireEvent(EventError, payload, fmt.Errorf("socketio: cross-namespace event dropped: packet=%q conn=%q", packetNS, kws.getNamespace()))
+		return
+	}
+	kws = target
+	ackID, hasAck, rest, err := splitSIOAckID(data)
+	if err != nil {
+		kws.fireEvent(EventError, payload, err)
+		return
+	}
+	eventName, eventArgs, err := parseSIOEvent(rest)
+	if err != nil {
+		kws.fireEvent(EventError, payload, err)
+		return
+	}
+	// Reserved lifecycle event names ("connect", "disconnect",
+	// "connect_error") are fired by the framework only. A client
+	// emitting "42[\"connect\",...]" would otherwise double-fire
+	// EventConnect listeners and bypass our internal lifecycle.
+	if isReservedEventName(eventName) {
+		kws.fireEvent(EventError, payload, fmt.Errorf("socketio: client may not emit reserved event %q", eventName))
+		return
+	}
+	if hasListener(kws.getNamespace(), eventName) {
+		metrics().EventReceived(eventName)
+	} else {
+		metrics().EventReceived("")
+	}
+	if !kws.allowEvent(eventName, payload) {
+		return
+	}
+	if attachments != nil {
+		if err := substitutePlaceholders(eventArgs, attachments); err != nil {
+			kws.fireEvent(EventError, payload, err)
 			return
 		}
-		var arr []json.RawMessage
-		if err := json.Unmarshal(rest, &arr); err != nil {
-			return
+	}
+	kws.fireEventWithAck(eventName, eventArgs, nil, ackID, hasAck, attachments)
+}
+
+// dispatchSIOAck resolves an ACK packet (43[/ns,]<id>[<data>]), or a
+// reassembled BINARY_ACK when attachments is non-nil, against the
+// pending server-initiated EmitWithAck callbacks of the socket connected
+// to packetNS.
+func (kws *Websocket) dispatchSIOAck(packetNS, data []byte, attachments [][]byte) {
+	// Cross-namespace guard: ACK ids are per-namespace per the
+	// socket.io v5 spec, so a frame "43/admin,7[...]" must only ever
+	// resolve id 7 of the "/admin" socket, never the root-namespace
+	// pending callback id 7. Drop silently when the namespace is not
+	// connected to keep the original callback waiting for a
+	// properly-namespaced ack.
+	target := kws.namespaceSocket(packetNS)
+	if target == nil {
+		return
+	}
+	kws = target
+	ackID, has, rest, err := splitSIOAckID(data)
+	if err != nil || !has {
+		return
+	}
+	var arr []json.RawMessage
+	if err := json.Unmarshal(rest, &arr); err != nil {
+		return
+	}
+	var args [][]byte
+	if len(arr) > 0 {
+		args = make([][]byte, len(arr))
+		for i, raw := range arr {
+			// Copy each raw JSON value off the read buffer so callers may
+			// retain it past the next ReadMessage (the underlying
+			// websocket library reuses the read buffer between frames).
+			args[i] = append([]byte(nil), raw...)
 		}
-		var args [][]byte
-		if len(arr) > 0 {
-			args = make([][]byte, len(arr))
-			for i, raw := range arr {
-				// Copy each raw JSON value off the read buffer so callers may
-				// retain it past the next ReadMessage (the underlying
-				// websocket library reuses the read buffer between frames).
-				args[i] = append([]byte(nil), raw...)
-			}
+	}
+	if attachments != nil {
+		if err := substitutePlaceholders(args, attachments); err != nil {
+			kws.fireEvent(EventError, nil, err)
+			return
 		}
-		kws.deliverOutboundAck(ackID, args)
-
-	default:
-		// Unknown SIO packet type: surface payload as a raw message.
-		kws.fireEvent(EventMessage, payload, nil)
 	}
+	kws.deliverOutboundAck(ackID, args)
 }
 
 // disconnected is the single tear-down entry point.
@@ -2458,9 +2800,33 @@ func (kws *Websocket) disconnected(err error) {
 		return
 	}
 
-	// Remove from the pool BEFORE firing user events so that listeners
-	// observing pool.all() do not see this dying connection.
+	// Namespaces multiplexed on this transport cannot outlive it; a child
+	// socket going away on its own only leaves its parent's set.
+	for _, child := range kws.detachChildren() {
+		child.disconnected(err)
+	}
+	if kws.parent != nil {
+		kws.parent.removeChild(kws)
+	}
+
+	// A dropped session is snapshotted for connection state recovery
+	// while its rooms are still known, and parked once it is gone.
+	parkedState := kws.parkState()
+	if kws.engineSID != "" {
+		pollSessions.delete(kws.engineSID)
+		metrics().PollingSessionClosed()
+	}
+	kws.connectionClosed()
+
+	// Remove from the pool and every room BEFORE firing user events so
+	// that listeners observing pool.all() or broadcasting to a room do
+	// not see this dying connection.
 	pool.delete(kws.GetUUID())
+	adapter().DelAll(kws.GetUUID())
+	if parkedState != nil {
+		park(parkedState)
+		kws.parked.Store(true)
+	}
 
 	// Drain pending outbound ack callbacks: invoke each with
 	// ErrAckDisconnected so callers can distinguish "ack received" (cb
@@ -2525,7 +2891,7 @@ func (kws *Websocket) fireEvent(event string, data []byte, error error) {
 	if data != nil {
 		args = [][]byte{data}
 	}
-	kws.fireEventWithAck(event, args, error, 0, false)
+	kws.fireEventWithAck(event, args, error, 0, false, nil)
 }
 
 // fireEventWithAck is the ack-id aware multi-arg variant. ackID/hasAck are
@@ -2535,15 +2901,24 @@ func (kws *Websocket) fireEvent(event string, data []byte, error error) {
 // args holds the raw-JSON event arguments. Data is populated from args[0]
 // for backwards compatibility with handlers that consume the single-arg
 // shape. SocketAttributes is a defensive copy so listeners cannot race
-// with concurrent SetAttribute mutations.
-func (kws *Websocket) fireEventWithAck(event string, args [][]byte, fireErr error, ackID uint64, hasAck bool) {
+// with concurrent SetAttribute mutations. attachments is non-nil only for
+// reassembled binary events.
+func (kws *Websocket) fireEventWithAck(event string, args [][]byte, fireErr error, ackID uint64, hasAck bool, attachments [][]byte) {
 	callbacks := listeners.get(event)
+	if nsp := lookupNamespace(kws.getNamespace()); nsp != nil {
+		if scoped := nsp.listeners.get(event); len(scoped) > 0 {
+			// Full slice expression: never append into the shared
+			// registry backing array.
+			callbacks = append(callbacks[:len(callbacks):len(callbacks)], scoped...)
+		}
+	}
 	if len(callbacks) == 0 {
 		return
 	}
 
 	kws.mu.RLock()
 	uuid := kws.UUID
+	namespace := nsName(kws.namespace)
 	attrs := make(map[string]any, len(kws.attributes))
 	for k, v := range kws.attributes {
 		attrs[k] = v
@@ -2588,10 +2963,12 @@ func (kws *Websocket) fireEventWithAck(event string, args [][]byte, fireErr erro
 			cb(&EventPayload{
 				Kws:              kws,
 				Name:             event,
+				Namespace:        namespace,
 				SocketUUID:       uuid,
 				SocketAttributes: attrs,
 				Data:             firstArg,
 				Args:             args,
+				Attachments:      attachments,
 				Error:            fireErr,
 				AckID:            ackID,
 				HasAck:           hasAck,
@@ -444,6 +444,9 @@ func resetSIOGlobals(t *testing.T) {
 	t.Helper()
 	pool.reset()
 	listeners.reset()
+	namespaces.reset()
+	rateLimits.reset()
+	currentAdapter.Store(&adapterRef{Adapter: NewMemoryAdapter(), local: true})
 	t.Cleanup(func() {
 		// Close every still-pooled connection so its read/send/pong
 		// goroutines exit before the next test snapshots them.
@@ -483,6 +486,7 @@ func resetSIOGlobals(t *testing.T) {
 		}
 		pool.reset()
 		listeners.reset()
+		namespaces.reset()
 	})
 }
 
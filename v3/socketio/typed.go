@@ -0,0 +1,154 @@
+package socketio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrInvalidArgument wraps the decoding and validation failures of an
+// OnTyped handler's argument. It is delivered through EventError and, when
+// the client asked for an ack, as the ack error.
+var ErrInvalidArgument = errors.New("socketio: invalid event argument")
+
+// Validator is implemented by OnTyped argument types that check
+// themselves after decoding. A non-nil error rejects the event before the
+// handler runs.
+type Validator interface {
+	Validate() error
+}
+
+// AckError is a handler error that carries data for the client. OnTyped
+// acks it as {"message": Message, "data": Data}; any other error is acked
+// as {"message": err.Error()}. Data must be JSON-encodable.
+type AckError struct {
+	Message string
+	Data    any
+}
+
+func (e *AckError) Error() string {
+	return e.Message
+}
+
+// OnTyped registers a listener for event that decodes the first argument
+// into T, validates it and calls handler. The listener is registered with
+// On, so it fires for every namespace; use OnTypedOf for a single one.
+//
+// The argument is decoded with encoding/json; a missing argument decodes
+// as JSON null. When T is []byte and the argument is a binary attachment,
+// the raw bytes are passed through; any other argument is decoded as JSON,
+// so a string must hold base64. When T or *T implements Validator,
+// Validate runs after decoding.
+//
+// When the client asked for an ack, the result is sent as an error-first
+// ack, the shape of a Node.js callback(err, result): [null, result] on
+// success, [{"message": ..., "data": ...}] when decoding, validation or
+// handler fail. Without an ack the result is dropped. Decoding and
+// validation failures always fire EventError on the socket, handler
+// failures only when there is no ack to carry them.
+func OnTyped[T, R any](event string, handler func(ep *EventPayload, arg T) (R, error)) {
+	On(event, typedCallback(event, handler))
+}
+
+// OnTypedOf is OnTyped for the sockets of namespace n only, registered
+// with n.On.
+func OnTypedOf[T, R any](n *Namespace, event string, handler func(ep *EventPayload, arg T) (R, error)) {
+	n.On(event, typedCallback(event, handler))
+}
+
+// typedCallback wraps handler into the listener of OnTyped.
+func typedCallback[T, R any](event string, handler func(ep *EventPayload, arg T) (R, error)) eventCallback {
+	return func(ep *EventPayload) {
+		arg, err := decodeTypedArg[T](ep.Args, ep.Attachments)
+		if err != nil {
+			ep.Kws.fireEvent(EventError, []byte(event), err)
+			ackTypedError(ep, err)
+			return
+		}
+		result, err := handler(ep, arg)
+		if err != nil {
+			if !ep.HasAck {
+				ep.Kws.fireEvent(EventError, []byte(event), err)
+			}
+			ackTypedError(ep, err)
+			return
+		}
+		if !ep.HasAck {
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			ep.Kws.fireEvent(EventError, []byte(event), err)
+			ackTypedError(ep, err)
+			return
+		}
+		_ = ep.Ack([]byte("null"), b)
+	}
+}
+
+// decodeTypedArg decodes the first of args into a T and validates it.
+// attachments are the binary attachments of the packet, one of which may
+// have been substituted for the first argument.
+func decodeTypedArg[T any](args, attachments [][]byte) (T, error) {
+	var arg T
+	raw := []byte("null")
+	if len(args) > 0 && len(args[0]) > 0 {
+		raw = args[0]
+		if b, ok := any(&arg).(*[]byte); ok && isAttachment(raw, attachments) {
+			*b = raw
+			return arg, nil
+		}
+	}
+	if err := json.Unmarshal(raw, &arg); err != nil {
+		return arg, fmt.Errorf("%w: %w", ErrInvalidArgument, err)
+	}
+	var v any = arg
+	if rv := reflect.ValueOf(v); !rv.IsValid() || (rv.Kind() == reflect.Pointer && rv.IsNil()) {
+		// null into a pointer or interface: nothing to validate.
+		return arg, nil
+	}
+	if _, ok := v.(Validator); !ok {
+		v = &arg
+	}
+	if val, ok := v.(Validator); ok {
+		if err := val.Validate(); err != nil {
+			return arg, fmt.Errorf("%w: %w", ErrInvalidArgument, err)
+		}
+	}
+	return arg, nil
+}
+
+// isAttachment reports whether raw is one of attachments, rather than a
+// JSON argument that only looks like bytes. substitutePlaceholders stores
+// the attachment slice itself, so identity is enough.
+func isAttachment(raw []byte, attachments [][]byte) bool {
+	for _, att := range attachments {
+		if len(att) == len(raw) && len(raw) > 0 && &att[0] == &raw[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// ackTypedError acks err as the first callback argument, if the client
+// asked for an ack.
+func ackTypedError(ep *EventPayload, err error) {
+	if !ep.HasAck {
+		return
+	}
+	payload := struct {
+		Message string `json:"message"`
+		Data    any    `json:"data,omitempty"`
+	}{Message: err.Error()}
+	var ae *AckError
+	if errors.As(err, &ae) {
+		payload.Data = ae.Data
+	}
+	b, mErr := json.Marshal(payload)
+	if mErr != nil {
+		payload.Data = nil
+		b, _ = json.Marshal(payload)
+	}
+	_ = ep.Ack(b)
+}
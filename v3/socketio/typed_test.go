@@ -0,0 +1,128 @@
+package socketio
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+type joinRequest struct {
+	Room string `json:"room"`
+}
+
+func (r joinRequest) Validate() error {
+	if r.Room == "" {
+		return errors.New("room is required")
+	}
+	return nil
+}
+
+func TestDecodeTypedArg(t *testing.T) {
+	req, err := decodeTypedArg[joinRequest]([][]byte{[]byte(`{"room":"a"}`)}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "a", req.Room)
+
+	_, err = decodeTypedArg[joinRequest](nil, nil)
+	require.ErrorIs(t, err, ErrInvalidArgument)
+	_, err = decodeTypedArg[joinRequest]([][]byte{[]byte(`"nope"`)}, nil)
+	require.ErrorIs(t, err, ErrInvalidArgument)
+
+	// A nil pointer is not validated.
+	p, err := decodeTypedArg[*joinRequest](nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, p)
+
+	// Attachments pass through as raw bytes.
+	attachments := [][]byte{{0xff, 0x00}}
+	b, err := decodeTypedArg[[]byte](attachments, attachments)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xff, 0x00}, b)
+}
+
+func TestDecodeTypedArgBytesFromAttachment(t *testing.T) {
+	// An attachment is passed through even when its bytes are valid JSON.
+	attachments := [][]byte{[]byte(`"aGk="`)}
+	b, err := decodeTypedArg[[]byte](attachments, attachments)
+	require.NoError(t, err)
+	require.Equal(t, []byte(`"aGk="`), b)
+}
+
+func TestDecodeTypedArgBytesFromJSON(t *testing.T) {
+	// A JSON argument is decoded, also next to an equal attachment.
+	b, err := decodeTypedArg[[]byte]([][]byte{[]byte(`"aGk="`)}, [][]byte{[]byte(`"aGk="`)})
+	require.NoError(t, err)
+	require.Equal(t, []byte("hi"), b)
+
+	_, err = decodeTypedArg[[]byte]([][]byte{{0xff, 0x00}}, nil)
+	require.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestOnTypedAcks(t *testing.T) {
+	resetSIOGlobals(t)
+
+	type joined struct {
+		Room    string `json:"room"`
+		Members int    `json:"members"`
+	}
+	OnTyped("join", func(ep *EventPayload, req joinRequest) (joined, error) {
+		if req.Room == "closed" {
+			return joined{}, &AckError{Message: "room closed", Data: map[string]string{"room": req.Room}}
+		}
+		ep.Kws.Join(req.Room)
+		return joined{Room: req.Room, Members: len(adapter().Members(req.Room))}, nil
+	})
+	errs := make(chan error, 1)
+	On(EventError, func(ep *EventPayload) { errs <- ep.Error })
+
+	ln, teardown := newSIOTestServer(t, func(_ *Websocket) {})
+	defer teardown()
+	conn := dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, sioHandshake(t, conn))
+
+	send := func(frame string) {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(frame)))
+	}
+	send(`421["join",{"room":"lobby"}]`)
+	require.Equal(t, `431[null,{"room":"lobby","members":1}]`, readText(t, conn))
+
+	send(`422["join",{"room":"closed"}]`)
+	require.Equal(t, `432[{"message":"room closed","data":{"room":"closed"}}]`, readText(t, conn))
+
+	send(`423["join",{}]`)
+	require.Equal(t, `433[{"message":"socketio: invalid event argument: room is required"}]`, readText(t, conn))
+	require.ErrorIs(t, <-errs, ErrInvalidArgument)
+
+	// Without an ack, failures surface as EventError.
+	send(`42["join",{"room":"closed"}]`)
+	select {
+	case err := <-errs:
+		require.EqualError(t, err, "room closed")
+	case <-time.After(3 * time.Second):
+		t.Fatal("EventError not fired")
+	}
+}
+
+func TestOnTypedOf(t *testing.T) {
+	resetSIOGlobals(t)
+
+	OnTypedOf(Of("/admin"), "join", func(_ *EventPayload, req joinRequest) (string, error) {
+		return req.Room, nil
+	})
+
+	ln, teardown := newSIOTestServer(t, func(_ *Websocket) {})
+	defer teardown()
+	conn := dialSIO(t, ln)
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, sioHandshake(t, conn))
+	connectNamespace(t, conn, "/admin")
+
+	// The default namespace has no listener, so only the second join is
+	// acked.
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`421["join",{"room":"lobby"}]`)))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`42/admin,2["join",{"room":"ops"}]`)))
+	require.Equal(t, `43/admin,2[null,"ops"]`, readText(t, conn))
+}
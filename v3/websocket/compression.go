@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// defaultCompressionLevel is the level of fasthttp/websocket when none is set.
+const defaultCompressionLevel = flate.BestSpeed
+
+// ErrInvalidCompressionLevel is returned by SetCompressionLevel for a level
+// outside flate.HuffmanOnly to flate.BestCompression.
+var ErrInvalidCompressionLevel = errors.New("websocket: invalid compression level")
+
+// compression holds the per-connection write compression settings. They are
+// stored atomically so they can be changed from any goroutine and are applied
+// to the underlying connection by the writer right before each message.
+type compression struct {
+	disabled  atomic.Bool
+	level     atomic.Int32
+	threshold atomic.Int64
+	// applied is the level last set on the underlying connection. It is only
+	// touched by the writer.
+	applied int
+}
+
+func (c *compression) reset(level, threshold int) {
+	if level == 0 {
+		level = defaultCompressionLevel
+	}
+	c.disabled.Store(false)
+	c.level.Store(int32(level))
+	c.threshold.Store(int64(threshold))
+	c.applied = defaultCompressionLevel
+}
+
+func validCompressionLevel(level int) bool {
+	return flate.HuffmanOnly <= level && level <= flate.BestCompression
+}
+
+// EnableWriteCompression enables and disables write compression of
+// subsequent text and binary messages. It has no effect unless per message
+// compression was negotiated, see Config.EnableCompression. Unlike the
+// method of the underlying connection it may be called concurrently with
+// the writer.
+func (conn *Conn) EnableWriteCompression(enable bool) {
+	conn.compression.disabled.Store(!enable)
+}
+
+// SetCompressionLevel sets the flate compression level of subsequent text
+// and binary messages. Valid levels range from flate.HuffmanOnly to
+// flate.BestCompression. Unlike the method of the underlying connection it
+// may be called concurrently with the writer.
+func (conn *Conn) SetCompressionLevel(level int) error {
+	if !validCompressionLevel(level) {
+		return ErrInvalidCompressionLevel
+	}
+	conn.compression.level.Store(int32(level))
+	return nil
+}
+
+// SetCompressionThreshold sets the size in bytes below which WriteMessage
+// sends a message uncompressed. Zero compresses every message.
+func (conn *Conn) SetCompressionThreshold(n int) {
+	conn.compression.threshold.Store(int64(n))
+}
+
+// prepareWrite applies the compression settings for a message of size
+// bytes, or of unknown size when size is negative.
+func (conn *Conn) prepareWrite(size int) {
+	c := &conn.compression
+	if level := int(c.level.Load()); level != c.applied {
+		if conn.Conn.SetCompressionLevel(level) == nil {
+			c.applied = level
+		}
+	}
+	enable := !c.disabled.Load()
+	if enable && size >= 0 && int64(size) < c.threshold.Load() {
+		enable = false
+	}
+	conn.Conn.EnableWriteCompression(enable)
+}
+
+// WriteMessage is a helper method for getting a writer using NextWriter,
+// writing the message and closing the writer. Messages smaller than the
+// compression threshold are sent uncompressed.
+func (conn *Conn) WriteMessage(messageType int, data []byte) error {
+	conn.prepareWrite(len(data))
+	return conn.Conn.WriteMessage(messageType, data)
+}
+
+// NextWriter returns a writer for the next message to send. The size of a
+// streamed message is not known up front, so the compression threshold does
+// not apply to it.
+func (conn *Conn) NextWriter(messageType int) (io.WriteCloser, error) {
+	conn.prepareWrite(-1)
+	return conn.Conn.NextWriter(messageType)
+}
+
+// WriteJSON writes the JSON encoding of v as a message. It is sent through
+// NextWriter, so the compression threshold does not apply to it.
+func (conn *Conn) WriteJSON(v interface{}) error {
+	w, err := conn.NextWriter(TextMessage)
+	if err != nil {
+		return err
+	}
+	err1 := json.NewEncoder(w).Encode(v)
+	err2 := w.Close()
+	if err1 != nil {
+		return err1
+	}
+	return err2
+}
@@ -0,0 +1,73 @@
+package event
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Codec encodes the values sent with EmitValue and decodes the messages
+// handed to Typed listeners. JSONCodec is built in; the msgpackcodec and
+// protobufcodec modules provide MessagePack and Protocol Buffers.
+type Codec interface {
+	// Marshal encodes v.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes data into the value v points to.
+	Unmarshal(data []byte, v any) error
+	// MessageType is the frame type of encoded values, TextMessage or
+	// BinaryMessage.
+	MessageType() int
+}
+
+// JSONCodec encodes values with encoding/json into text frames.
+type JSONCodec struct{}
+
+// Marshal implements Codec.
+func (JSONCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal implements Codec.
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// MessageType implements Codec.
+func (JSONCodec) MessageType() int { return TextMessage }
+
+// EmitValue encodes v with the configured Codec and writes it to the
+// current connection as a text or binary frame, as the codec dictates.
+func (kws *Websocket) EmitValue(v any) error {
+	data, err := kws.settings.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	kws.write(kws.settings.codec.MessageType(), data)
+	return nil
+}
+
+// Typed adapts a listener that takes a decoded value to an EventCallback,
+// for use with any form of On:
+//
+//	s.On("chat", event.Typed(func(ep *event.EventPayload, msg ChatMessage) {
+//	    log.Println(msg.From, msg.Text)
+//	}))
+//
+// The payload's Data is decoded into a new T with the connection's Codec.
+// For a pointer type T a new value is allocated and decoded into. When
+// decoding fails the listener is skipped and EventError fires with the
+// message and the error.
+func Typed[T any](listener func(payload *EventPayload, v T)) EventCallback {
+	pointer := reflect.TypeFor[T]().Kind() == reflect.Pointer
+	return func(payload *EventPayload) {
+		var v T
+		target := any(&v)
+		if pointer {
+			v = reflect.New(reflect.TypeFor[T]().Elem()).Interface().(T)
+			target = v
+		}
+		if err := payload.Kws.settings.codec.Unmarshal(payload.Data, target); err != nil {
+			// A failing listener for EventError must not fire it again.
+			if payload.Name != EventError {
+				payload.Kws.fireEvent(EventError, payload.Data, err)
+			}
+			return
+		}
+		listener(payload, v)
+	}
+}
@@ -0,0 +1,83 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type chatMessage struct {
+	From string `json:"from"`
+	Text string `json:"text"`
+}
+
+// binaryJSONCodec is JSONCodec with binary frames.
+type binaryJSONCodec struct{ JSONCodec }
+
+func (binaryJSONCodec) MessageType() int { return BinaryMessage }
+
+// failingCodec fails every call.
+type failingCodec struct{ JSONCodec }
+
+var errCodec = errors.New("codec failed")
+
+func (failingCodec) Marshal(any) ([]byte, error) { return nil, errCodec }
+
+func TestJSONCodec(t *testing.T) {
+	codec := JSONCodec{}
+	data, err := codec.Marshal(chatMessage{From: "alice", Text: "hi"})
+	require.NoError(t, err)
+	var got chatMessage
+	require.NoError(t, codec.Unmarshal(data, &got))
+	require.Equal(t, chatMessage{From: "alice", Text: "hi"}, got)
+	require.Equal(t, TextMessage, codec.MessageType())
+}
+
+func TestEmitValueUsesCodecFrameType(t *testing.T) {
+	resetState()
+	kws := createWS()
+	kws.settings = resolveSettings(Config{Codec: binaryJSONCodec{}})
+
+	require.NoError(t, kws.EmitValue(chatMessage{From: "alice", Text: "hi"}))
+	msg := <-kws.queue
+	require.Equal(t, BinaryMessage, msg.mType)
+	var got chatMessage
+	require.NoError(t, json.Unmarshal(msg.data, &got))
+	require.Equal(t, "alice", got.From)
+
+	kws.settings = resolveSettings(Config{})
+	require.NoError(t, kws.EmitValue(chatMessage{From: "bob"}))
+	msg = <-kws.queue
+	require.Equal(t, TextMessage, msg.mType)
+	require.JSONEq(t, `{"from":"bob","text":""}`, string(msg.data))
+
+	kws.settings = resolveSettings(Config{Codec: failingCodec{}})
+	require.ErrorIs(t, kws.EmitValue(chatMessage{}), errCodec)
+}
+
+func TestTypedListener(t *testing.T) {
+	resetState()
+	kws := createWS()
+	kws.settings = resolveSettings(Config{})
+
+	values := make(chan string, 1)
+	errs := make(chan error, 1)
+	kws.On("chat", Typed(func(_ *EventPayload, v *chatMessage) {
+		values <- v.Text
+	}))
+	kws.On(EventError, func(p *EventPayload) { errs <- p.Error })
+
+	kws.fireEvent("chat", []byte(`{"from":"alice","text":"hi"}`), nil)
+	require.Equal(t, "hi", <-values)
+
+	kws.fireEvent("chat", []byte{0xff}, nil)
+	require.Error(t, <-errs)
+	require.Empty(t, values)
+
+	plain := make(chan chatMessage, 1)
+	kws.On("plain", Typed(func(_ *EventPayload, v chatMessage) { plain <- v }))
+	kws.fireEvent("plain", []byte(`{"from":"alice","text":"hi"}`), nil)
+	require.Equal(t, chatMessage{From: "alice", Text: "hi"}, <-plain)
+}
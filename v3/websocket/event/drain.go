@@ -0,0 +1,155 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/contrib/v3/websocket"
+)
+
+// DrainConfig tunes StagedDrain.
+type DrainConfig struct {
+	// Duration is the time over which the connections are closed. The
+	// last wave closes at the end of it. Defaults to 30s.
+	Duration time.Duration
+	// Waves is the number of batches the connections are closed in, one
+	// every Duration / Waves. Defaults to 10.
+	Waves int
+	// MaxReconnectDelay bounds the delay sent in the reconnect hint. It is
+	// split into Waves equal slots and a connection closed in wave n gets
+	// a random delay from slot n, so with the default the clients of a
+	// wave are asked to reconnect just before that wave closes them.
+	// Defaults to Duration.
+	MaxReconnectDelay time.Duration
+	// ReconnectMessage builds the reconnect hint sent to every connection
+	// when the drain starts; delay is the jittered time the client should
+	// wait before it reconnects. Returning nil skips the hint. Defaults to
+	// the envelope {"event":"reconnect","data":{"delay":<milliseconds>}}.
+	ReconnectMessage func(delay time.Duration) []byte
+	// CloseCode is the close code of the close frames. Defaults to
+	// websocket.CloseServiceRestart (1012).
+	CloseCode int
+	// CloseReason is the reason of the close frames. Defaults to
+	// "server draining".
+	CloseReason string
+	// Progress is called after every wave. Defaults to nil.
+	Progress func(progress DrainProgress)
+}
+
+// DrainProgress reports how far StagedDrain got.
+type DrainProgress struct {
+	// Wave is the number of the wave just closed, starting at 1.
+	Wave int
+	// Waves is the number of waves.
+	Waves int
+	// Closed is the number of connections closed so far, including those
+	// the clients closed themselves.
+	Closed int
+	// Total is the number of connections when the drain started.
+	Total int
+}
+
+func resolveDrainConfig(config ...DrainConfig) DrainConfig {
+	var cfg DrainConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Duration <= 0 {
+		cfg.Duration = 30 * time.Second
+	}
+	if cfg.Waves <= 0 {
+		cfg.Waves = 10
+	}
+	if cfg.MaxReconnectDelay <= 0 {
+		cfg.MaxReconnectDelay = cfg.Duration
+	}
+	if cfg.ReconnectMessage == nil {
+		cfg.ReconnectMessage = defaultReconnectMessage
+	}
+	if cfg.CloseCode == 0 {
+		cfg.CloseCode = websocket.CloseServiceRestart
+	}
+	if cfg.CloseReason == "" {
+		cfg.CloseReason = "server draining"
+	}
+	return cfg
+}
+
+func defaultReconnectMessage(delay time.Duration) []byte {
+	frame, _ := json.Marshal(envelope{
+		Event: "reconnect",
+		Data:  json.RawMessage(`{"delay":` + strconv.FormatInt(delay.Milliseconds(), 10) + `}`),
+	})
+	return frame
+}
+
+// StagedDrain shuts s down without sending every client to the next
+// replica at once. It marks s as draining, so new upgrades are refused,
+// sends each connection a reconnect hint with a delay matching its wave,
+// and then closes the connections in Waves evenly spread over Duration,
+// calling Progress after each wave.
+//
+// If ctx is done first, the remaining connections are force closed as by
+// CloseAll and ctx.Err() is returned.
+func (s *Server) StagedDrain(ctx context.Context, config ...DrainConfig) error {
+	cfg := resolveDrainConfig(config...)
+	s.Drain()
+
+	var conns []*Websocket
+	for _, c := range s.pool.all() {
+		if kws, ok := c.(*Websocket); ok {
+			conns = append(conns, kws)
+		}
+	}
+	rand.Shuffle(len(conns), func(i, j int) { conns[i], conns[j] = conns[j], conns[i] })
+
+	// Full send queues may hold up the hints until the first wave is due,
+	// after which they are only sent to connections with room. The waves
+	// are timed from before the hints, so they never stretch Duration.
+	start := time.Now()
+	interval := cfg.Duration / time.Duration(cfg.Waves)
+	hintCtx, cancel := context.WithDeadline(ctx, start.Add(interval))
+	defer cancel()
+	slot := cfg.MaxReconnectDelay / time.Duration(cfg.Waves)
+	begin := 0
+	for wave := 1; wave <= cfg.Waves; wave++ {
+		end := len(conns) * wave / cfg.Waves
+		for _, kws := range conns[begin:end] {
+			delay := slot*time.Duration(wave-1) + rand.N(slot+1)
+			if hint := cfg.ReconnectMessage(delay); hint != nil {
+				_ = kws.enqueue(hintCtx, TextMessage, hint)
+			}
+		}
+		begin = end
+	}
+
+	closed := 0
+	for wave := 1; wave <= cfg.Waves; wave++ {
+		timer := time.NewTimer(time.Until(start.Add(cfg.Duration * time.Duration(wave) / time.Duration(cfg.Waves))))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			stopTimer(timer)
+			s.forceClose(ctx.Err())
+			return ctx.Err()
+		}
+
+		end := len(conns) * wave / cfg.Waves
+		for _, kws := range conns[closed:end] {
+			kws.closeWith(cfg.CloseCode, cfg.CloseReason)
+		}
+		closed = end
+		if cfg.Progress != nil {
+			cfg.Progress(DrainProgress{Wave: wave, Waves: cfg.Waves, Closed: closed, Total: len(conns)})
+		}
+	}
+	return nil
+}
+
+// StagedDrain drains the default server in waves. See Server.StagedDrain.
+func StagedDrain(ctx context.Context, config ...DrainConfig) error {
+	return defaultServer.StagedDrain(ctx, config...)
+}
@@ -0,0 +1,138 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// newDrainServer serves s on an in-memory listener and dials n clients.
+func newDrainServer(t *testing.T, s *Server, n int) (*websocket.Dialer, string, []*websocket.Conn) {
+	t.Helper()
+	app := fiber.New()
+	ln := fasthttputil.NewInmemoryListener()
+	t.Cleanup(func() {
+		_ = app.Shutdown()
+		_ = ln.Close()
+	})
+	app.Use(upgradeMiddleware)
+	app.Get("/", s.Handler(func(*Websocket) {}))
+	go func() { _ = app.Listener(ln) }()
+
+	dialer := &websocket.Dialer{
+		NetDial:          func(_, _ string) (net.Conn, error) { return ln.Dial() },
+		HandshakeTimeout: 5 * time.Second,
+	}
+	url := "ws://" + ln.Addr().String()
+	conns := make([]*websocket.Conn, n)
+	for i := range conns {
+		conn, _, err := dialWithRetry(dialer, url)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		conns[i] = conn
+	}
+	require.Eventually(t, func() bool { return len(s.pool.all()) == n }, 2*time.Second, 10*time.Millisecond)
+	return dialer, url, conns
+}
+
+func TestStagedDrainClosesInWaves(t *testing.T) {
+	s := NewServer()
+	dialer, url, conns := newDrainServer(t, s, 4)
+
+	var progress []DrainProgress
+	err := s.StagedDrain(context.Background(), DrainConfig{
+		Duration:          100 * time.Millisecond,
+		Waves:             2,
+		MaxReconnectDelay: time.Second,
+		Progress:          func(p DrainProgress) { progress = append(progress, p) },
+	})
+	require.NoError(t, err)
+	require.Equal(t, []DrainProgress{
+		{Wave: 1, Waves: 2, Closed: 2, Total: 4},
+		{Wave: 2, Waves: 2, Closed: 4, Total: 4},
+	}, progress)
+	require.Empty(t, s.pool.all())
+
+	var delays []int64
+	for _, conn := range conns {
+		_, msg, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var hint struct {
+			Event string `json:"event"`
+			Data  struct {
+				Delay int64 `json:"delay"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(msg, &hint))
+		require.Equal(t, "reconnect", hint.Event)
+		delays = append(delays, hint.Data.Delay)
+
+		_, _, err = conn.ReadMessage()
+		require.True(t, websocket.IsCloseError(err, websocket.CloseServiceRestart), err)
+	}
+
+	// Each wave gets its slot of MaxReconnectDelay.
+	slices.Sort(delays)
+	for i, delay := range delays {
+		wave := int64(i/2 + 1)
+		require.GreaterOrEqual(t, delay, (wave-1)*500)
+		require.LessOrEqual(t, delay, wave*500)
+	}
+
+	// Upgrades are refused while draining.
+	_, resp, err := dialer.Dial(url, nil)
+	require.Error(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestStagedDrainForceClosesOnContext(t *testing.T) {
+	s := NewServer()
+	_, _, conns := newDrainServer(t, s, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := s.StagedDrain(ctx, DrainConfig{
+		Duration:         time.Minute,
+		ReconnectMessage: func(time.Duration) []byte { return nil },
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Empty(t, s.pool.all())
+
+	for _, conn := range conns {
+		_, _, err := conn.ReadMessage()
+		require.Error(t, err)
+		require.False(t, websocket.IsCloseError(err, websocket.CloseServiceRestart))
+	}
+}
+
+func TestStagedDrainFullQueueDoesNotHoldUpWaves(t *testing.T) {
+	s := NewServer()
+	kws := createWS()
+	kws.server = s
+	s.pool.set(kws)
+	// Nobody drains the queue, so the hint can never be queued.
+	kws.queue <- message{data: []byte("backlog")}
+
+	start := time.Now()
+	err := s.StagedDrain(context.Background(), DrainConfig{
+		Duration: 400 * time.Millisecond,
+		Waves:    2,
+	})
+	require.NoError(t, err)
+	// The hint waits up to the first wave, which is part of Duration.
+	elapsed := time.Since(start)
+	require.GreaterOrEqual(t, elapsed, 400*time.Millisecond)
+	require.Less(t, elapsed, 550*time.Millisecond)
+	require.False(t, kws.IsAlive())
+	require.Equal(t, "backlog", string((<-kws.queue).data))
+}
@@ -7,7 +7,6 @@ import (
 	"errors"
 	"net"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/contrib/v3/websocket"
@@ -35,7 +34,8 @@ const (
 
 // Supported event list.
 const (
-	// EventMessage is fired when a text or binary message is received.
+	// EventMessage is fired when a text or binary message is received. With
+	// Config.Envelope, envelope frames are dispatched by name instead.
 	EventMessage = "message"
 	// EventPing is fired when a WebSocket ping control frame is received.
 	EventPing = "ping"
@@ -92,6 +92,8 @@ type message struct {
 	mType   int
 	data    []byte
 	retries int
+	// queued is when the message entered the send queue, for QueueStats.
+	queued time.Time
 }
 
 // EventPayload stores information about an event and its connection.
@@ -139,30 +141,76 @@ type Config struct {
 	// RecoverHandler is called on a panic inside a user On callback. If
 	// nil, panics are recovered silently.
 	RecoverHandler func(event string, r any)
+	// Envelope enables the JSON envelope mode: inbound text messages of the
+	// form {"id","event","data"} are dispatched to the named event or
+	// request handler instead of EventMessage, and Request is available.
+	// Envelope listeners and request handlers run in order on a goroutine
+	// of their own, so they may call Request; up to SendQueueSize inbound
+	// envelopes wait for them before reading stalls. Defaults to false.
+	Envelope bool
+	// RequestTimeout bounds how long Request waits for a reply when the
+	// supplied context has no earlier deadline. Zero falls back to 10s.
+	RequestTimeout time.Duration
+	// ResumeSecret enables resumable sessions. Every connection gets a
+	// resume token signed with this key (HMAC-SHA256), see ResumeToken.
+	// When a connection drops without Close or CloseAll, its UUID,
+	// attributes, hub topics and unsent messages are kept for
+	// ResumeGracePeriod, and a new connection presenting the token takes
+	// them over. Defaults to nil (disabled).
+	ResumeSecret []byte
+	// ResumeGracePeriod is how long a dropped session can be resumed. Zero
+	// falls back to 30s.
+	ResumeGracePeriod time.Duration
+	// ResumeBufferSize caps the messages kept for a dropped session, those
+	// still queued at the drop plus those sent to its UUID with EmitTo
+	// meanwhile. The oldest are dropped first. Zero falls back to the send
+	// queue size, which is also the upper bound.
+	ResumeBufferSize int
+	// ResumeQuery is the query parameter carrying the resume token of a
+	// reconnecting client. Defaults to "resume".
+	ResumeQuery string
+	// Codec encodes the values sent with EmitValue and decodes the data of
+	// Typed listeners. The envelope mode always uses JSON. Defaults to
+	// JSONCodec.
+	Codec Codec
 }
 
 // settings is the per-connection immutable snapshot.
 type settings struct {
-	pingInterval     time.Duration
-	readIdleTimeout  time.Duration
-	writeTimeout     time.Duration
-	maxMessageSize   int64
-	sendQueueSize    int
-	maxSendRetry     int
-	retrySendTimeout time.Duration
-	recover          func(event string, r any)
+	pingInterval      time.Duration
+	readIdleTimeout   time.Duration
+	writeTimeout      time.Duration
+	maxMessageSize    int64
+	sendQueueSize     int
+	maxSendRetry      int
+	retrySendTimeout  time.Duration
+	recover           func(event string, r any)
+	envelope          bool
+	requestTimeout    time.Duration
+	resumeSecret      []byte
+	resumeGracePeriod time.Duration
+	resumeBufferSize  int
+	resumeQuery       string
+	codec             Codec
 }
 
 func resolveSettings(cfg Config) settings {
 	s := settings{
-		pingInterval:     cfg.PingInterval,
-		readIdleTimeout:  cfg.ReadIdleTimeout,
-		writeTimeout:     cfg.WriteTimeout,
-		maxMessageSize:   cfg.MaxMessageSize,
-		sendQueueSize:    cfg.SendQueueSize,
-		maxSendRetry:     cfg.MaxSendRetry,
-		retrySendTimeout: cfg.RetrySendTimeout,
-		recover:          cfg.RecoverHandler,
+		pingInterval:      cfg.PingInterval,
+		readIdleTimeout:   cfg.ReadIdleTimeout,
+		writeTimeout:      cfg.WriteTimeout,
+		maxMessageSize:    cfg.MaxMessageSize,
+		sendQueueSize:     cfg.SendQueueSize,
+		maxSendRetry:      cfg.MaxSendRetry,
+		retrySendTimeout:  cfg.RetrySendTimeout,
+		recover:           cfg.RecoverHandler,
+		envelope:          cfg.Envelope,
+		requestTimeout:    cfg.RequestTimeout,
+		resumeSecret:      cfg.ResumeSecret,
+		resumeGracePeriod: cfg.ResumeGracePeriod,
+		resumeBufferSize:  cfg.ResumeBufferSize,
+		resumeQuery:       cfg.ResumeQuery,
+		codec:             cfg.Codec,
 	}
 	if s.pingInterval <= 0 {
 		s.pingInterval = PongTimeout
@@ -197,6 +245,21 @@ func resolveSettings(cfg Config) settings {
 			s.retrySendTimeout = 20 * time.Millisecond
 		}
 	}
+	if s.requestTimeout <= 0 {
+		s.requestTimeout = 10 * time.Second
+	}
+	if s.resumeGracePeriod <= 0 {
+		s.resumeGracePeriod = 30 * time.Second
+	}
+	if s.resumeBufferSize <= 0 || s.resumeBufferSize > s.sendQueueSize {
+		s.resumeBufferSize = s.sendQueueSize
+	}
+	if s.resumeQuery == "" {
+		s.resumeQuery = "resume"
+	}
+	if s.codec == nil {
+		s.codec = JSONCodec{}
+	}
 	return s
 }
 
@@ -231,12 +294,18 @@ type Websocket struct {
 	mu        sync.RWMutex
 	// Conn is the underlying Fiber websocket connection.
 	Conn *websocket.Conn
+	// server owns the pool and listeners the connection belongs to.
+	server *Server
 	// settings holds the per-connection immutable tuning snapshot.
 	settings settings
 	// isAlive defines if the connection is alive or not.
 	isAlive bool
 	// queue stores outbound messages.
 	queue chan message
+	// writable wakes the channels handed out by Writable.
+	writable writableSignal
+	// stats counts the messages written from queue.
+	stats queueStats
 	// done signals goroutines to stop gracefully.
 	done chan struct{}
 	// doneOnce closes done exactly once.
@@ -244,9 +313,27 @@ type Websocket struct {
 	// attributes stores optional connection-scoped values.
 	attributes map[string]interface{}
 	// localListeners holds listeners registered for this connection only via
-	// the On method. They fire in addition to the process-global listeners and
-	// are discarded when the connection disconnects.
+	// the On method. They fire in addition to the server listeners and are
+	// discarded when the connection disconnects.
 	localListeners safeListeners
+	// hubs holds the Hubs this connection subscribed through, so it can
+	// leave them when it disconnects.
+	hubs map[*Hub]struct{}
+	// requests correlates outstanding Request calls with their replies.
+	requests pendingRequests
+	// inbox holds the inbound envelope events and requests for the
+	// dispatch goroutine, nil unless Config.Envelope is set.
+	inbox chan envelope
+	// session is the resumable session of the connection, nil unless
+	// Config.ResumeSecret is set. It is assigned before the connection is
+	// published.
+	session *session
+	// resumed reports whether session was taken over on connect.
+	resumed bool
+	// unsent holds the message whose write failed, for the session.
+	unsent []message
+	// leftTopics holds the hub topics left on disconnect, for the session.
+	leftTopics map[*Hub][]string
 	// UUID is the unique connection identifier.
 	UUID string
 	// Locals wraps Fiber Locals.
@@ -264,10 +351,6 @@ type safePool struct {
 	conn map[string]ws
 }
 
-var pool = safePool{
-	conn: make(map[string]ws),
-}
-
 func (p *safePool) set(ws ws) {
 	p.Lock()
 	p.conn[ws.GetUUID()] = ws
@@ -330,50 +413,19 @@ func (l *safeListeners) remove(event string) {
 	l.Unlock()
 }
 
-var listeners = safeListeners{
-	list: make(map[string][]EventCallback),
-}
-
 // New returns a Fiber handler that upgrades the request to WebSocket and wraps
-// it with the event helper using default tuning. For per-instance tuning use
-// NewWithConfig.
+// it with the event helper using default tuning. Connections join the default
+// server, which the package-level functions operate on. For an independent
+// endpoint use NewServer and Server.Handler.
 func New(callback func(kws *Websocket), config ...websocket.Config) fiber.Handler {
 	return NewWithConfig(callback, Config{}, config...)
 }
 
 // NewWithConfig returns a Fiber handler that upgrades the request to WebSocket
 // and wraps it with the event helper, using the supplied per-instance tuning.
+// Connections join the default server.
 func NewWithConfig(callback func(kws *Websocket), eventCfg Config, wsConfig ...websocket.Config) fiber.Handler {
-	s := resolveSettings(eventCfg)
-	return websocket.New(func(c *websocket.Conn) {
-		kws := &Websocket{
-			Conn:     c,
-			settings: s,
-			Locals: func(key string) interface{} {
-				return c.Locals(key)
-			},
-			Params: func(key string, defaultValue ...string) string {
-				return c.Params(key, defaultValue...)
-			},
-			Query: func(key string, defaultValue ...string) string {
-				return c.Query(key, defaultValue...)
-			},
-			Cookies: func(key string, defaultValue ...string) string {
-				return c.Cookies(key, defaultValue...)
-			},
-			queue:      make(chan message, s.sendQueueSize),
-			done:       make(chan struct{}),
-			attributes: make(map[string]interface{}),
-			isAlive:    true,
-		}
-
-		kws.UUID = kws.createUUID()
-		pool.set(kws)
-
-		callback(kws)
-		kws.fireEvent(EventConnect, nil, nil)
-		kws.run()
-	}, wsConfig...)
+	return defaultServer.handler(callback, resolveSettings(eventCfg), wsConfig...)
 }
 
 // GetUUID returns the connection UUID.
@@ -385,6 +437,7 @@ func (kws *Websocket) GetUUID() string {
 
 // SetUUID updates the connection UUID and its pool entry.
 func (kws *Websocket) SetUUID(uuid string) error {
+	pool := &kws.server.pool
 	pool.Lock()
 	defer pool.Unlock()
 	kws.mu.Lock()
@@ -401,6 +454,14 @@ func (kws *Websocket) SetUUID(uuid string) error {
 	if existing, ok := pool.conn[uuid]; ok && existing != kws {
 		return ErrorUUIDDuplication
 	}
+	// A dropped session keeps its UUID reserved until it expires.
+	if kws.session != nil {
+		if err := kws.server.sessions.rename(kws, uuid); err != nil {
+			return err
+		}
+	} else if kws.server.sessions.reserved(kws, uuid) {
+		return ErrorUUIDDuplication
+	}
 
 	kws.UUID = uuid
 	if prevUUID != "" {
@@ -469,17 +530,18 @@ func (kws *Websocket) EmitToList(uuids []string, message []byte, mType ...int) {
 // silently ignored and, unlike the (*Websocket).EmitToList method form, no
 // EventError is fired.
 func EmitToList(uuids []string, message []byte, mType ...int) {
-	for _, wsUUID := range uuids {
-		_ = EmitTo(wsUUID, message, mType...)
-	}
+	defaultServer.EmitToList(uuids, message, mType...)
 }
 
 // EmitTo emits a message to a connection UUID. On an invalid or dead target it
 // fires EventError on the originating connection (kws) and returns the error;
 // the package-level EmitTo does not fire EventError.
 func (kws *Websocket) EmitTo(uuid string, message []byte, mType ...int) error {
-	conn, err := pool.get(uuid)
+	conn, err := kws.server.pool.get(uuid)
 	if err != nil {
+		if kws.server.bufferForSession(uuid, message, mType...) {
+			return nil
+		}
 		kws.fireEvent(EventError, []byte(uuid), ErrorInvalidConnection)
 		return ErrorInvalidConnection
 	}
@@ -496,16 +558,7 @@ func (kws *Websocket) EmitTo(uuid string, message []byte, mType ...int) error {
 // caller and, unlike the (*Websocket).EmitTo method form, does not fire
 // EventError.
 func EmitTo(uuid string, message []byte, mType ...int) error {
-	conn, err := pool.get(uuid)
-	if err != nil {
-		return ErrorInvalidConnection
-	}
-	if !conn.IsAlive() {
-		return ErrorInvalidConnection
-	}
-
-	conn.Emit(message, mType...)
-	return nil
+	return defaultServer.EmitTo(uuid, message, mType...)
 }
 
 // Broadcast emits to all active connections, skipping the originating
@@ -513,7 +566,7 @@ func EmitTo(uuid string, message []byte, mType ...int) error {
 // kws; the package-level Broadcast does not.
 func (kws *Websocket) Broadcast(message []byte, except bool, mType ...int) {
 	selfUUID := kws.GetUUID()
-	for wsUUID := range pool.all() {
+	for wsUUID := range kws.server.pool.all() {
 		if except && selfUUID == wsUUID {
 			continue
 		}
@@ -524,22 +577,21 @@ func (kws *Websocket) Broadcast(message []byte, except bool, mType ...int) {
 
 // Broadcast emits to all active connections.
 func Broadcast(message []byte, mType ...int) {
-	for _, kws := range pool.all() {
-		kws.Emit(message, mType...)
-	}
+	defaultServer.Broadcast(message, mType...)
 }
 
 // On registers a listener for the event on this connection only. Unlike the
-// package-level On, which is process-global, these listeners fire only for
-// events on this connection and are discarded when it disconnects. Both
-// per-connection and global listeners fire for a given event.
+// package-level On and Server.On, which cover every connection of a server,
+// these listeners fire only for events on this connection and are discarded
+// when it disconnects. Both per-connection and server listeners fire for a
+// given event.
 func (kws *Websocket) On(event string, callback EventCallback) {
 	kws.localListeners.set(event, callback)
 }
 
 // Off removes all listeners registered for the event on this connection via the
-// On method. It does not affect process-global listeners registered with the
-// package-level On.
+// On method. It does not affect server listeners registered with the
+// package-level On or Server.On.
 func (kws *Websocket) Off(event string) {
 	kws.localListeners.remove(event)
 }
@@ -551,7 +603,7 @@ func (kws *Websocket) Fire(event string, data []byte) {
 
 // Fire fires a custom event on all active connections.
 func Fire(event string, data []byte) {
-	fireGlobalEvent(event, data, nil)
+	defaultServer.Fire(event, data)
 }
 
 // Emit writes a message to the current connection.
@@ -563,6 +615,41 @@ func (kws *Websocket) Emit(message []byte, mType ...int) {
 	kws.write(t, message)
 }
 
+// EnableCompression enables and disables compression of subsequent messages
+// to this connection. It has no effect unless per message compression was
+// negotiated, see websocket.Config.EnableCompression.
+func (kws *Websocket) EnableCompression(enable bool) {
+	if conn := kws.conn(); conn != nil {
+		conn.EnableWriteCompression(enable)
+	}
+}
+
+// SetCompressionLevel sets the flate level of subsequent compressed messages
+// to this connection. It returns websocket.ErrInvalidCompressionLevel for a
+// level outside flate.HuffmanOnly to flate.BestCompression, and
+// ErrorInvalidConnection once the connection is closed.
+func (kws *Websocket) SetCompressionLevel(level int) error {
+	conn := kws.conn()
+	if conn == nil {
+		return ErrorInvalidConnection
+	}
+	return conn.SetCompressionLevel(level)
+}
+
+// SetCompressionThreshold sets the size in bytes below which messages to
+// this connection are sent uncompressed. Zero compresses every message.
+func (kws *Websocket) SetCompressionThreshold(n int) {
+	if conn := kws.conn(); conn != nil {
+		conn.SetCompressionThreshold(n)
+	}
+}
+
+func (kws *Websocket) conn() *websocket.Conn {
+	kws.mu.RLock()
+	defer kws.mu.RUnlock()
+	return kws.Conn
+}
+
 // closeFrameMaxReason caps the reason payload in a close frame so the
 // combined control frame stays within RFC 6455 §5.5's 125-byte limit
 // (2 bytes status code + up to 123 bytes reason).
@@ -574,8 +661,15 @@ func (kws *Websocket) Close() {
 		return
 	}
 
+	kws.closeWith(websocket.CloseNormalClosure, "Connection closed")
+}
+
+// closeWith closes the connection from the server with a close frame
+// carrying code and reason.
+func (kws *Websocket) closeWith(code int, reason string) {
 	kws.closeOnce.Do(func() {
-		kws.writeClose(websocket.CloseNormalClosure, "Connection closed")
+		kws.endSession()
+		kws.writeClose(code, reason)
 		kws.fireEvent(EventClose, nil, nil)
 		kws.disconnected(nil)
 	})
@@ -648,22 +742,14 @@ func (kws *Websocket) pong(ctx context.Context) {
 }
 
 func (kws *Websocket) write(messageType int, messageBytes []byte) {
-	msg := message{
-		mType:   messageType,
-		data:    messageBytes,
-		retries: 0,
-	}
-
-	select {
-	case kws.queue <- msg:
-	case <-kws.done:
-	}
+	_ = kws.enqueue(context.Background(), messageType, messageBytes)
 }
 
 func (kws *Websocket) send(ctx context.Context) {
 	for {
 		select {
 		case msg := <-kws.queue:
+			kws.writable.notify()
 			if !kws.hasConn() {
 				if msg.retries <= kws.settings.maxSendRetry {
 					retryTimer := time.NewTimer(kws.settings.retrySendTimeout)
@@ -704,10 +790,18 @@ func (kws *Websocket) send(ctx context.Context) {
 			_ = conn.SetWriteDeadline(time.Now().Add(kws.settings.writeTimeout))
 			err := conn.WriteMessage(msg.mType, msg.data)
 			if err != nil {
-				kws.drainQueue()
+				if kws.session != nil {
+					// Keep the queue for the session, see suspend.
+					kws.mu.Lock()
+					kws.unsent = []message{msg}
+					kws.mu.Unlock()
+				} else {
+					kws.drainQueue()
+				}
 				kws.disconnected(err)
 				return
 			}
+			kws.stats.record(time.Since(msg.queued))
 		case <-ctx.Done():
 			return
 		}
@@ -777,6 +871,13 @@ func (kws *Websocket) run() {
 		defer wg.Done()
 		kws.send(ctx)
 	}()
+	if kws.inbox != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			kws.dispatch(ctx)
+		}()
+	}
 
 	<-kws.done
 	cancelFunc()
@@ -786,6 +887,9 @@ func (kws *Websocket) run() {
 	kws.unblockRead()
 	wg.Wait()
 	kws.closeConn()
+	if kws.session != nil {
+		kws.suspend()
+	}
 }
 
 func (kws *Websocket) read(ctx context.Context) {
@@ -820,6 +924,9 @@ func (kws *Websocket) read(ctx context.Context) {
 
 		switch mType {
 		case TextMessage, BinaryMessage:
+			if mType == TextMessage && kws.settings.envelope && kws.dispatchEnvelope(msg) {
+				continue
+			}
 			kws.fireEvent(EventMessage, msg, nil)
 		default:
 			// Defensive: ReadMessage should not deliver control frames.
@@ -835,7 +942,14 @@ func (kws *Websocket) disconnected(err error) {
 		kws.doneOnce.Do(func() {
 			close(kws.done)
 		})
-		pool.delete(kws.GetUUID())
+		kws.server.pool.delete(kws.GetUUID())
+		topics := kws.leaveHubs()
+		if kws.session != nil {
+			kws.mu.Lock()
+			kws.leftTopics = topics
+			kws.mu.Unlock()
+		}
+		kws.writable.notify()
 	})
 
 	if !disconnected {
@@ -893,26 +1007,15 @@ func stopTimer(timer *time.Timer) {
 	}
 }
 
-func fireGlobalEvent(event string, data []byte, err error) {
-	for _, kws := range pool.all() {
-		kws.fireEvent(event, data, err)
-	}
-}
-
 func (kws *Websocket) fireEvent(event string, data []byte, err error) {
 	// listeners.get returns a fresh slice, so appending the per-connection
-	// listeners to it does not mutate the global registry.
-	callbacks := append(listeners.get(event), kws.localListeners.get(event)...)
+	// listeners to it does not mutate the server registry.
+	callbacks := append(kws.server.listeners.get(event), kws.localListeners.get(event)...)
 	if len(callbacks) == 0 {
 		return
 	}
 
-	kws.mu.RLock()
-	attrs := make(map[string]any, len(kws.attributes))
-	for key, value := range kws.attributes {
-		attrs[key] = value
-	}
-	kws.mu.RUnlock()
+	attrs := kws.attributesSnapshot()
 
 	// Clone payload bytes once before fan-out so listeners that retain the
 	// slice are not exposed to the read buffer being reused by the next
@@ -936,6 +1039,17 @@ func (kws *Websocket) fireEvent(event string, data []byte, err error) {
 	}
 }
 
+// attributesSnapshot returns a copy of the connection attributes.
+func (kws *Websocket) attributesSnapshot() map[string]any {
+	kws.mu.RLock()
+	defer kws.mu.RUnlock()
+	attrs := make(map[string]any, len(kws.attributes))
+	for key, value := range kws.attributes {
+		attrs[key] = value
+	}
+	return attrs
+}
+
 // invokeCallback runs a single listener callback with panic recovery so a
 // faulty user listener cannot tear down the read or send goroutine.
 func (kws *Websocket) invokeCallback(event string, cb EventCallback, p *EventPayload) {
@@ -952,41 +1066,34 @@ func (kws *Websocket) invokeCallback(event string, cb EventCallback, p *EventPay
 // EventCallback is the listener signature invoked when an event fires.
 type EventCallback func(payload *EventPayload)
 
-// On registers a process-global listener for an event. The callback fires for
-// that event on every connection created by New / NewWithConfig, regardless of
-// route or Config, and stays registered until removed with Off. For listeners
-// scoped to a single connection use the (*Websocket).On method instead.
+// On registers a listener for an event on the default server. The callback
+// fires for that event on every connection created by New / NewWithConfig,
+// regardless of route or Config, and stays registered until removed with Off.
+// For listeners scoped to a single connection use the (*Websocket).On method
+// instead.
 func On(event string, callback EventCallback) {
-	listeners.set(event, callback)
+	defaultServer.On(event, callback)
 }
 
-// Off removes all process-global listeners registered for the event via On. It
-// is the counterpart to On and does not affect per-connection listeners
+// Off removes all default server listeners registered for the event via On.
+// It is the counterpart to On and does not affect per-connection listeners
 // registered through (*Websocket).On.
 func Off(event string) {
-	listeners.remove(event)
+	defaultServer.Off(event)
 }
 
-var draining atomic.Bool
-
-// IsDraining reports whether the package is in draining mode. Upgrade
-// handlers can poll this to refuse new connections during a graceful
-// shutdown.
+// IsDraining reports whether the default server is in draining mode.
 func IsDraining() bool {
-	return draining.Load()
+	return defaultServer.IsDraining()
 }
 
-// Drain marks the package as draining. New connections are not refused
-// automatically; the upgrade gate is the caller's responsibility (a
-// middleware that checks IsDraining and returns 503).
+// Drain marks the default server as draining. See Server.Drain.
 func Drain() {
-	draining.Store(true)
+	defaultServer.Drain()
 }
 
-// CloseAll iterates every active connection in the in-process pool and
-// sends a close control frame with the supplied code and reason, then
-// waits for each helper's run() loop to exit. If ctx expires first,
-// remaining connections are force closed via Conn.Close.
+// CloseAll closes every connection of the default server. See
+// Server.CloseAll.
 //
 // The typical usage is from a Fiber shutdown hook:
 //
@@ -996,51 +1103,6 @@ func Drain() {
 //	    event.Drain()
 //	    return event.CloseAll(ctx, websocket.CloseGoingAway, "server shutting down")
 //	})
-//
-// Reason is capped at 123 bytes per RFC 6455.
 func CloseAll(ctx context.Context, code int, reason string) error {
-	snapshot := pool.all()
-	if len(snapshot) == 0 {
-		return nil
-	}
-
-	var wg sync.WaitGroup
-	for _, c := range snapshot {
-		kws, ok := c.(*Websocket)
-		if !ok {
-			continue
-		}
-		wg.Add(1)
-		go func(kws *Websocket) {
-			defer wg.Done()
-			kws.closeOnce.Do(func() {
-				kws.writeClose(code, reason)
-				kws.fireEvent(EventClose, nil, nil)
-				kws.disconnected(nil)
-			})
-		}(kws)
-	}
-
-	done := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(done)
-	}()
-
-	select {
-	case <-done:
-		return nil
-	case <-ctx.Done():
-		for _, c := range pool.all() {
-			kws, ok := c.(*Websocket)
-			if !ok {
-				continue
-			}
-			// Mark disconnected (clears isAlive, removes from pool) before the
-			// force close so later emits cannot target a closed connection.
-			kws.disconnected(ctx.Err())
-			kws.closeConn()
-		}
-		return ctx.Err()
-	}
+	return defaultServer.CloseAll(ctx, code, reason)
 }
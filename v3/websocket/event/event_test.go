@@ -59,20 +59,23 @@ type WebsocketMock struct {
 }
 
 func resetState() {
-	pool.Lock()
-	pool.conn = make(map[string]ws)
-	pool.Unlock()
-	listeners.Lock()
-	listeners.list = make(map[string][]EventCallback)
-	listeners.Unlock()
-	draining.Store(false)
+	defaultServer.pool.Lock()
+	defaultServer.pool.conn = make(map[string]ws)
+	defaultServer.pool.Unlock()
+	defaultServer.listeners.Lock()
+	defaultServer.listeners.list = make(map[string][]EventCallback)
+	defaultServer.listeners.Unlock()
+	defaultServer.requestHandlers.Lock()
+	defaultServer.requestHandlers.list = nil
+	defaultServer.requestHandlers.Unlock()
+	defaultServer.draining.Store(false)
 }
 
 func (s *WebsocketMock) SetUUID(uuid string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, err := pool.get(uuid); err == nil {
+	if _, err := defaultServer.pool.get(uuid); err == nil {
 		return ErrorUUIDDuplication
 	}
 	s.UUID = uuid
@@ -175,7 +178,7 @@ func TestGlobalFire(t *testing.T) {
 
 	for i := 0; i < numTestConn; i++ {
 		kws := createWS()
-		pool.set(kws)
+		defaultServer.pool.set(kws)
 	}
 
 	h := new(HandlerMock)
@@ -195,7 +198,7 @@ func TestGlobalBroadcast(t *testing.T) {
 	for i := 0; i < numParallelTestConn; i++ {
 		mws := new(WebsocketMock)
 		require.NoError(t, mws.SetUUID(mws.createUUID()))
-		pool.set(mws)
+		defaultServer.pool.set(mws)
 
 		mws.On("Emit", mock.Anything).Return(nil)
 		mws.wg.Add(1)
@@ -203,7 +206,7 @@ func TestGlobalBroadcast(t *testing.T) {
 
 	Broadcast([]byte("test"), TextMessage)
 
-	for _, mws := range pool.all() {
+	for _, mws := range defaultServer.pool.all() {
 		mws.(*WebsocketMock).wg.Wait()
 		mws.(*WebsocketMock).AssertNumberOfCalls(t, "Emit", 1)
 	}
@@ -217,11 +220,11 @@ func TestGlobalEmitTo(t *testing.T) {
 
 	alive := new(WebsocketMock)
 	alive.UUID = aliveUUID
-	pool.set(alive)
+	defaultServer.pool.set(alive)
 
 	closed := new(WebsocketMock)
 	closed.UUID = closedUUID
-	pool.set(closed)
+	defaultServer.pool.set(closed)
 
 	alive.On("Emit", mock.Anything).Return(nil)
 	alive.On("IsAlive").Return(true)
@@ -255,12 +258,12 @@ func TestGlobalEmitToList(t *testing.T) {
 		kws.On("Emit", mock.Anything).Return(nil)
 		kws.On("IsAlive").Return(true)
 		kws.wg.Add(1)
-		pool.set(kws)
+		defaultServer.pool.set(kws)
 	}
 
 	EmitToList(uuids, []byte("test"), TextMessage)
 
-	for _, kws := range pool.all() {
+	for _, kws := range defaultServer.pool.all() {
 		kws.(*WebsocketMock).wg.Wait()
 		kws.(*WebsocketMock).AssertNumberOfCalls(t, "Emit", 1)
 	}
@@ -296,7 +299,7 @@ func TestWebsocketSetUUIDUpdatesPool(t *testing.T) {
 	resetState()
 
 	kws := createWS()
-	pool.set(kws)
+	defaultServer.pool.set(kws)
 
 	oldUUID := kws.GetUUID()
 	newUUID := "new-uuid"
@@ -305,22 +308,22 @@ func TestWebsocketSetUUIDUpdatesPool(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, newUUID, kws.GetUUID())
 
-	_, err = pool.get(oldUUID)
+	_, err = defaultServer.pool.get(oldUUID)
 	require.ErrorIs(t, err, ErrorInvalidConnection)
 
-	poolEntry, err := pool.get(newUUID)
+	poolEntry, err := defaultServer.pool.get(newUUID)
 	require.NoError(t, err)
 	require.Equal(t, kws, poolEntry)
 
 	other := createWS()
 	other.UUID = "other-uuid"
-	pool.set(other)
+	defaultServer.pool.set(other)
 
 	err = kws.SetUUID(other.UUID)
 	require.ErrorIs(t, err, ErrorUUIDDuplication)
 	require.Equal(t, newUUID, kws.GetUUID())
 
-	poolEntry, err = pool.get(newUUID)
+	poolEntry, err = defaultServer.pool.get(newUUID)
 	require.NoError(t, err)
 	require.Equal(t, kws, poolEntry)
 }
@@ -329,7 +332,7 @@ func TestWebsocketCloseRemovesConnectionFromPool(t *testing.T) {
 	resetState()
 
 	kws := createWS()
-	pool.set(kws)
+	defaultServer.pool.set(kws)
 	closeEvents := 0
 	disconnectEvents := 0
 	On(EventClose, func(*EventPayload) {
@@ -352,7 +355,7 @@ func TestWebsocketCloseRemovesConnectionFromPool(t *testing.T) {
 	wg.Wait()
 
 	require.False(t, kws.IsAlive())
-	_, err := pool.get(kws.GetUUID())
+	_, err := defaultServer.pool.get(kws.GetUUID())
 	require.ErrorIs(t, err, ErrorInvalidConnection)
 	require.Equal(t, 1, closeEvents)
 	require.Equal(t, 1, disconnectEvents)
@@ -362,7 +365,7 @@ func TestWebsocketCloseDoesNotBlockOnFullQueue(t *testing.T) {
 	resetState()
 
 	kws := createWS()
-	pool.set(kws)
+	defaultServer.pool.set(kws)
 
 	// Fill the send queue to capacity so any further write would block on the
 	// queue channel if it were not guarded by the done channel.
@@ -397,7 +400,7 @@ func TestDrainFlag(t *testing.T) {
 	require.False(t, IsDraining())
 	Drain()
 	require.True(t, IsDraining())
-	draining.Store(false)
+	defaultServer.draining.Store(false)
 	require.False(t, IsDraining())
 }
 
@@ -437,7 +440,7 @@ func TestCloseAllSendsGoingAway(t *testing.T) {
 
 	// Give upgrades a moment to register in the pool.
 	require.Eventually(t, func() bool {
-		return len(pool.all()) == numConn
+		return len(defaultServer.pool.all()) == numConn
 	}, 2*time.Second, 10*time.Millisecond, "expected %d pool entries", numConn)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -453,7 +456,7 @@ func TestCloseAllSendsGoingAway(t *testing.T) {
 		require.Equal(t, "bye", ce.Text)
 	}
 
-	require.Empty(t, pool.all())
+	require.Empty(t, defaultServer.pool.all())
 }
 
 func TestCloseSendsFormatCloseMessage(t *testing.T) {
@@ -759,7 +762,7 @@ func TestListenerPanicIsRecovered(t *testing.T) {
 		},
 	})
 	atomic.StoreInt32(panicCounter, 0)
-	pool.set(kws)
+	defaultServer.pool.set(kws)
 
 	On(EventMessage, func(*EventPayload) {
 		panic("listener boom")
@@ -779,7 +782,7 @@ func TestEventPayloadDataIsIndependentOfReadBuffer(t *testing.T) {
 	resetState()
 
 	kws := createWS()
-	pool.set(kws)
+	defaultServer.pool.set(kws)
 
 	var captured []byte
 	On(EventMessage, func(p *EventPayload) {
@@ -901,7 +904,7 @@ func TestWebsocketDisconnectedFiresOnce(t *testing.T) {
 	resetState()
 
 	kws := createWS()
-	pool.set(kws)
+	defaultServer.pool.set(kws)
 	disconnectEvents := 0
 	errorEvents := 0
 	On(EventDisconnect, func(payload *EventPayload) {
@@ -919,7 +922,7 @@ func TestWebsocketDisconnectedFiresOnce(t *testing.T) {
 	kws.disconnected(nil)
 
 	require.False(t, kws.IsAlive())
-	_, err := pool.get(kws.GetUUID())
+	_, err := defaultServer.pool.get(kws.GetUUID())
 	require.ErrorIs(t, err, ErrorInvalidConnection)
 	require.Equal(t, 1, disconnectEvents)
 	require.Equal(t, 1, errorEvents)
@@ -927,7 +930,8 @@ func TestWebsocketDisconnectedFiresOnce(t *testing.T) {
 
 func createWS() *Websocket {
 	kws := &Websocket{
-		Conn: nil,
+		Conn:   nil,
+		server: defaultServer,
 		Locals: func(key string) interface{} {
 			return ""
 		},
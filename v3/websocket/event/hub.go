@@ -0,0 +1,372 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/v3/websocket"
+)
+
+var (
+	// ErrorHubClosed is returned by Hub methods once the hub has been closed.
+	ErrorHubClosed = errors.New("hub is closed")
+	// ErrorSlowSubscriber is passed to EventError of a subscriber whose send
+	// queue had no room for a published message. The message is dropped
+	// for that subscriber.
+	ErrorSlowSubscriber = errors.New("hub message dropped: send queue is full")
+)
+
+// Backplane fans Hub publishes out to the hubs of other processes. The
+// Hub always delivers to its own subscribers first and only hands the
+// message to the Backplane afterwards, so a Backplane must deliver to
+// every other node but never back to the node that published.
+//
+// Implementations must be safe for concurrent use. The event/redisbackplane
+// package provides a Redis pub/sub implementation; MemoryBroker connects
+// hubs within one process.
+type Backplane interface {
+	// Publish sends a message published on this node to the other nodes.
+	Publish(topic string, message []byte, mType int) error
+	// Subscribe registers handler for messages published by other nodes.
+	// NewHub calls it exactly once, before the hub is returned.
+	Subscribe(handler func(topic string, message []byte, mType int)) error
+	// Close ends the subscription. Hub.Close calls it.
+	Close() error
+}
+
+// HubConfig configures a Hub. Pass via NewHub.
+type HubConfig struct {
+	// Backplane fans publishes out to other processes. Nil keeps the
+	// hub local to this process.
+	Backplane Backplane
+
+	// DeliveryTimeout is how long one delivery may wait for room in the
+	// send queues of its subscribers, in total. A subscriber whose queue
+	// is still full is skipped with ErrorSlowSubscriber, so a stalled
+	// connection cannot hold up Publish or the Backplane for the others.
+	// Optional. Default: 0 (never wait)
+	DeliveryTimeout time.Duration
+
+	// DisconnectSlow closes a subscriber that missed a message with
+	// CloseTryAgainLater, so the client can reconnect and resync instead
+	// of silently missing messages.
+	// Optional. Default: false
+	DisconnectSlow bool
+}
+
+// Hub is a topic based publish/subscribe registry on top of the event
+// helper. Connections join topics with Subscribe and leave them with
+// Unsubscribe or by disconnecting; Publish emits to every connection
+// subscribed to a topic, on this node and, through the Backplane, on
+// every other node.
+//
+// A Hub is independent of the connection pool, so several hubs can be
+// used side by side.
+type Hub struct {
+	backplane       Backplane
+	deliveryTimeout time.Duration
+	disconnectSlow  bool
+
+	mu     sync.RWMutex
+	closed bool
+	// topics maps a topic to its local subscribers. Connections are keyed
+	// by pointer so SetUUID does not invalidate a subscription.
+	topics map[string]map[*Websocket]struct{}
+	// joined maps a connection to the topics it is subscribed to.
+	joined map[*Websocket]map[string]struct{}
+}
+
+// NewHub creates a Hub. When a Backplane is configured its subscription is
+// opened before NewHub returns, and its error is returned if that fails.
+func NewHub(config ...HubConfig) (*Hub, error) {
+	var cfg HubConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	h := &Hub{
+		backplane:       cfg.Backplane,
+		deliveryTimeout: cfg.DeliveryTimeout,
+		disconnectSlow:  cfg.DisconnectSlow,
+		topics:          make(map[string]map[*Websocket]struct{}),
+		joined:          make(map[*Websocket]map[string]struct{}),
+	}
+	if h.backplane != nil {
+		if err := h.backplane.Subscribe(h.deliver); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Subscribe adds kws to topic. It returns ErrorInvalidConnection when kws
+// is no longer alive. Subscribing twice to the same topic is a no-op.
+// Subscriptions end automatically when the connection disconnects.
+func (h *Hub) Subscribe(kws *Websocket, topic string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrorHubClosed
+	}
+
+	// Registering the hub on kws under kws.mu, while holding h.mu, pairs
+	// with disconnected: either the liveness check below fails, or
+	// leaveHubs sees this hub and removes the subscription again.
+	kws.mu.Lock()
+	if !kws.isAlive {
+		kws.mu.Unlock()
+		return ErrorInvalidConnection
+	}
+	if kws.hubs == nil {
+		kws.hubs = make(map[*Hub]struct{})
+	}
+	kws.hubs[h] = struct{}{}
+	kws.mu.Unlock()
+
+	subs, ok := h.topics[topic]
+	if !ok {
+		subs = make(map[*Websocket]struct{})
+		h.topics[topic] = subs
+	}
+	subs[kws] = struct{}{}
+
+	joined, ok := h.joined[kws]
+	if !ok {
+		joined = make(map[string]struct{})
+		h.joined[kws] = joined
+	}
+	joined[topic] = struct{}{}
+	return nil
+}
+
+// Unsubscribe removes kws from topic. It is a no-op when kws is not
+// subscribed to topic.
+func (h *Hub) Unsubscribe(kws *Websocket, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unsubscribeLocked(kws, topic)
+}
+
+// UnsubscribeAll removes kws from every topic of the hub.
+func (h *Hub) UnsubscribeAll(kws *Websocket) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for topic := range h.joined[kws] {
+		h.unsubscribeLocked(kws, topic)
+	}
+}
+
+// leave is UnsubscribeAll returning the topics kws left.
+func (h *Hub) leave(kws *Websocket) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	topics := make([]string, 0, len(h.joined[kws]))
+	for topic := range h.joined[kws] {
+		topics = append(topics, topic)
+		h.unsubscribeLocked(kws, topic)
+	}
+	return topics
+}
+
+func (h *Hub) unsubscribeLocked(kws *Websocket, topic string) {
+	if subs, ok := h.topics[topic]; ok {
+		delete(subs, kws)
+		if len(subs) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	if joined, ok := h.joined[kws]; ok {
+		delete(joined, topic)
+		if len(joined) == 0 {
+			delete(h.joined, kws)
+		}
+	}
+}
+
+// Topics returns the topics kws is subscribed to, in no particular order.
+func (h *Hub) Topics(kws *Websocket) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	joined := h.joined[kws]
+	if len(joined) == 0 {
+		return nil
+	}
+	ret := make([]string, 0, len(joined))
+	for topic := range joined {
+		ret = append(ret, topic)
+	}
+	return ret
+}
+
+// Subscribers returns the number of local connections subscribed to
+// topic. Subscribers on other nodes are not counted.
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics[topic])
+}
+
+// Publish emits message to every connection subscribed to topic, first on
+// this node and then, when a Backplane is configured, on every other node.
+// The message type defaults to TextMessage. The returned error is the
+// Backplane's; local delivery has already happened when it is reported.
+// Publish does not wait for slow subscribers, see HubConfig.DeliveryTimeout.
+func (h *Hub) Publish(topic string, message []byte, mType ...int) error {
+	t := TextMessage
+	if len(mType) > 0 {
+		t = mType[0]
+	}
+	h.mu.RLock()
+	closed := h.closed
+	h.mu.RUnlock()
+	if closed {
+		return ErrorHubClosed
+	}
+
+	h.deliver(topic, message, t)
+	if h.backplane == nil {
+		return nil
+	}
+	return h.backplane.Publish(topic, message, t)
+}
+
+// deliver queues message to the local subscribers of topic, waiting at
+// most DeliveryTimeout in total for room in their send queues.
+func (h *Hub) deliver(topic string, message []byte, mType int) {
+	h.mu.RLock()
+	subs := make([]*Websocket, 0, len(h.topics[topic]))
+	for kws := range h.topics[topic] {
+		subs = append(subs, kws)
+	}
+	h.mu.RUnlock()
+
+	// enqueue prefers a free slot over a done ctx, so a zero timeout
+	// still queues to every subscriber with room.
+	ctx, cancel := context.WithTimeout(context.Background(), h.deliveryTimeout)
+	defer cancel()
+	for _, kws := range subs {
+		if !kws.IsAlive() {
+			continue
+		}
+		if err := kws.enqueue(ctx, mType, message); err == nil || errors.Is(err, ErrorInvalidConnection) {
+			continue
+		}
+		kws.fireEvent(EventError, message, ErrorSlowSubscriber)
+		if h.disconnectSlow {
+			// Closing writes a close frame, which may itself wait on the
+			// slow peer.
+			go kws.closeWith(websocket.CloseTryAgainLater, "Too slow to receive messages")
+		}
+	}
+}
+
+// Close drops every subscription and closes the Backplane. Connections
+// stay open.
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	joined := h.joined
+	h.topics = make(map[string]map[*Websocket]struct{})
+	h.joined = make(map[*Websocket]map[string]struct{})
+	h.mu.Unlock()
+
+	for kws := range joined {
+		kws.mu.Lock()
+		delete(kws.hubs, h)
+		kws.mu.Unlock()
+	}
+	if h.backplane == nil {
+		return nil
+	}
+	return h.backplane.Close()
+}
+
+// leaveHubs removes kws from every hub it subscribed through and returns
+// the topics it left per hub. It runs once the connection is no longer
+// alive, so no new subscription can race it.
+func (kws *Websocket) leaveHubs() map[*Hub][]string {
+	kws.mu.Lock()
+	hubs := kws.hubs
+	kws.hubs = nil
+	kws.mu.Unlock()
+	var left map[*Hub][]string
+	for h := range hubs {
+		if topics := h.leave(kws); len(topics) > 0 {
+			if left == nil {
+				left = make(map[*Hub][]string, len(hubs))
+			}
+			left[h] = topics
+		}
+	}
+	return left
+}
+
+// MemoryBroker connects the hubs of one process as if they ran on
+// separate nodes. It is mainly useful in tests and for hubs that serve
+// different endpoints but share topics.
+type MemoryBroker struct {
+	mu    sync.RWMutex
+	nodes map[*MemoryBackplane]func(topic string, message []byte, mType int)
+}
+
+// NewMemoryBroker returns a broker with no nodes.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		nodes: make(map[*MemoryBackplane]func(topic string, message []byte, mType int)),
+	}
+}
+
+// Backplane returns a new node of the broker, to be passed to a single Hub.
+func (b *MemoryBroker) Backplane() *MemoryBackplane {
+	return &MemoryBackplane{broker: b}
+}
+
+// MemoryBackplane is an in-process Backplane node created by
+// MemoryBroker.Backplane. Publish delivers synchronously to every other
+// subscribed node of the broker.
+type MemoryBackplane struct {
+	broker *MemoryBroker
+}
+
+var _ Backplane = (*MemoryBackplane)(nil)
+
+// Publish implements Backplane.
+func (m *MemoryBackplane) Publish(topic string, message []byte, mType int) error {
+	m.broker.mu.RLock()
+	handlers := make([]func(string, []byte, int), 0, len(m.broker.nodes))
+	for node, handler := range m.broker.nodes {
+		if node != m {
+			handlers = append(handlers, handler)
+		}
+	}
+	m.broker.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(topic, message, mType)
+	}
+	return nil
+}
+
+// Subscribe implements Backplane.
+func (m *MemoryBackplane) Subscribe(handler func(topic string, message []byte, mType int)) error {
+	m.broker.mu.Lock()
+	defer m.broker.mu.Unlock()
+	if _, ok := m.broker.nodes[m]; ok {
+		return errors.New("memory backplane: already subscribed")
+	}
+	m.broker.nodes[m] = handler
+	return nil
+}
+
+// Close implements Backplane.
+func (m *MemoryBackplane) Close() error {
+	m.broker.mu.Lock()
+	delete(m.broker.nodes, m)
+	m.broker.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,147 @@
+package event
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func receiveQueued(t *testing.T, kws *Websocket) message {
+	t.Helper()
+	select {
+	case msg := <-kws.queue:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("message not queued")
+		return message{}
+	}
+}
+
+func TestHubPublishReachesSubscribersOnly(t *testing.T) {
+	resetState()
+
+	hub, err := NewHub()
+	require.NoError(t, err)
+	defer func() { _ = hub.Close() }()
+
+	a, b, c := createWS(), createWS(), createWS()
+	require.NoError(t, hub.Subscribe(a, "news"))
+	require.NoError(t, hub.Subscribe(a, "news"))
+	require.NoError(t, hub.Subscribe(b, "news"))
+	require.NoError(t, hub.Subscribe(c, "sports"))
+	require.Equal(t, 2, hub.Subscribers("news"))
+	require.Equal(t, []string{"news"}, hub.Topics(a))
+
+	require.NoError(t, hub.Publish("news", []byte("hello"), BinaryMessage))
+	for _, kws := range []*Websocket{a, b} {
+		msg := receiveQueued(t, kws)
+		require.Equal(t, "hello", string(msg.data))
+		require.Equal(t, BinaryMessage, msg.mType)
+	}
+	require.Empty(t, c.queue)
+
+	hub.Unsubscribe(b, "news")
+	require.NoError(t, hub.Publish("news", []byte("again")))
+	require.Equal(t, TextMessage, receiveQueued(t, a).mType)
+	require.Empty(t, b.queue)
+	require.Nil(t, hub.Topics(b))
+}
+
+func TestHubDisconnectUnsubscribes(t *testing.T) {
+	resetState()
+
+	hub, err := NewHub()
+	require.NoError(t, err)
+	defer func() { _ = hub.Close() }()
+
+	kws := createWS()
+	require.NoError(t, hub.Subscribe(kws, "a"))
+	require.NoError(t, hub.Subscribe(kws, "b"))
+
+	kws.disconnected(nil)
+	require.Zero(t, hub.Subscribers("a"))
+	require.Zero(t, hub.Subscribers("b"))
+	require.ErrorIs(t, hub.Subscribe(kws, "a"), ErrorInvalidConnection)
+}
+
+func TestHubMemoryBackplane(t *testing.T) {
+	resetState()
+
+	broker := NewMemoryBroker()
+	hubA, err := NewHub(HubConfig{Backplane: broker.Backplane()})
+	require.NoError(t, err)
+	hubB, err := NewHub(HubConfig{Backplane: broker.Backplane()})
+	require.NoError(t, err)
+	defer func() { _ = hubB.Close() }()
+
+	onA, onB := createWS(), createWS()
+	require.NoError(t, hubA.Subscribe(onA, "room"))
+	require.NoError(t, hubB.Subscribe(onB, "room"))
+
+	// Each node delivers exactly once: locally, then through the broker.
+	require.NoError(t, hubA.Publish("room", []byte("from a")))
+	require.Equal(t, "from a", string(receiveQueued(t, onA).data))
+	require.Equal(t, "from a", string(receiveQueued(t, onB).data))
+	require.Empty(t, onA.queue)
+
+	require.NoError(t, hubA.Close())
+	require.ErrorIs(t, hubA.Publish("room", nil), ErrorHubClosed)
+	require.ErrorIs(t, hubA.Subscribe(onA, "room"), ErrorHubClosed)
+	require.NoError(t, hubB.Publish("room", []byte("from b")))
+	require.Equal(t, "from b", string(receiveQueued(t, onB).data))
+	require.Empty(t, onA.queue)
+}
+
+func TestHubSlowSubscriberDoesNotStallDelivery(t *testing.T) {
+	resetState()
+
+	broker := NewMemoryBroker()
+	hubA, err := NewHub(HubConfig{Backplane: broker.Backplane()})
+	require.NoError(t, err)
+	defer func() { _ = hubA.Close() }()
+	hubB, err := NewHub(HubConfig{
+		Backplane:       broker.Backplane(),
+		DeliveryTimeout: 20 * time.Millisecond,
+		DisconnectSlow:  true,
+	})
+	require.NoError(t, err)
+	defer func() { _ = hubB.Close() }()
+
+	stalled, fast := createWS(), createWS()
+	errs := make(chan error, 1)
+	stalled.On(EventError, func(p *EventPayload) { errs <- p.Error })
+	require.NoError(t, hubB.Subscribe(stalled, "room"))
+	require.NoError(t, hubB.Subscribe(fast, "room"))
+	// Nobody drains the queue of stalled, so it stays full.
+	stalled.queue <- message{data: []byte("backlog")}
+
+	published := make(chan error, 1)
+	go func() { published <- hubA.Publish("room", []byte("news")) }()
+	select {
+	case err := <-published:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a stalled subscriber")
+	}
+
+	require.Equal(t, "news", string(receiveQueued(t, fast).data))
+	require.ErrorIs(t, <-errs, ErrorSlowSubscriber)
+	require.Eventually(t, func() bool { return !stalled.IsAlive() }, time.Second, 5*time.Millisecond)
+	require.Equal(t, 1, hubB.Subscribers("room"))
+}
+
+type failingBackplane struct {
+	MemoryBackplane
+}
+
+var errBackplane = errors.New("backplane down")
+
+func (*failingBackplane) Subscribe(func(string, []byte, int)) error { return errBackplane }
+
+func TestNewHubBackplaneSubscribeError(t *testing.T) {
+	hub, err := NewHub(HubConfig{Backplane: &failingBackplane{}})
+	require.ErrorIs(t, err, errBackplane)
+	require.Nil(t, hub)
+}
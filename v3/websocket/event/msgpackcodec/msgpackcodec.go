@@ -0,0 +1,28 @@
+// Package msgpackcodec provides an event.Codec that encodes values as
+// MessagePack:
+//
+//	s := event.NewServer(event.Config{Codec: msgpackcodec.Codec{}})
+//
+// It is a module of its own, so applications that only send JSON do not
+// depend on the MessagePack library.
+package msgpackcodec
+
+import (
+	"github.com/gofiber/contrib/v3/websocket/event"
+	"github.com/shamaton/msgpack/v2"
+)
+
+// Codec encodes values as MessagePack into binary frames. Struct fields are
+// named by their `msgpack` tag, or their name without one.
+type Codec struct{}
+
+var _ event.Codec = Codec{}
+
+// Marshal implements event.Codec.
+func (Codec) Marshal(v any) ([]byte, error) { return msgpack.Marshal(v) }
+
+// Unmarshal implements event.Codec.
+func (Codec) Unmarshal(data []byte, v any) error { return msgpack.Unmarshal(data, v) }
+
+// MessageType implements event.Codec.
+func (Codec) MessageType() int { return event.BinaryMessage }
@@ -0,0 +1,22 @@
+package msgpackcodec
+
+import (
+	"testing"
+
+	"github.com/gofiber/contrib/v3/websocket/event"
+	"github.com/stretchr/testify/require"
+)
+
+type chatMessage struct {
+	From string `msgpack:"from"`
+	Text string `msgpack:"text"`
+}
+
+func TestCodec(t *testing.T) {
+	data, err := Codec{}.Marshal(chatMessage{From: "alice", Text: "hi"})
+	require.NoError(t, err)
+	var got chatMessage
+	require.NoError(t, Codec{}.Unmarshal(data, &got))
+	require.Equal(t, chatMessage{From: "alice", Text: "hi"}, got)
+	require.Equal(t, event.BinaryMessage, Codec{}.MessageType())
+}
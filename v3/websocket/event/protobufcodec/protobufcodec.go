@@ -0,0 +1,50 @@
+// Package protobufcodec provides an event.Codec for Protocol Buffers
+// messages:
+//
+//	s := event.NewServer(event.Config{Codec: protobufcodec.Codec{}})
+//	s.On("chat", event.Typed(func(ep *event.EventPayload, msg *pb.Chat) {
+//		// ...
+//	}))
+//
+// It is a module of its own, so applications that only send JSON do not
+// depend on the protobuf runtime.
+package protobufcodec
+
+import (
+	"errors"
+
+	"github.com/gofiber/contrib/v3/websocket/event"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNotProtoMessage is returned by Codec for values that do not implement
+// proto.Message.
+var ErrNotProtoMessage = errors.New("value does not implement proto.Message")
+
+// Codec encodes proto.Message values into binary frames. Other values fail
+// with ErrNotProtoMessage, so Typed listeners must use a message pointer
+// type such as *pb.Chat.
+type Codec struct{}
+
+var _ event.Codec = Codec{}
+
+// Marshal implements event.Codec.
+func (Codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal implements event.Codec.
+func (Codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// MessageType implements event.Codec.
+func (Codec) MessageType() int { return event.BinaryMessage }
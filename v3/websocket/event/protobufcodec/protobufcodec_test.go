@@ -0,0 +1,25 @@
+package protobufcodec
+
+import (
+	"testing"
+
+	"github.com/gofiber/contrib/v3/websocket/event"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodec(t *testing.T) {
+	codec := Codec{}
+	data, err := codec.Marshal(wrapperspb.String("hi"))
+	require.NoError(t, err)
+	got := &wrapperspb.StringValue{}
+	require.NoError(t, codec.Unmarshal(data, got))
+	require.True(t, proto.Equal(wrapperspb.String("hi"), got))
+	require.Equal(t, event.BinaryMessage, codec.MessageType())
+
+	type plain struct{ Text string }
+	_, err = codec.Marshal(plain{})
+	require.ErrorIs(t, err, ErrNotProtoMessage)
+	require.ErrorIs(t, codec.Unmarshal(data, &plain{}), ErrNotProtoMessage)
+}
@@ -0,0 +1,160 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QueueStats is a snapshot of the outbound queue of a connection.
+type QueueStats struct {
+	// Depth is the number of messages waiting to be written.
+	Depth int
+	// Capacity is the size of the queue, see Config.SendQueueSize.
+	Capacity int
+	// Sent is the number of messages written to the socket.
+	Sent uint64
+	// LastLatency is the time the most recently written message spent
+	// between being queued and being written.
+	LastLatency time.Duration
+	// MaxLatency is the highest latency of any written message.
+	MaxLatency time.Duration
+	// AverageLatency is the mean latency of all written messages.
+	AverageLatency time.Duration
+}
+
+// queueStats accumulates the counters behind QueueStats. It is updated by
+// the writer only and read from any goroutine.
+type queueStats struct {
+	sent         atomic.Uint64
+	totalLatency atomic.Int64
+	lastLatency  atomic.Int64
+	maxLatency   atomic.Int64
+}
+
+func (s *queueStats) record(latency time.Duration) {
+	s.totalLatency.Add(int64(latency))
+	s.lastLatency.Store(int64(latency))
+	if int64(latency) > s.maxLatency.Load() {
+		s.maxLatency.Store(int64(latency))
+	}
+	s.sent.Add(1)
+}
+
+// writableSignal wakes producers waiting in Writable once the writer has
+// taken a message off the queue.
+type writableSignal struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+// closedChan is returned by Writable when there is room already.
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+func (w *writableSignal) wait(full func() bool) <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !full() {
+		return closedChan
+	}
+	if w.ch == nil {
+		w.ch = make(chan struct{})
+	}
+	return w.ch
+}
+
+func (w *writableSignal) notify() {
+	w.mu.Lock()
+	if w.ch != nil {
+		close(w.ch)
+		w.ch = nil
+	}
+	w.mu.Unlock()
+}
+
+// EmitContext writes a message to the current connection like Emit, but
+// gives up when ctx is done while the send queue is full. It returns
+// ctx.Err() in that case and ErrorInvalidConnection when the connection is
+// closed before the message could be queued.
+func (kws *Websocket) EmitContext(ctx context.Context, message []byte, mType ...int) error {
+	t := TextMessage
+	if len(mType) > 0 {
+		t = mType[0]
+	}
+	if !kws.IsAlive() {
+		return ErrorInvalidConnection
+	}
+	return kws.enqueue(ctx, t, message)
+}
+
+// Writable returns a channel that is closed once the send queue has room
+// for another message, or immediately if it has room already. A producer
+// can wait on it together with its own sources instead of blocking in
+// Emit:
+//
+//	select {
+//	case <-kws.Writable():
+//	    kws.Emit(tick)
+//	case <-ctx.Done():
+//	    return
+//	}
+//
+// Other producers may take the free slot first, so Emit can still block
+// briefly when several goroutines write to the same connection. The
+// channel is also closed when the connection disconnects.
+func (kws *Websocket) Writable() <-chan struct{} {
+	select {
+	case <-kws.done:
+		return closedChan
+	default:
+	}
+	return kws.writable.wait(func() bool {
+		return len(kws.queue) >= cap(kws.queue)
+	})
+}
+
+// QueueStats returns the current depth of the send queue and the latency
+// between queueing and writing of the messages sent so far.
+func (kws *Websocket) QueueStats() QueueStats {
+	stats := QueueStats{
+		Depth:       len(kws.queue),
+		Capacity:    cap(kws.queue),
+		Sent:        kws.stats.sent.Load(),
+		LastLatency: time.Duration(kws.stats.lastLatency.Load()),
+		MaxLatency:  time.Duration(kws.stats.maxLatency.Load()),
+	}
+	if stats.Sent > 0 {
+		stats.AverageLatency = time.Duration(kws.stats.totalLatency.Load() / int64(stats.Sent))
+	}
+	return stats
+}
+
+// enqueue puts a message on the send queue, waiting for room until ctx is
+// done or the connection closes.
+func (kws *Websocket) enqueue(ctx context.Context, mType int, data []byte) error {
+	msg := message{
+		mType:  mType,
+		data:   data,
+		queued: time.Now(),
+	}
+
+	// Prefer a free slot over an already cancelled ctx.
+	select {
+	case kws.queue <- msg:
+		return nil
+	default:
+	}
+	select {
+	case kws.queue <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-kws.done:
+		return ErrorInvalidConnection
+	}
+}
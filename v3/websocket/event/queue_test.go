@@ -0,0 +1,76 @@
+package event
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmitContextWaitsForQueueSpace(t *testing.T) {
+	resetState()
+	kws := createWS()
+
+	require.NoError(t, kws.EmitContext(context.Background(), []byte("first")))
+	require.Equal(t, QueueStats{Depth: 1, Capacity: 1}, kws.QueueStats())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, kws.EmitContext(ctx, []byte("second")), context.DeadlineExceeded)
+
+	writable := kws.Writable()
+	select {
+	case <-writable:
+		t.Fatal("writable while the queue is full")
+	default:
+	}
+
+	// The writer takes the message off the queue, which wakes the producer.
+	<-kws.queue
+	kws.writable.notify()
+	select {
+	case <-writable:
+	case <-time.After(time.Second):
+		t.Fatal("writable not signalled")
+	}
+	require.NoError(t, kws.EmitContext(context.Background(), []byte("second")))
+
+	kws.Close()
+	require.ErrorIs(t, kws.EmitContext(context.Background(), []byte("third")), ErrorInvalidConnection)
+	select {
+	case <-kws.Writable():
+	default:
+		t.Fatal("writable not closed after disconnect")
+	}
+}
+
+func TestQueueStatsCountsWrittenMessages(t *testing.T) {
+	s := NewServer(Config{SendQueueSize: 2})
+	conn, kws := newEnvelopeServer(t, s)
+
+	const n = 20
+	go func() {
+		for i := 0; i < n; i++ {
+			if err := kws.EmitContext(context.Background(), []byte(strconv.Itoa(i))); err != nil {
+				return
+			}
+		}
+	}()
+	for i := 0; i < n; i++ {
+		_, msg, err := conn.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, strconv.Itoa(i), string(msg))
+	}
+
+	require.Eventually(t, func() bool {
+		return kws.QueueStats().Sent == n
+	}, time.Second, time.Millisecond)
+	stats := kws.QueueStats()
+	require.Equal(t, 2, stats.Capacity)
+	require.Zero(t, stats.Depth)
+	require.Positive(t, stats.MaxLatency)
+	require.GreaterOrEqual(t, stats.MaxLatency, stats.AverageLatency)
+	require.GreaterOrEqual(t, stats.MaxLatency, stats.LastLatency)
+}
@@ -0,0 +1,205 @@
+// Package redisbackplane provides an event.Backplane that fans Hub
+// publishes out to every server instance over Redis pub/sub.
+//
+// It is designed to share the connection of a
+// github.com/gofiber/storage/redis/v3 store:
+//
+//	store := redis.New(redis.Config{URL: "redis://localhost:6379"})
+//	hub, err := event.NewHub(event.HubConfig{
+//		Backplane: redisbackplane.New(redisbackplane.Config{Client: store.Conn()}),
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer hub.Close()
+package redisbackplane
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/v3/websocket/event"
+	"github.com/redis/go-redis/v9"
+)
+
+// Config controls the Redis backplane.
+type Config struct {
+	// Client is the Redis connection used for PUBLISH and SUBSCRIBE.
+	// Pass Conn() of a github.com/gofiber/storage/redis/v3 Storage to
+	// reuse the application's existing pool.
+	//
+	// Required.
+	Client redis.UniversalClient
+
+	// Channel is the pub/sub channel shared by every node of the
+	// deployment. All topics travel over this one channel; use a
+	// distinct channel per independent hub sharing the same Redis.
+	//
+	// Optional. Default: "fiber:websocket:hub"
+	Channel string
+
+	// PublishTimeout bounds a single PUBLISH round trip.
+	//
+	// Optional. Default: 5 * time.Second
+	PublishTimeout time.Duration
+}
+
+// ConfigDefault is the default config.
+var ConfigDefault = Config{
+	Channel:        "fiber:websocket:hub",
+	PublishTimeout: 5 * time.Second,
+}
+
+// ErrClientRequired is returned by Publish and Subscribe when
+// Config.Client is nil.
+var ErrClientRequired = errors.New("websocket redis backplane: client is required")
+
+// nodeIDLen is the length of the hex node id prefixed to every message.
+const nodeIDLen = 16
+
+// redisClient is the subset of go-redis the backplane uses.
+type redisClient interface {
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// Backplane is an event.Backplane backed by Redis pub/sub. Messages are
+// framed as the node id, the message type byte, the uvarint length of
+// the topic, the topic and the payload.
+type Backplane struct {
+	config Config
+	client redisClient
+	// nodeID prefixes every published message so a node can skip its own
+	// messages when Redis echoes them back.
+	nodeID string
+
+	mu     sync.Mutex
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+var _ event.Backplane = (*Backplane)(nil)
+
+// New creates a Redis backplane. Pass it to event.NewHub, which opens the
+// subscription.
+func New(config ...Config) *Backplane {
+	cfg := configDefault(config...)
+	return &Backplane{
+		config: cfg,
+		client: cfg.Client,
+		nodeID: newNodeID(),
+	}
+}
+
+func configDefault(config ...Config) Config {
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+	cfg := config[0]
+	if cfg.Channel == "" {
+		cfg.Channel = ConfigDefault.Channel
+	}
+	if cfg.PublishTimeout <= 0 {
+		cfg.PublishTimeout = ConfigDefault.PublishTimeout
+	}
+	return cfg
+}
+
+func newNodeID() string {
+	var b [nodeIDLen / 2]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Publish implements event.Backplane.
+func (b *Backplane) Publish(topic string, message []byte, mType int) error {
+	if b.client == nil {
+		return ErrClientRequired
+	}
+	msg := make([]byte, 0, nodeIDLen+1+binary.MaxVarintLen64+len(topic)+len(message))
+	msg = append(msg, b.nodeID...)
+	msg = append(msg, byte(mType))
+	msg = binary.AppendUvarint(msg, uint64(len(topic)))
+	msg = append(msg, topic...)
+	msg = append(msg, message...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.PublishTimeout)
+	defer cancel()
+	return b.client.Publish(ctx, b.config.Channel, msg).Err()
+}
+
+// Subscribe implements event.Backplane. It blocks until Redis confirms
+// the subscription, then hands every message published by another node
+// to handler from a single background goroutine, preserving order.
+// Malformed messages are skipped.
+func (b *Backplane) Subscribe(handler func(topic string, message []byte, mType int)) error {
+	if b.client == nil {
+		return ErrClientRequired
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pubsub != nil {
+		return errors.New("websocket redis backplane: already subscribed")
+	}
+
+	ctx := context.Background()
+	pubsub := b.client.Subscribe(ctx, b.config.Channel)
+	// Receive waits for the SUBSCRIBE confirmation so messages published
+	// right after NewHub returns are not missed.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return err
+	}
+	b.pubsub = pubsub
+	b.done = make(chan struct{})
+
+	ch := pubsub.Channel()
+	go func() {
+		defer close(b.done)
+		for msg := range ch {
+			payload := msg.Payload
+			if len(payload) < nodeIDLen+1 || payload[:nodeIDLen] == b.nodeID {
+				continue
+			}
+			topic, data, mType, ok := decode([]byte(payload[nodeIDLen:]))
+			if !ok {
+				continue
+			}
+			handler(topic, data, mType)
+		}
+	}()
+	return nil
+}
+
+// decode splits a message body after the node id.
+func decode(body []byte) (topic string, data []byte, mType int, ok bool) {
+	mType = int(body[0])
+	n, size := binary.Uvarint(body[1:])
+	if size <= 0 || n > uint64(len(body)-1-size) {
+		return "", nil, 0, false
+	}
+	start := 1 + size
+	end := start + int(n)
+	return string(body[start:end]), body[end:], mType, true
+}
+
+// Close implements event.Backplane. It ends the subscription and waits
+// for the delivery goroutine to exit. The Redis client is left open; it
+// belongs to the caller.
+func (b *Backplane) Close() error {
+	b.mu.Lock()
+	pubsub, done := b.pubsub, b.done
+	b.pubsub = nil
+	b.mu.Unlock()
+	if pubsub == nil {
+		return nil
+	}
+	err := pubsub.Close()
+	<-done
+	return err
+}
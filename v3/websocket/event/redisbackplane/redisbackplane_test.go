@@ -0,0 +1,110 @@
+package redisbackplane
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/contrib/v3/websocket/event"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+type delivery struct {
+	topic   string
+	message string
+	mType   int
+}
+
+func newTestClient(t *testing.T) *redis.Client {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// collect subscribes b and returns a channel fed with every message it
+// receives from other nodes.
+func collect(t *testing.T, b *Backplane) <-chan delivery {
+	t.Helper()
+	ch := make(chan delivery, 16)
+	require.NoError(t, b.Subscribe(func(topic string, message []byte, mType int) {
+		ch <- delivery{topic: topic, message: string(message), mType: mType}
+	}))
+	t.Cleanup(func() { _ = b.Close() })
+	return ch
+}
+
+func receive(t *testing.T, ch <-chan delivery) delivery {
+	t.Helper()
+	select {
+	case d := <-ch:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not delivered")
+		return delivery{}
+	}
+}
+
+func Test_Backplane_PublishReachesOtherNodesOnly(t *testing.T) {
+	client := newTestClient(t)
+	nodeA := New(Config{Client: client})
+	nodeB := New(Config{Client: client})
+	other := New(Config{Client: client, Channel: "other"})
+
+	fromA := collect(t, nodeA)
+	fromB := collect(t, nodeB)
+	fromOther := collect(t, other)
+
+	require.NoError(t, nodeA.Publish("news", []byte("hello"), event.TextMessage))
+	require.Equal(t, delivery{"news", "hello", event.TextMessage}, receive(t, fromB))
+
+	require.NoError(t, nodeB.Publish("", []byte{0, 1}, event.BinaryMessage))
+	require.Equal(t, delivery{"", "\x00\x01", event.BinaryMessage}, receive(t, fromA))
+
+	// Nodes never see their own messages, and channels are isolated.
+	require.Empty(t, fromA)
+	require.Empty(t, fromB)
+	require.Empty(t, fromOther)
+}
+
+func Test_Backplane_SkipsMalformedMessages(t *testing.T) {
+	client := newTestClient(t)
+	b := New(Config{Client: client})
+	ch := collect(t, b)
+
+	// A foreign node id followed by a topic length past the end.
+	require.NoError(t, client.Publish(context.Background(), ConfigDefault.Channel, "ffffffffffffffff\x01\x09abc").Err())
+	require.NoError(t, New(Config{Client: client}).Publish("t", []byte("ok"), event.TextMessage))
+	require.Equal(t, delivery{"t", "ok", event.TextMessage}, receive(t, ch))
+}
+
+func Test_Backplane_RequiresClient(t *testing.T) {
+	b := New()
+	require.ErrorIs(t, b.Publish("t", nil, event.TextMessage), ErrClientRequired)
+	require.ErrorIs(t, b.Subscribe(func(string, []byte, int) {}), ErrClientRequired)
+	require.NoError(t, b.Close())
+}
+
+func Test_Backplane_DoubleSubscribe(t *testing.T) {
+	b := New(Config{Client: newTestClient(t)})
+	collect(t, b)
+	require.Error(t, b.Subscribe(func(string, []byte, int) {}))
+}
+
+// Test_Backplane_HubFanOut publishes on one hub and checks that a hub on
+// another "node" hands the message to the Redis side.
+func Test_Backplane_HubFanOut(t *testing.T) {
+	client := newTestClient(t)
+	remote := New(Config{Client: client})
+	fromHub := collect(t, remote)
+
+	hub, err := event.NewHub(event.HubConfig{Backplane: New(Config{Client: client})})
+	require.NoError(t, err)
+	defer func() { _ = hub.Close() }()
+
+	require.NoError(t, hub.Publish("room", []byte("hi")))
+	require.Equal(t, delivery{"room", "hi", event.TextMessage}, receive(t, fromHub))
+}
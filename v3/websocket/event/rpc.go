@@ -0,0 +1,288 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+var (
+	// ErrorEnvelopeDisabled is returned by Request on a connection whose
+	// Config does not enable Envelope.
+	ErrorEnvelopeDisabled = errors.New("request needs the JSON envelope mode (Config.Envelope)")
+	// ErrorNoRequestHandler is the reply error sent to the peer when a
+	// request names an event without a RequestHandler.
+	ErrorNoRequestHandler = errors.New("no request handler for event")
+
+	// errRequestHandlerPanic is the reply error sent for a panicking
+	// RequestHandler; the panic value itself stays on the server.
+	errRequestHandlerPanic = errors.New("internal error")
+)
+
+// ReplyError is returned by Request when the peer answered with an error.
+type ReplyError struct {
+	// Message is the "error" member of the reply envelope.
+	Message string
+}
+
+// Error implements error.
+func (e *ReplyError) Error() string {
+	return "request failed: " + e.Message
+}
+
+// RequestHandler answers a request received in the JSON envelope mode. The
+// returned value is encoded with encoding/json as the "data" member of the
+// reply; a non-nil error is sent as its "error" member instead, so it must
+// not carry details the peer should not see.
+// payload.Data holds the raw JSON "data" member of the request.
+type RequestHandler func(payload *EventPayload) (any, error)
+
+// envelope is the JSON frame of the envelope mode. A frame with an event
+// and no id is a plain event, one with both is a request, and one with an
+// id but no event is the reply to the request with that id.
+type envelope struct {
+	ID    json.RawMessage `json:"id,omitempty"`
+	Event string          `json:"event,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// reservedEvents are the lifecycle events a peer must not fire through an
+// envelope.
+var reservedEvents = map[string]struct{}{
+	EventMessage:    {},
+	EventPing:       {},
+	EventPong:       {},
+	EventDisconnect: {},
+	EventConnect:    {},
+	EventClose:      {},
+	EventError:      {},
+}
+
+type safeRequestHandlers struct {
+	sync.RWMutex
+	list map[string]RequestHandler
+}
+
+func (h *safeRequestHandlers) set(event string, handler RequestHandler) {
+	h.Lock()
+	defer h.Unlock()
+	if handler == nil {
+		delete(h.list, event)
+		return
+	}
+	if h.list == nil {
+		h.list = make(map[string]RequestHandler)
+	}
+	h.list[event] = handler
+}
+
+func (h *safeRequestHandlers) get(event string) RequestHandler {
+	h.RLock()
+	defer h.RUnlock()
+	return h.list[event]
+}
+
+// OnRequest registers the handler answering requests for event on every
+// connection of s that uses the envelope mode. A later registration for the
+// same event replaces the earlier one; a nil handler removes it.
+func (s *Server) OnRequest(event string, handler RequestHandler) {
+	s.requestHandlers.set(event, handler)
+}
+
+// OnRequest registers a request handler on the default server. See
+// Server.OnRequest.
+func OnRequest(event string, handler RequestHandler) {
+	defaultServer.OnRequest(event, handler)
+}
+
+// pendingRequests tracks the Request calls waiting for a reply.
+type pendingRequests struct {
+	mu   sync.Mutex
+	next uint64
+	list map[uint64]chan envelope
+}
+
+func (p *pendingRequests) add() (uint64, chan envelope) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.list == nil {
+		p.list = make(map[uint64]chan envelope)
+	}
+	p.next++
+	ch := make(chan envelope, 1)
+	p.list[p.next] = ch
+	return p.next, ch
+}
+
+func (p *pendingRequests) remove(id uint64) {
+	p.mu.Lock()
+	delete(p.list, id)
+	p.mu.Unlock()
+}
+
+// resolve hands reply to the waiting Request. Replies to unknown or
+// expired ids are dropped.
+func (p *pendingRequests) resolve(reply envelope) {
+	id, err := strconv.ParseUint(string(reply.ID), 10, 64)
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	ch, ok := p.list[id]
+	delete(p.list, id)
+	p.mu.Unlock()
+	if ok {
+		ch <- reply
+	}
+}
+
+// Request sends event with data to the peer as a JSON envelope request and
+// waits for the correlated reply. data is encoded with encoding/json; pass a
+// json.RawMessage to send pre-encoded JSON. The reply's raw "data" member is
+// returned, or a *ReplyError when the peer answered with an error.
+//
+// Request waits until ctx is done or Config.RequestTimeout elapses,
+// whichever comes first, and returns ErrorInvalidConnection when the
+// connection closes meanwhile. It needs Config.Envelope.
+func (kws *Websocket) Request(ctx context.Context, event string, data any) (json.RawMessage, error) {
+	if !kws.settings.envelope {
+		return nil, ErrorEnvelopeDisabled
+	}
+	if !kws.IsAlive() {
+		return nil, ErrorInvalidConnection
+	}
+
+	var raw json.RawMessage
+	if data != nil {
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			return nil, err
+		}
+	}
+	id, ch := kws.requests.add()
+	defer kws.requests.remove(id)
+	frame, err := json.Marshal(envelope{
+		ID:    json.RawMessage(strconv.FormatUint(id, 10)),
+		Event: event,
+		Data:  raw,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, kws.settings.requestTimeout)
+	defer cancel()
+
+	if err := kws.enqueue(ctx, TextMessage, frame); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-ch:
+		if reply.Error != "" {
+			return nil, &ReplyError{Message: reply.Error}
+		}
+		return reply.Data, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-kws.done:
+		return nil, ErrorInvalidConnection
+	}
+}
+
+// dispatchEnvelope routes an inbound envelope frame. It reports false when
+// msg is not an envelope, in which case the caller fires EventMessage.
+//
+// Replies are resolved right here on the read goroutine; events and
+// requests are handed to dispatch, so a handler waiting in Request does not
+// keep its own reply from being read.
+func (kws *Websocket) dispatchEnvelope(msg []byte) bool {
+	var env envelope
+	if err := json.Unmarshal(msg, &env); err != nil {
+		return false
+	}
+	hasID := len(env.ID) > 0 && string(env.ID) != "null"
+	if env.Event == "" {
+		if !hasID {
+			return false
+		}
+		kws.requests.resolve(env)
+		return true
+	}
+	if _, ok := reservedEvents[env.Event]; ok {
+		return false
+	}
+	if !hasID {
+		env.ID = nil
+	}
+	select {
+	case kws.inbox <- env:
+	case <-kws.done:
+	}
+	return true
+}
+
+// dispatch runs the listeners and request handlers of the envelopes read
+// by read, in the order they arrived.
+func (kws *Websocket) dispatch(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case env := <-kws.inbox:
+			if env.ID == nil {
+				kws.fireEvent(env.Event, env.Data, nil)
+			} else {
+				kws.handleRequest(env)
+			}
+		}
+	}
+}
+
+// handleRequest runs the RequestHandler for env and queues the reply.
+func (kws *Websocket) handleRequest(env envelope) {
+	reply := envelope{ID: env.ID}
+	data, err := kws.invokeRequestHandler(env)
+	if err == nil {
+		reply.Data, err = json.Marshal(data)
+	}
+	if err != nil {
+		reply.Data = nil
+		reply.Error = err.Error()
+	}
+	frame, err := json.Marshal(reply)
+	if err != nil {
+		return
+	}
+	kws.write(TextMessage, frame)
+}
+
+// invokeRequestHandler calls the handler registered for env.Event with the
+// same panic recovery as listener callbacks.
+func (kws *Websocket) invokeRequestHandler(env envelope) (data any, err error) {
+	handler := kws.server.requestHandlers.get(env.Event)
+	if handler == nil {
+		return nil, ErrorNoRequestHandler
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if kws.settings.recover != nil {
+				kws.settings.recover(env.Event, r)
+			}
+			data, err = nil, errRequestHandlerPanic
+		}
+	}()
+
+	// json.Unmarshal copies RawMessage values, so env.Data does not alias
+	// the read buffer.
+	return handler(&EventPayload{
+		Kws:              kws,
+		Name:             env.Event,
+		SocketUUID:       kws.GetUUID(),
+		SocketAttributes: kws.attributesSnapshot(),
+		Data:             env.Data,
+	})
+}
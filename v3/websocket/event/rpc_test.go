@@ -0,0 +1,189 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// newEnvelopeServer serves s on an in-memory listener and returns a dialed
+// client plus the server side of the connection.
+func newEnvelopeServer(t *testing.T, s *Server) (*websocket.Conn, *Websocket) {
+	t.Helper()
+	app := fiber.New()
+	ln := fasthttputil.NewInmemoryListener()
+	t.Cleanup(func() {
+		_ = app.Shutdown()
+		_ = ln.Close()
+	})
+
+	kwsCh := make(chan *Websocket, 1)
+	app.Use(upgradeMiddleware)
+	app.Get("/", s.Handler(func(kws *Websocket) { kwsCh <- kws }))
+	go func() { _ = app.Listener(ln) }()
+
+	dialer := &websocket.Dialer{
+		NetDial:          func(_, _ string) (net.Conn, error) { return ln.Dial() },
+		HandshakeTimeout: 5 * time.Second,
+	}
+	conn, _, err := dialWithRetry(dialer, "ws://"+ln.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	select {
+	case kws := <-kwsCh:
+		t.Cleanup(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_ = s.CloseAll(ctx, websocket.CloseNormalClosure, "")
+		})
+		return conn, kws
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not established")
+		return nil, nil
+	}
+}
+
+func readEnvelope(t *testing.T, conn *websocket.Conn) envelope {
+	t.Helper()
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var env envelope
+	require.NoError(t, json.Unmarshal(msg, &env))
+	return env
+}
+
+func TestEnvelopeClientRequest(t *testing.T) {
+	s := NewServer(Config{Envelope: true})
+	s.OnRequest("sum", func(p *EventPayload) (any, error) {
+		var nums []int
+		if err := json.Unmarshal(p.Data, &nums); err != nil {
+			return nil, errors.New("bad input")
+		}
+		total := 0
+		for _, n := range nums {
+			total += n
+		}
+		return total, nil
+	})
+	s.OnRequest("boom", func(*EventPayload) (any, error) { panic("secret") })
+	events := make(chan string, 4)
+	s.On("chat", func(p *EventPayload) { events <- string(p.Data) })
+	s.On(EventMessage, func(p *EventPayload) { events <- "message:" + string(p.Data) })
+
+	conn, _ := newEnvelopeServer(t, s)
+	write := func(frame string) {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(frame)))
+	}
+
+	write(`{"id":"a1","event":"sum","data":[1,2,3]}`)
+	require.Equal(t, envelope{ID: json.RawMessage(`"a1"`), Data: json.RawMessage(`6`)}, readEnvelope(t, conn))
+
+	write(`{"id":2,"event":"sum","data":"x"}`)
+	require.Equal(t, envelope{ID: json.RawMessage(`2`), Error: "bad input"}, readEnvelope(t, conn))
+
+	write(`{"id":3,"event":"missing"}`)
+	require.Equal(t, ErrorNoRequestHandler.Error(), readEnvelope(t, conn).Error)
+
+	write(`{"id":4,"event":"boom"}`)
+	require.Equal(t, "internal error", readEnvelope(t, conn).Error)
+
+	// Plain envelope events fire their listeners; anything else, including
+	// reserved event names, still arrives as EventMessage.
+	write(`{"event":"chat","data":"hi"}`)
+	require.Equal(t, `"hi"`, <-events)
+	write(`{"event":"disconnect"}`)
+	require.Equal(t, `message:{"event":"disconnect"}`, <-events)
+	write(`plain`)
+	require.Equal(t, "message:plain", <-events)
+}
+
+func TestEnvelopeServerRequest(t *testing.T) {
+	s := NewServer(Config{Envelope: true, RequestTimeout: 100 * time.Millisecond})
+	conn, kws := newEnvelopeServer(t, s)
+
+	type result struct {
+		reply json.RawMessage
+		err   error
+	}
+	request := func(data any) <-chan result {
+		ch := make(chan result, 1)
+		go func() {
+			reply, err := kws.Request(context.Background(), "ask", data)
+			ch <- result{reply, err}
+		}()
+		return ch
+	}
+
+	pending := request(map[string]int{"n": 1})
+	req := readEnvelope(t, conn)
+	require.Equal(t, "ask", req.Event)
+	require.JSONEq(t, `{"n":1}`, string(req.Data))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"id":`+string(req.ID)+`,"data":"yes"}`)))
+	res := <-pending
+	require.NoError(t, res.err)
+	require.Equal(t, `"yes"`, string(res.reply))
+
+	pending = request(nil)
+	req = readEnvelope(t, conn)
+	require.Nil(t, req.Data)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"id":`+string(req.ID)+`,"error":"no"}`)))
+	res = <-pending
+	var replyErr *ReplyError
+	require.ErrorAs(t, res.err, &replyErr)
+	require.Equal(t, "no", replyErr.Message)
+
+	// Unanswered requests time out after RequestTimeout; a late reply is
+	// dropped.
+	pending = request(nil)
+	req = readEnvelope(t, conn)
+	require.ErrorIs(t, (<-pending).err, context.DeadlineExceeded)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"id":`+string(req.ID)+`,"data":1}`)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := kws.Request(ctx, "ask", nil)
+	require.ErrorIs(t, err, context.Canceled)
+
+	pending = request(nil)
+	readEnvelope(t, conn)
+	kws.Close()
+	require.ErrorIs(t, (<-pending).err, ErrorInvalidConnection)
+}
+
+func TestRequestFromRequestHandler(t *testing.T) {
+	s := NewServer(Config{Envelope: true, RequestTimeout: 2 * time.Second})
+	s.OnRequest("buy", func(p *EventPayload) (any, error) {
+		// The reply to this request is read while the handler waits.
+		reply, err := p.Kws.Request(context.Background(), "confirm", json.RawMessage(p.Data))
+		if err != nil {
+			return nil, err
+		}
+		return reply, nil
+	})
+	conn, _ := newEnvelopeServer(t, s)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"id":1,"event":"buy","data":"book"}`)))
+	req := readEnvelope(t, conn)
+	require.Equal(t, "confirm", req.Event)
+	require.Equal(t, `"book"`, string(req.Data))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"id":`+string(req.ID)+`,"data":true}`)))
+
+	require.Equal(t, envelope{ID: json.RawMessage(`1`), Data: json.RawMessage(`true`)}, readEnvelope(t, conn))
+}
+
+func TestRequestNeedsEnvelope(t *testing.T) {
+	resetState()
+	kws := createWS()
+	_, err := kws.Request(context.Background(), "ask", nil)
+	require.ErrorIs(t, err, ErrorEnvelopeDisabled)
+}
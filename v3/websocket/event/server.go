@@ -0,0 +1,234 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gofiber/contrib/v3/websocket"
+	"github.com/gofiber/fiber/v3"
+)
+
+// Server is an independent event endpoint. It owns its connection pool,
+// its listeners, its tuning and its drain state, so several servers can be
+// mounted in one process without seeing each other's connections:
+//
+//	chat := event.NewServer()
+//	admin := event.NewServer(event.Config{PingInterval: 5 * time.Second})
+//	app.Get("/ws/chat", chat.Handler(onChat))
+//	app.Get("/ws/admin", admin.Handler(onAdmin))
+//
+// The package-level New, On, Off, OnRequest, EmitTo, EmitToList, Broadcast, Fire,
+// Drain, IsDraining and CloseAll operate on a default server.
+type Server struct {
+	settings  settings
+	pool      safePool
+	listeners safeListeners
+	// requestHandlers answers envelope requests, see OnRequest.
+	requestHandlers safeRequestHandlers
+	// sessions holds the resumable sessions, see Config.ResumeSecret.
+	sessions sessionStore
+	draining atomic.Bool
+}
+
+// defaultServer backs the package-level functions. Handlers created by
+// NewWithConfig carry their own tuning, so its settings are unused.
+var defaultServer = NewServer()
+
+// NewServer creates a Server with the supplied tuning. Zero values in config
+// fall back to the package-level vars as for NewWithConfig; they are
+// resolved here, once.
+func NewServer(config ...Config) *Server {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	return &Server{
+		settings:  resolveSettings(cfg),
+		pool:      safePool{conn: make(map[string]ws)},
+		listeners: safeListeners{list: make(map[string][]EventCallback)},
+	}
+}
+
+// Handler returns a Fiber handler that upgrades the request to WebSocket and
+// adds the connection to s.
+func (s *Server) Handler(callback func(kws *Websocket), wsConfig ...websocket.Config) fiber.Handler {
+	return s.handler(callback, s.settings, wsConfig...)
+}
+
+func (s *Server) handler(callback func(kws *Websocket), st settings, wsConfig ...websocket.Config) fiber.Handler {
+	upgrade := s.upgrade(callback, st, wsConfig...)
+	return func(c fiber.Ctx) error {
+		if s.IsDraining() && websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrServiceUnavailable
+		}
+		return upgrade(c)
+	}
+}
+
+func (s *Server) upgrade(callback func(kws *Websocket), st settings, wsConfig ...websocket.Config) fiber.Handler {
+	return websocket.New(func(c *websocket.Conn) {
+		kws := &Websocket{
+			Conn:     c,
+			server:   s,
+			settings: st,
+			Locals: func(key string) interface{} {
+				return c.Locals(key)
+			},
+			Params: func(key string, defaultValue ...string) string {
+				return c.Params(key, defaultValue...)
+			},
+			Query: func(key string, defaultValue ...string) string {
+				return c.Query(key, defaultValue...)
+			},
+			Cookies: func(key string, defaultValue ...string) string {
+				return c.Cookies(key, defaultValue...)
+			},
+			queue:      make(chan message, st.sendQueueSize),
+			done:       make(chan struct{}),
+			attributes: make(map[string]interface{}),
+			isAlive:    true,
+		}
+		if st.envelope {
+			kws.inbox = make(chan envelope, st.sendQueueSize)
+		}
+
+		kws.UUID = kws.createUUID()
+		if st.resumeSecret != nil {
+			kws.resume(c.Query(st.resumeQuery))
+		}
+		s.pool.set(kws)
+
+		callback(kws)
+		kws.fireEvent(EventConnect, nil, nil)
+		kws.run()
+	}, wsConfig...)
+}
+
+// On registers a listener for an event on every connection of s. It stays
+// registered until removed with Off.
+func (s *Server) On(event string, callback EventCallback) {
+	s.listeners.set(event, callback)
+}
+
+// Off removes all listeners registered for the event via s.On.
+func (s *Server) Off(event string) {
+	s.listeners.remove(event)
+}
+
+// EmitTo emits a message to a connection UUID of s. A message for a
+// dropped resumable session is buffered until the session is resumed, see
+// Config.ResumeSecret. It returns ErrorInvalidConnection when the target is
+// unknown or no longer alive.
+func (s *Server) EmitTo(uuid string, message []byte, mType ...int) error {
+	conn, err := s.pool.get(uuid)
+	if err != nil {
+		if s.bufferForSession(uuid, message, mType...) {
+			return nil
+		}
+		return ErrorInvalidConnection
+	}
+	if !conn.IsAlive() {
+		return ErrorInvalidConnection
+	}
+
+	conn.Emit(message, mType...)
+	return nil
+}
+
+// bufferForSession keeps a message for a dropped session until it is
+// resumed. It reports false when uuid names no dropped session.
+func (s *Server) bufferForSession(uuid string, message []byte, mType ...int) bool {
+	t := TextMessage
+	if len(mType) > 0 {
+		t = mType[0]
+	}
+	return s.sessions.buffer(uuid, t, message)
+}
+
+// EmitToList emits a message to a list of connection UUIDs of s. Per-UUID
+// errors are silently ignored.
+func (s *Server) EmitToList(uuids []string, message []byte, mType ...int) {
+	for _, wsUUID := range uuids {
+		_ = s.EmitTo(wsUUID, message, mType...)
+	}
+}
+
+// Broadcast emits to all active connections of s.
+func (s *Server) Broadcast(message []byte, mType ...int) {
+	for _, kws := range s.pool.all() {
+		kws.Emit(message, mType...)
+	}
+}
+
+// Fire fires a custom event on all active connections of s.
+func (s *Server) Fire(event string, data []byte) {
+	for _, kws := range s.pool.all() {
+		kws.fireEvent(event, data, nil)
+	}
+}
+
+// IsDraining reports whether s is in draining mode.
+func (s *Server) IsDraining() bool {
+	return s.draining.Load()
+}
+
+// Drain marks s as draining. From then on its handlers refuse upgrades
+// with 503 Service Unavailable. See StagedDrain to also close the
+// connections.
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
+// CloseAll iterates every active connection of s and sends a close control
+// frame with the supplied code and reason, then waits for each helper's
+// run() loop to exit. If ctx expires first, remaining connections are force
+// closed via Conn.Close. Reason is capped at 123 bytes per RFC 6455.
+func (s *Server) CloseAll(ctx context.Context, code int, reason string) error {
+	snapshot := s.pool.all()
+	if len(snapshot) == 0 {
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range snapshot {
+		kws, ok := c.(*Websocket)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(kws *Websocket) {
+			defer wg.Done()
+			kws.closeWith(code, reason)
+		}(kws)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.forceClose(ctx.Err())
+		return ctx.Err()
+	}
+}
+
+// forceClose closes every connection of s without a close handshake.
+func (s *Server) forceClose(err error) {
+	for _, c := range s.pool.all() {
+		kws, ok := c.(*Websocket)
+		if !ok {
+			continue
+		}
+		// Mark disconnected (clears isAlive, removes from pool) before the
+		// force close so later emits cannot target a closed connection.
+		kws.endSession()
+		kws.disconnected(err)
+		kws.closeConn()
+	}
+}
@@ -0,0 +1,90 @@
+package event
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// TestServersAreIsolated mounts two servers and the default one in one app
+// and checks that pools, listeners and drain state do not leak between
+// them.
+func TestServersAreIsolated(t *testing.T) {
+	resetState()
+
+	chat := NewServer()
+	admin := NewServer(Config{PingInterval: 5 * time.Second})
+
+	app := fiber.New()
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = app.Shutdown()
+		_ = ln.Close()
+	}()
+
+	app.Use(upgradeMiddleware)
+	chat.On(EventMessage, func(p *EventPayload) { p.Kws.Emit(append([]byte("chat:"), p.Data...)) })
+	admin.On(EventMessage, func(p *EventPayload) { p.Kws.Emit(append([]byte("admin:"), p.Data...)) })
+	app.Get("/chat", chat.Handler(func(*Websocket) {}))
+	app.Get("/admin", admin.Handler(func(kws *Websocket) {
+		require.Equal(t, 5*time.Second, kws.settings.pingInterval)
+	}))
+	app.Get("/", New(func(*Websocket) {}))
+
+	go func() { _ = app.Listener(ln) }()
+
+	dialer := &websocket.Dialer{
+		NetDial:          func(_, _ string) (net.Conn, error) { return ln.Dial() },
+		HandshakeTimeout: 5 * time.Second,
+	}
+	dial := func(path string) *websocket.Conn {
+		conn, _, err := dialWithRetry(dialer, "ws://"+ln.Addr().String()+path)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	}
+	chatConn := dial("/chat")
+	adminConn := dial("/admin")
+	dial("/")
+
+	require.Eventually(t, func() bool {
+		return len(chat.pool.all()) == 1 && len(admin.pool.all()) == 1 && len(defaultServer.pool.all()) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, chatConn.WriteMessage(websocket.TextMessage, []byte("hi")))
+	_, msg, err := chatConn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, "chat:hi", string(msg))
+
+	admin.Broadcast([]byte("notice"))
+	_, msg, err = adminConn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, "notice", string(msg))
+
+	for id := range chat.pool.all() {
+		require.ErrorIs(t, admin.EmitTo(id, []byte("x")), ErrorInvalidConnection)
+		require.ErrorIs(t, EmitTo(id, []byte("x")), ErrorInvalidConnection)
+	}
+
+	admin.Drain()
+	require.True(t, admin.IsDraining())
+	require.False(t, chat.IsDraining())
+	require.False(t, IsDraining())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	require.NoError(t, admin.CloseAll(ctx, websocket.CloseGoingAway, "bye"))
+	require.Empty(t, admin.pool.all())
+	require.Len(t, chat.pool.all(), 1)
+	require.Len(t, defaultServer.pool.all(), 1)
+
+	require.NoError(t, chat.CloseAll(ctx, websocket.CloseNormalClosure, ""))
+	require.NoError(t, CloseAll(ctx, websocket.CloseNormalClosure, ""))
+}
@@ -0,0 +1,315 @@
+package event
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/v3/websocket"
+)
+
+// session is the resumable state of a connection, see Config.ResumeSecret.
+// While a connection is attached, kws points to it and the state lives on
+// the connection itself; the fields below it only hold the state of a
+// detached session during its grace period.
+type session struct {
+	uuid  string
+	nonce string
+	kws   *Websocket
+
+	attributes map[string]any
+	topics     map[*Hub][]string
+	buffer     []message
+	bufferSize int
+	timer      *time.Timer
+}
+
+// sessionStore holds the resumable sessions of a Server by UUID.
+type sessionStore struct {
+	mu   sync.Mutex
+	list map[string]*session
+}
+
+// resumeState is what a resumed connection takes over from the session.
+type resumeState struct {
+	// previous is the connection the session was still attached to, if
+	// the client came back before its old connection was found dead.
+	previous   *Websocket
+	attributes map[string]any
+	topics     map[*Hub][]string
+	buffer     []message
+}
+
+// attach binds kws to the session named by token, or to a new session when
+// token does not verify or its session is gone. It runs before kws is
+// published, so it sets kws.UUID and kws.session directly.
+func (s *sessionStore) attach(kws *Websocket, token string) (resumeState, bool) {
+	secret := kws.settings.resumeSecret
+	uuid, nonce, ok := parseResumeToken(secret, token)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.list == nil {
+		s.list = make(map[string]*session)
+	}
+
+	sess := s.list[uuid]
+	if !ok || sess == nil || !hmac.Equal([]byte(sess.nonce), []byte(nonce)) {
+		sess = &session{uuid: kws.UUID, nonce: newResumeNonce(), kws: kws}
+		s.list[sess.uuid] = sess
+		kws.session = sess
+		return resumeState{}, false
+	}
+
+	state := resumeState{
+		previous:   sess.kws,
+		attributes: sess.attributes,
+		topics:     sess.topics,
+		buffer:     sess.buffer,
+	}
+	if sess.timer != nil {
+		sess.timer.Stop()
+	}
+	sess.nonce = newResumeNonce()
+	sess.kws = kws
+	sess.attributes, sess.topics, sess.buffer, sess.timer = nil, nil, nil, nil
+	kws.UUID = sess.uuid
+	kws.session = sess
+	return state, true
+}
+
+// detach keeps the state of kws for the grace period. It is a no-op when
+// another connection took the session over meanwhile.
+func (s *sessionStore) detach(kws *Websocket, attributes map[string]any, topics map[*Hub][]string, unsent []message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := kws.session
+	if sess.kws != kws || s.list[sess.uuid] != sess {
+		return
+	}
+	sess.kws = nil
+	sess.attributes = attributes
+	sess.topics = topics
+	sess.bufferSize = kws.settings.resumeBufferSize
+	sess.buffer = nil
+	for _, msg := range unsent {
+		sess.push(msg)
+	}
+	sess.timer = time.AfterFunc(kws.settings.resumeGracePeriod, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if sess.kws == nil && s.list[sess.uuid] == sess {
+			delete(s.list, sess.uuid)
+		}
+	})
+}
+
+// end forgets the session of kws, so it can no longer be resumed.
+func (s *sessionStore) end(kws *Websocket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := kws.session
+	if sess.kws == kws && s.list[sess.uuid] == sess {
+		delete(s.list, sess.uuid)
+	}
+}
+
+// rename moves the session of kws to uuid. The caller holds kws.mu.
+func (s *sessionStore) rename(kws *Websocket, uuid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := kws.session
+	if existing, ok := s.list[uuid]; ok && existing != sess {
+		return ErrorUUIDDuplication
+	}
+	if s.list[sess.uuid] == sess {
+		delete(s.list, sess.uuid)
+	}
+	sess.uuid = uuid
+	s.list[uuid] = sess
+	return nil
+}
+
+// reserved reports whether uuid belongs to a session other than the one of
+// kws.
+func (s *sessionStore) reserved(kws *Websocket, uuid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.list[uuid]
+	return ok && sess != kws.session
+}
+
+// buffer keeps a message for the detached session uuid. It reports false
+// when there is no such session.
+func (s *sessionStore) buffer(uuid string, mType int, data []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.list[uuid]
+	if !ok || sess.kws != nil {
+		return false
+	}
+	sess.push(message{mType: mType, data: data, queued: time.Now()})
+	return true
+}
+
+// push appends msg to the buffer, dropping the oldest message when full.
+func (sess *session) push(msg message) {
+	if len(sess.buffer) >= sess.bufferSize {
+		if sess.bufferSize <= 0 {
+			return
+		}
+		sess.buffer = append(sess.buffer[:0], sess.buffer[1:]...)
+	}
+	msg.retries = 0
+	sess.buffer = append(sess.buffer, msg)
+}
+
+func (s *sessionStore) token(kws *Websocket) string {
+	s.mu.Lock()
+	uuid, nonce := kws.session.uuid, kws.session.nonce
+	s.mu.Unlock()
+	payload := base64.RawURLEncoding.EncodeToString([]byte(uuid)) + "." + nonce
+	return payload + "." + signResumePayload(kws.settings.resumeSecret, payload)
+}
+
+// parseResumeToken verifies a token of the form
+// base64url(uuid) "." nonce "." base64url(HMAC-SHA256(payload)).
+func parseResumeToken(secret []byte, token string) (uuid, nonce string, ok bool) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", "", false
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(signResumePayload(secret, payload))) {
+		return "", "", false
+	}
+	encoded, nonce, found := strings.Cut(payload, ".")
+	if !found {
+		return "", "", false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	return string(raw), nonce, true
+}
+
+func signResumePayload(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newResumeNonce() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ResumeToken returns the token a client presents to resume this session
+// after a disconnect, see Config.ResumeSecret. The token changes whenever
+// the session is resumed or its UUID changes, so send the current one to
+// the client on every connect. It returns "" when resumption is disabled.
+func (kws *Websocket) ResumeToken() string {
+	if kws.session == nil {
+		return ""
+	}
+	return kws.server.sessions.token(kws)
+}
+
+// Resumed reports whether the connection took over a previous session.
+func (kws *Websocket) Resumed() bool {
+	return kws.resumed
+}
+
+// endSession forgets the session of a connection closed by the server.
+func (kws *Websocket) endSession() {
+	if kws.session != nil {
+		kws.server.sessions.end(kws)
+	}
+}
+
+// resume binds a new connection to the session named by token and restores
+// its state. It runs before kws is added to the pool.
+func (kws *Websocket) resume(token string) {
+	state, ok := kws.server.sessions.attach(kws, token)
+	if !ok {
+		return
+	}
+	kws.resumed = true
+
+	if prev := state.previous; prev != nil {
+		// The client came back before its old connection was found dead.
+		// Close the old one and take its state, including the messages it
+		// has not written yet; its own suspend no longer touches the
+		// session.
+		state.buffer = prev.pending()
+		if prev.IsAlive() {
+			prev.closeOnce.Do(func() {
+				prev.writeClose(websocket.CloseNormalClosure, "Session resumed")
+				prev.fireEvent(EventClose, nil, nil)
+				prev.disconnected(nil)
+			})
+		}
+		// Waits for a disconnect in progress elsewhere.
+		prev.disconnected(nil)
+		// Messages queued until it left the pool.
+		state.buffer = append(state.buffer, prev.pending()...)
+		prev.mu.RLock()
+		state.topics = prev.leftTopics
+		prev.mu.RUnlock()
+		state.attributes = prev.attributesSnapshot()
+	}
+	if state.attributes != nil {
+		kws.attributes = state.attributes
+	}
+	// Keep the newest messages the queue holds, so the replay cannot block
+	// although the writer is not running yet.
+	if n := len(state.buffer) - cap(kws.queue); n > 0 {
+		state.buffer = state.buffer[n:]
+	}
+	for _, msg := range state.buffer {
+		msg.retries = 0
+		select {
+		case kws.queue <- msg:
+		default:
+		}
+	}
+	for h, topics := range state.topics {
+		for _, topic := range topics {
+			_ = h.Subscribe(kws, topic)
+		}
+	}
+}
+
+// suspend keeps the session of a disconnected connection for the grace
+// period. It runs after the connection's goroutines have stopped, so the
+// queue holds exactly the messages that were not written.
+func (kws *Websocket) suspend() {
+	unsent := kws.pending()
+	kws.mu.RLock()
+	topics := kws.leftTopics
+	kws.mu.RUnlock()
+	kws.server.sessions.detach(kws, kws.attributesSnapshot(), topics, unsent)
+}
+
+// pending takes the messages kws has not written: the one whose write
+// failed, then the queue.
+func (kws *Websocket) pending() []message {
+	kws.mu.Lock()
+	msgs := kws.unsent
+	kws.unsent = nil
+	kws.mu.Unlock()
+	for {
+		select {
+		case msg := <-kws.queue:
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
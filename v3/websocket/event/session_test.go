@@ -0,0 +1,170 @@
+package event
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func TestResumeTokenVerification(t *testing.T) {
+	secret := []byte("secret")
+	payload := "dXNlci0x.nonce"
+	token := payload + "." + signResumePayload(secret, payload)
+
+	uuid, nonce, ok := parseResumeToken(secret, token)
+	require.True(t, ok)
+	require.Equal(t, "user-1", uuid)
+	require.Equal(t, "nonce", nonce)
+
+	for _, bad := range []string{
+		"",
+		payload,
+		token + "x",
+		"dXNlci0y.nonce." + signResumePayload(secret, payload),
+	} {
+		_, _, ok := parseResumeToken(secret, bad)
+		require.False(t, ok, bad)
+	}
+	_, _, ok = parseResumeToken([]byte("other"), token)
+	require.False(t, ok)
+}
+
+func TestSessionResume(t *testing.T) {
+	s := NewServer(Config{ResumeSecret: []byte("secret"), ResumeGracePeriod: time.Minute})
+	hub, err := NewHub()
+	require.NoError(t, err)
+	defer func() { _ = hub.Close() }()
+
+	app := fiber.New()
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = app.Shutdown()
+		_ = ln.Close()
+	}()
+
+	kwsCh := make(chan *Websocket, 1)
+	app.Use(upgradeMiddleware)
+	app.Get("/", s.Handler(func(kws *Websocket) {
+		if !kws.Resumed() {
+			kws.SetAttribute("user", "alice")
+			require.NoError(t, hub.Subscribe(kws, "news"))
+		}
+		kws.Emit([]byte(kws.ResumeToken()))
+		kwsCh <- kws
+	}))
+	go func() { _ = app.Listener(ln) }()
+
+	dialer := &websocket.Dialer{
+		NetDial:          func(_, _ string) (net.Conn, error) { return ln.Dial() },
+		HandshakeTimeout: 5 * time.Second,
+	}
+	read := func(conn *websocket.Conn) string {
+		_, msg, err := conn.ReadMessage()
+		require.NoError(t, err)
+		return string(msg)
+	}
+	dial := func(token string) (*websocket.Conn, *Websocket) {
+		conn, _, err := dialWithRetry(dialer, "ws://"+ln.Addr().String()+"/?resume="+url.QueryEscape(token))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn, <-kwsCh
+	}
+
+	conn, first := dial("")
+	token := read(conn)
+	require.False(t, first.Resumed())
+	require.NotEmpty(t, token)
+
+	// Drop the connection without a close handshake.
+	_ = conn.UnderlyingConn().Close()
+	require.Eventually(t, func() bool { return !first.IsAlive() }, 2*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return s.EmitTo(first.GetUUID(), []byte("missed")) == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Zero(t, hub.Subscribers("news"))
+
+	// A UUID of a dropped session stays reserved.
+	other := createWS()
+	other.server = s
+	require.ErrorIs(t, other.SetUUID(first.GetUUID()), ErrorUUIDDuplication)
+
+	// Buffered messages are replayed before anything the handler sends.
+	conn, second := dial(token)
+	require.True(t, second.Resumed())
+	require.Equal(t, first.GetUUID(), second.GetUUID())
+	require.Equal(t, "alice", second.GetStringAttribute("user"))
+	require.Equal(t, "missed", read(conn))
+	next := read(conn)
+	require.NotEqual(t, token, next)
+
+	require.NoError(t, hub.Publish("news", []byte("headline")))
+	require.Equal(t, "headline", read(conn))
+
+	// Tokens are single use, and a session closed by the server is gone.
+	_, third := dial(token)
+	require.False(t, third.Resumed())
+	second.Close()
+	_, fourth := dial(next)
+	require.False(t, fourth.Resumed())
+	require.NotEqual(t, second.GetUUID(), fourth.GetUUID())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, s.CloseAll(ctx, websocket.CloseNormalClosure, ""))
+}
+
+func TestSessionTakeover(t *testing.T) {
+	s := NewServer(Config{ResumeSecret: []byte("secret")})
+	conn, first := newEnvelopeServer(t, s)
+	first.SetAttribute("n", 1)
+	token := first.ResumeToken()
+
+	// The client reconnects before the server noticed the old connection
+	// is gone; the new connection takes the session over.
+	second := createWS()
+	second.server = s
+	second.settings = first.settings
+	second.resume(token)
+	require.True(t, second.Resumed())
+	require.False(t, first.IsAlive())
+	require.Equal(t, first.GetUUID(), second.GetUUID())
+	require.Equal(t, 1, second.GetIntAttribute("n"))
+
+	_, _, err := conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure))
+	second.Close()
+}
+
+func TestSessionTakeoverKeepsPending(t *testing.T) {
+	s := NewServer(Config{ResumeSecret: []byte("secret")})
+	// The old connection is still attached, with a failed write and a
+	// queued message its writer never got to.
+	first := createWS()
+	first.server = s
+	first.settings = s.settings
+	first.resume("")
+	first.unsent = []message{{mType: TextMessage, data: []byte("unsent")}}
+	first.queue <- message{mType: TextMessage, data: []byte("queued")}
+
+	second := createWS()
+	second.server = s
+	second.settings = s.settings
+	second.queue = make(chan message, 2)
+	second.resume(first.ResumeToken())
+	require.True(t, second.Resumed())
+	require.False(t, first.IsAlive())
+	require.Empty(t, first.queue)
+	require.Nil(t, first.unsent)
+
+	require.Len(t, second.queue, 2)
+	require.Equal(t, "unsent", string((<-second.queue).data))
+	require.Equal(t, "queued", string((<-second.queue).data))
+}
@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/utils/v2"
+)
+
+// originPattern is a parsed Origins entry such as "https://*.example.com"
+// or "http://localhost:*".
+type originPattern struct {
+	scheme string
+	// host is the host name, without the "*." prefix of a subdomain
+	// wildcard.
+	host string
+	// subdomains is true for a "*." pattern, which matches any subdomain
+	// of host at any depth but not host itself.
+	subdomains bool
+	// port is the port, "" for the scheme's default port or "*" for any
+	// port.
+	port string
+}
+
+// originMatcher decides whether an Origin header is allowed, see
+// Config.Origins, Config.OriginRegexps and Config.OriginValidator.
+type originMatcher struct {
+	anyOrigin  bool
+	allowEmpty bool
+	// raw holds the entries that are not parseable as origins, compared
+	// verbatim as before origin parsing existed.
+	raw       []string
+	patterns  []originPattern
+	regexps   []*regexp.Regexp
+	validator func(origin string, c fiber.Ctx) bool
+}
+
+func newOriginMatcher(cfg Config) *originMatcher {
+	m := &originMatcher{
+		allowEmpty: cfg.AllowEmptyOrigin,
+		regexps:    cfg.OriginRegexps,
+		validator:  cfg.OriginValidator,
+	}
+	for _, origin := range cfg.Origins {
+		if origin == "*" {
+			m.anyOrigin = true
+			continue
+		}
+		if p, ok := parseOriginPattern(origin); ok {
+			m.patterns = append(m.patterns, p)
+		} else {
+			m.raw = append(m.raw, origin)
+		}
+	}
+	return m
+}
+
+func (m *originMatcher) allow(c fiber.Ctx) bool {
+	if m.anyOrigin {
+		return true
+	}
+	origin := utils.UnsafeString(c.RequestCtx().Request.Header.Peek("Origin"))
+	if origin == "" {
+		return m.allowEmpty
+	}
+	for _, raw := range m.raw {
+		if raw == origin {
+			return true
+		}
+	}
+	if scheme, host, port, ok := parseOrigin(origin); ok {
+		for i := range m.patterns {
+			if m.patterns[i].match(scheme, host, port) {
+				return true
+			}
+		}
+	}
+	for _, re := range m.regexps {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	if m.validator != nil {
+		return m.validator(origin, c)
+	}
+	return false
+}
+
+func (p *originPattern) match(scheme, host, port string) bool {
+	if p.scheme != scheme {
+		return false
+	}
+	if p.port != "*" && p.port != port {
+		return false
+	}
+	if p.subdomains {
+		return strings.HasSuffix(host, "."+p.host)
+	}
+	return host == p.host
+}
+
+// parseOrigin splits an Origin header into its lowercased scheme, host and
+// port. The port is "" when it is the default port of the scheme.
+func parseOrigin(origin string) (scheme, host, port string, ok bool) {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User != nil ||
+		(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return "", "", "", false
+	}
+	scheme = strings.ToLower(u.Scheme)
+	host = strings.ToLower(u.Hostname())
+	port = normalizePort(scheme, u.Port())
+	return scheme, host, port, host != ""
+}
+
+// parseOriginPattern parses "scheme://host[:port]" where host may start
+// with "*." and port may be "*".
+func parseOriginPattern(pattern string) (originPattern, bool) {
+	scheme, rest, found := strings.Cut(pattern, "://")
+	if !found || scheme == "" || rest == "" || strings.ContainsAny(rest, "/?#@") {
+		return originPattern{}, false
+	}
+	p := originPattern{scheme: strings.ToLower(scheme)}
+
+	host := rest
+	if strings.HasPrefix(rest, "[") || strings.Count(rest, ":") == 1 {
+		h, port, err := net.SplitHostPort(rest)
+		if err == nil {
+			host, p.port = h, port
+		} else if !strings.HasPrefix(rest, "[") || !strings.HasSuffix(rest, "]") {
+			return originPattern{}, false
+		} else {
+			host = rest[1 : len(rest)-1]
+		}
+	}
+	host = strings.ToLower(host)
+	if strings.HasPrefix(host, "*.") {
+		p.subdomains = true
+		host = host[2:]
+	}
+	if host == "" || strings.Contains(host, "*") {
+		return originPattern{}, false
+	}
+	p.host = host
+	if p.port != "*" {
+		p.port = normalizePort(p.scheme, p.port)
+	}
+	return p, true
+}
+
+// normalizePort maps the default port of scheme to "".
+func normalizePort(scheme, port string) string {
+	switch {
+	case port == "80" && (scheme == "http" || scheme == "ws"),
+		port == "443" && (scheme == "https" || scheme == "wss"):
+		return ""
+	}
+	return port
+}
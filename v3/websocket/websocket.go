@@ -9,7 +9,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"runtime/debug"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -31,10 +34,43 @@ type Config struct {
 	// Subprotocols specifies the client's requested subprotocols.
 	Subprotocols []string
 
+	// SelectSubprotocol negotiates the subprotocol per request instead of the
+	// static Subprotocols list, which it replaces when set. It receives the
+	// protocols the client requested, in the client's order, and returns the one
+	// to use, or "" for none. A value the client did not request is ignored.
+	// Optional. Default: nil
+	SelectSubprotocol func(requested []string, c fiber.Ctx) string
+
+	// BeforeUpgrade runs before the connection is upgraded, after the origin
+	// check, e.g. to authenticate the client. A non-nil error aborts the upgrade
+	// and is returned to Fiber's error handler, so a *fiber.Error sets the HTTP
+	// status (e.g. fiber.ErrUnauthorized). Locals stored here are copied into
+	// the Conn.
+	// Optional. Default: nil
+	BeforeUpgrade func(c fiber.Ctx) error
+
 	// Allowed Origin's based on the Origin header, this validate the request origin to
-	// prevent cross-site request forgery. Everything is allowed if left empty.
+	// prevent cross-site request forgery. Everything is allowed if left empty
+	// and neither OriginRegexps nor OriginValidator is set.
+	//
+	// Entries are parsed as "scheme://host[:port]" and compared case-insensitively,
+	// ignoring the scheme's default port. The host may start with "*." to allow any
+	// subdomain (e.g. "https://*.example.com" allows "https://a.b.example.com" but not
+	// "https://example.com"), and the port may be "*" to allow any port. "*" alone
+	// allows every origin.
 	Origins []string
 
+	// OriginRegexps allows every Origin header matched by one of the expressions.
+	// The header is matched verbatim, so anchor the expressions.
+	// Optional. Default: nil
+	OriginRegexps []*regexp.Regexp
+
+	// OriginValidator is called for a non-empty Origin header that Origins and
+	// OriginRegexps do not allow, with the request's context so the origin can be
+	// checked against e.g. the tenant of the request. Returning true allows it.
+	// Optional. Default: nil
+	OriginValidator func(origin string, c fiber.Ctx) bool
+
 	// AllowEmptyOrigin allows WebSocket connections when the Origin header is absent.
 	// When false (default), connections without an Origin header are rejected unless Origins includes "*".
 	// Set to true to allow connections from non-browser clients that don't send Origin headers.
@@ -63,6 +99,19 @@ type Config struct {
 	// takeover" modes are supported.
 	EnableCompression bool
 
+	// CompressionLevel is the flate level used for compressed messages, from
+	// flate.HuffmanOnly to flate.BestCompression. Zero keeps the default,
+	// flate.BestSpeed. Conn.SetCompressionLevel changes it per connection.
+	// Optional. Default: 0
+	CompressionLevel int
+
+	// CompressionThreshold is the size in bytes below which WriteMessage sends
+	// a message uncompressed, so tiny messages do not pay the compression cost.
+	// Zero compresses every message. Conn.SetCompressionThreshold changes it
+	// per connection.
+	// Optional. Default: 0
+	CompressionThreshold int
+
 	// RecoverHandler is a panic handler function that recovers from panics
 	// Default recover function is used when nil and writes error message in a response field `error`
 	// It prints stack trace to the stderr by default
@@ -102,17 +151,10 @@ func New(handler func(*Conn), config ...Config) fiber.Handler {
 	if len(config) > 0 {
 		cfg = config[0]
 	}
-	if len(cfg.Origins) == 0 {
+	if len(cfg.Origins) == 0 && len(cfg.OriginRegexps) == 0 && cfg.OriginValidator == nil {
 		cfg.Origins = []string{"*"}
 	}
-	// Check if wildcard is present in the Origins list during initialization
-	hasWildcard := false
-	for _, origin := range cfg.Origins {
-		if origin == "*" {
-			hasWildcard = true
-			break
-		}
-	}
+	origins := newOriginMatcher(cfg)
 	if cfg.ReadBufferSize == 0 {
 		cfg.ReadBufferSize = 1024
 	}
@@ -122,6 +164,12 @@ func New(handler func(*Conn), config ...Config) fiber.Handler {
 	if cfg.RecoverHandler == nil {
 		cfg.RecoverHandler = defaultRecover
 	}
+	if cfg.CompressionLevel != 0 && !validCompressionLevel(cfg.CompressionLevel) {
+		panic(ErrInvalidCompressionLevel)
+	}
+	if cfg.SelectSubprotocol != nil {
+		cfg.Subprotocols = nil
+	}
 	var upgrader = websocket.FastHTTPUpgrader{
 		HandshakeTimeout:  cfg.HandshakeTimeout,
 		Subprotocols:      cfg.Subprotocols,
@@ -129,36 +177,35 @@ func New(handler func(*Conn), config ...Config) fiber.Handler {
 		WriteBufferSize:   cfg.WriteBufferSize,
 		EnableCompression: cfg.EnableCompression,
 		WriteBufferPool:   cfg.WriteBufferPool,
-		CheckOrigin: func(fctx *fasthttp.RequestCtx) bool {
-			// Fast path: if Origins is just wildcard (the default), allow all without checking header
-			if len(cfg.Origins) == 1 && cfg.Origins[0] == "*" {
-				return true
-			}
-			origin := utils.UnsafeString(fctx.Request.Header.Peek("Origin"))
-			if origin == "" {
-				// Allow empty Origin if wildcard is in list or explicitly configured
-				return hasWildcard || cfg.AllowEmptyOrigin
-			}
-			// If wildcard is present, allow any non-empty origin
-			if hasWildcard {
-				return true
-			}
-			// No wildcard present, check if origin matches any specific origin in the list
-			for i := range cfg.Origins {
-				if cfg.Origins[i] == origin {
-					return true
-				}
-			}
-			return false
+		// The origin is checked by the handler below, which has the fiber.Ctx
+		// OriginValidator needs.
+		CheckOrigin: func(*fasthttp.RequestCtx) bool {
+			return true
 		},
 	}
 	return func(c fiber.Ctx) error {
 		if cfg.Next != nil && cfg.Next(c) {
 			return c.Next()
 		}
+		if !origins.allow(c) {
+			return fiber.ErrUpgradeRequired
+		}
+		if cfg.BeforeUpgrade != nil {
+			if err := cfg.BeforeUpgrade(c); err != nil {
+				return err
+			}
+		}
+		if cfg.SelectSubprotocol != nil {
+			requested := requestedSubprotocols(c)
+			if protocol := cfg.SelectSubprotocol(requested, c); protocol != "" && slices.Contains(requested, protocol) {
+				// With Subprotocols nil the upgrader answers with this header.
+				c.Set("Sec-WebSocket-Protocol", protocol)
+			}
+		}
 		ensureKeepHijackedConns(c.App().Server())
 
 		conn := acquireConn()
+		conn.compression.reset(cfg.CompressionLevel, cfg.CompressionThreshold)
 		// locals
 		c.RequestCtx().VisitUserValues(func(key []byte, value interface{}) {
 			conn.locals[string(key)] = value
@@ -205,6 +252,20 @@ func New(handler func(*Conn), config ...Config) fiber.Handler {
 	}
 }
 
+// requestedSubprotocols returns the protocols of the client's
+// Sec-WebSocket-Protocol header.
+func requestedSubprotocols(c fiber.Ctx) []string {
+	header := strings.TrimSpace(c.Get("Sec-WebSocket-Protocol"))
+	if header == "" {
+		return nil
+	}
+	protocols := strings.Split(header, ",")
+	for i := range protocols {
+		protocols[i] = strings.TrimSpace(protocols[i])
+	}
+	return protocols
+}
+
 // Conn https://godoc.org/github.com/gorilla/websocket#pkg-index
 type Conn struct {
 	*websocket.Conn
@@ -214,6 +275,8 @@ type Conn struct {
 	headers map[string]string
 	queries map[string]string
 	ip      string
+	// compression holds the write compression settings, see compression.go.
+	compression compression
 }
 
 // Conn pool
@@ -1,8 +1,12 @@
 package websocket
 
 import (
+	"bytes"
+	"compress/flate"
 	"net"
 	"net/http"
+	"regexp"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -10,6 +14,8 @@ import (
 	"github.com/gofiber/fiber/v3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
 )
 
 func TestWebSocketMiddlewareDefaultConfig(t *testing.T) {
@@ -161,6 +167,148 @@ func TestWebSocketMiddlewareConfigOrigin(t *testing.T) {
 	})
 }
 
+func TestOriginMatcher(t *testing.T) {
+	app := fiber.New()
+	allowed := func(cfg Config, origin string) bool {
+		fctx := &fasthttp.RequestCtx{}
+		if origin != "" {
+			fctx.Request.Header.Set("Origin", origin)
+		}
+		c := app.AcquireCtx(fctx)
+		defer app.ReleaseCtx(c)
+		return newOriginMatcher(cfg).allow(c)
+	}
+
+	wildcard := Config{Origins: []string{"https://*.tenant.example.com", "http://localhost:*", "HTTPS://Example.com:443"}}
+	for origin, want := range map[string]bool{
+		"https://a.tenant.example.com":      true,
+		"https://a.b.tenant.example.com":    true,
+		"https://A.Tenant.Example.com:443":  true,
+		"https://tenant.example.com":        false,
+		"http://a.tenant.example.com":       false,
+		"https://a.tenant.example.com:8443": false,
+		"https://evil-tenant.example.com":   false,
+		"https://a.tenant.example.com.evil": false,
+		"http://localhost":                  true,
+		"http://localhost:5173":             true,
+		"https://example.com":               true,
+		"https://example.com/":              true,
+		"https://example.com:8443":          false,
+		"https://user@example.com":          false,
+		"null":                              false,
+		"":                                  false,
+	} {
+		assert.Equal(t, want, allowed(wildcard, origin), origin)
+	}
+
+	// Unparseable entries keep the historical verbatim comparison.
+	assert.True(t, allowed(Config{Origins: []string{"null"}}, "null"))
+
+	regexps := Config{OriginRegexps: []*regexp.Regexp{regexp.MustCompile(`^https://app-[0-9]+\.example\.com$`)}}
+	assert.True(t, allowed(regexps, "https://app-42.example.com"))
+	assert.False(t, allowed(regexps, "https://app-x.example.com"))
+}
+
+func TestWebSocketMiddlewareOriginValidator(t *testing.T) {
+	var seen string
+	app := setupTestApp(Config{
+		Origins: []string{"http://localhost:3000"},
+		OriginValidator: func(origin string, c fiber.Ctx) bool {
+			seen = origin
+			return c.Query("tenant") == "acme" && origin == "https://acme.example.com"
+		},
+	}, nil)
+	defer app.Shutdown()
+
+	// Origins still allow without consulting the validator.
+	conn, resp, err := websocket.DefaultDialer.Dial("ws://localhost:3000/ws/message", http.Header{
+		"Origin": []string{"http://localhost:3000"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusSwitchingProtocols, resp.StatusCode)
+	conn.Close()
+	assert.Empty(t, seen)
+
+	conn, resp, err = websocket.DefaultDialer.Dial("ws://localhost:3000/ws/message?tenant=acme", http.Header{
+		"Origin": []string{"https://acme.example.com"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusSwitchingProtocols, resp.StatusCode)
+	conn.Close()
+	assert.Equal(t, "https://acme.example.com", seen)
+
+	_, resp, err = websocket.DefaultDialer.Dial("ws://localhost:3000/ws/message?tenant=other", http.Header{
+		"Origin": []string{"https://acme.example.com"},
+	})
+	assert.ErrorIs(t, err, websocket.ErrBadHandshake)
+	assert.Equal(t, fiber.StatusUpgradeRequired, resp.StatusCode)
+}
+
+func TestWebSocketMiddlewareBeforeUpgrade(t *testing.T) {
+	app := setupTestApp(Config{
+		BeforeUpgrade: func(c fiber.Ctx) error {
+			if c.Get("Authorization") != "Bearer good" {
+				return fiber.ErrUnauthorized
+			}
+			fiber.StoreInContext(c, "user", "alice")
+			return nil
+		},
+	}, func(c *Conn) {
+		c.WriteJSON(fiber.Map{"user": c.Locals("user")})
+	})
+	defer app.Shutdown()
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws://localhost:3000/ws/message", nil)
+	assert.ErrorIs(t, err, websocket.ErrBadHandshake)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+
+	conn, resp, err := websocket.DefaultDialer.Dial("ws://localhost:3000/ws/message", http.Header{
+		"Authorization": []string{"Bearer good"},
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, fiber.StatusSwitchingProtocols, resp.StatusCode)
+
+	var msg fiber.Map
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "alice", msg["user"])
+}
+
+func TestWebSocketMiddlewareSelectSubprotocol(t *testing.T) {
+	var requested []string
+	app := setupTestApp(Config{
+		// Replaced by SelectSubprotocol.
+		Subprotocols: []string{"chat.v1"},
+		SelectSubprotocol: func(protocols []string, c fiber.Ctx) string {
+			requested = protocols
+			return c.Query("proto")
+		},
+	}, func(c *Conn) {
+		c.WriteJSON(fiber.Map{"protocol": c.Subprotocol()})
+	})
+	defer app.Shutdown()
+
+	dial := func(proto string) (string, string) {
+		dialer := websocket.Dialer{Subprotocols: []string{"chat.v1", "chat.v2"}}
+		conn, resp, err := dialer.Dial("ws://localhost:3000/ws/message?proto="+proto, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		var msg fiber.Map
+		require.NoError(t, conn.ReadJSON(&msg))
+		return resp.Header.Get("Sec-WebSocket-Protocol"), msg["protocol"].(string)
+	}
+
+	header, protocol := dial("chat.v2")
+	assert.Equal(t, []string{"chat.v1", "chat.v2"}, requested)
+	assert.Equal(t, "chat.v2", header)
+	assert.Equal(t, "chat.v2", protocol)
+
+	// A protocol the client did not ask for is not sent.
+	header, protocol = dial("chat.v3")
+	assert.Empty(t, header)
+	assert.Empty(t, protocol)
+}
+
 func TestWebSocketMiddlewareBufferSize(t *testing.T) {
 	app := setupTestApp(Config{
 		Origins:         []string{"*"},
@@ -426,6 +574,137 @@ func TestWebSocketCompressionAfterHandlerReturns(t *testing.T) {
 	}
 }
 
+// countingConn counts the bytes read from the wrapped connection.
+type countingConn struct {
+	net.Conn
+	read atomic.Int64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func TestWebSocketCompressionThreshold(t *testing.T) {
+	large := bytes.Repeat([]byte("a"), 2000)
+	app := setupTestApp(Config{
+		EnableCompression:    true,
+		CompressionLevel:     flate.BestCompression,
+		CompressionThreshold: 100,
+	}, func(c *Conn) {
+		assert.ErrorIs(t, c.SetCompressionLevel(42), ErrInvalidCompressionLevel)
+		// Each client message asks for the next server message, so the
+		// client reads exactly one frame at a time.
+		for _, step := range []func(){
+			func() {},
+			func() { c.SetCompressionThreshold(5000) },
+			func() { c.SetCompressionThreshold(0); c.EnableWriteCompression(false) },
+			func() { c.EnableWriteCompression(true) },
+		} {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+			step()
+			if err := c.WriteMessage(TextMessage, large); err != nil {
+				return
+			}
+		}
+	})
+	defer app.Shutdown()
+
+	var counter *countingConn
+	dialer := websocket.Dialer{
+		EnableCompression: true,
+		NetDial: func(network, addr string) (net.Conn, error) {
+			conn, err := net.Dial(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			counter = &countingConn{Conn: conn}
+			return counter, nil
+		},
+	}
+	conn, _, err := dialer.Dial("ws://localhost:3000/ws/message", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	wireSize := func() int64 {
+		before := counter.read.Load()
+		require.NoError(t, conn.WriteMessage(TextMessage, []byte("next")))
+		_, msg, err := conn.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, large, msg)
+		return counter.read.Load() - before
+	}
+
+	assert.Less(t, wireSize(), int64(100), "above the threshold")
+	assert.Greater(t, wireSize(), int64(2000), "below the raised threshold")
+	assert.Greater(t, wireSize(), int64(2000), "compression disabled")
+	assert.Less(t, wireSize(), int64(100), "compression enabled again")
+}
+
+func BenchmarkConnWriteMessage(b *testing.B) {
+	small := []byte(`{"type":"chat","text":"hi"}`)
+	large := bytes.Repeat([]byte(`{"type":"tick","price":"101.25"},`), 256)
+
+	for _, bc := range []struct {
+		name string
+		cfg  Config
+		msg  []byte
+	}{
+		{"small/uncompressed", Config{}, small},
+		{"small/compressed", Config{EnableCompression: true}, small},
+		{"small/threshold", Config{EnableCompression: true, CompressionThreshold: 256}, small},
+		{"large/uncompressed", Config{}, large},
+		{"large/compressed", Config{EnableCompression: true}, large},
+		{"large/threshold", Config{EnableCompression: true, CompressionThreshold: 256}, large},
+		{"large/best-compression", Config{EnableCompression: true, CompressionLevel: flate.BestCompression}, large},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			connCh := make(chan *Conn, 1)
+			done := make(chan struct{})
+			app := fiber.New()
+			ln := fasthttputil.NewInmemoryListener()
+			app.Get("/", New(func(c *Conn) {
+				connCh <- c
+				<-done
+			}, bc.cfg))
+			go func() { _ = app.Listener(ln, fiber.ListenConfig{DisableStartupMessage: true}) }()
+			defer func() {
+				_ = app.Shutdown()
+				_ = ln.Close()
+			}()
+
+			dialer := websocket.Dialer{
+				EnableCompression: true,
+				NetDial:           func(_, _ string) (net.Conn, error) { return ln.Dial() },
+			}
+			client, _, err := dialer.Dial("ws://"+ln.Addr().String(), nil)
+			require.NoError(b, err)
+			defer client.Close()
+			go func() {
+				for {
+					if _, _, err := client.NextReader(); err != nil {
+						return
+					}
+				}
+			}()
+
+			server := <-connCh
+			defer close(done)
+			b.SetBytes(int64(len(bc.msg)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := server.WriteMessage(TextMessage, bc.msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 func setupTestApp(cfg Config, h func(c *Conn)) *fiber.App {
 	var handler fiber.Handler
 	if h == nil {